@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Agent defaults
+const (
+	defaultAgentBatchInterval = 30 * time.Second
+	agentRequestTimeout       = 30 * time.Second
+	agentMaxSpoolFiles        = 5000 // Oldest spooled batches are dropped beyond this
+)
+
+// IngestBatch is what an agent pushes to the aggregator: container metadata
+// with only the samples and events collected since the previous batch
+type IngestBatch struct {
+	Agent      string           `json:"agent"`
+	ConfigName string           `json:"config_name"`
+	SentAt     time.Time        `json:"sent_at"`
+	Final      bool             `json:"final,omitempty"` // The session ended; the aggregator finalizes summaries
+	Containers []*ContainerData `json:"containers"`
+}
+
+// AgentOptions configures `mdok agent`
+type AgentOptions struct {
+	Aggregator    string // Base URL of the aggregator, e.g. http://central:7071
+	Token         string // Bearer token expected by the aggregator
+	Name          string // Agent name; containers are stored as "<name>/<container>"
+	BatchInterval time.Duration
+	Resume        bool
+}
+
+// agentForwarder batches a monitor's new data and pushes it to the aggregator,
+// spooling batches to disk while the aggregator is unreachable
+type agentForwarder struct {
+	opts        AgentOptions
+	configName  string
+	monitor     *Monitor
+	logger      *log.Logger
+	client      *http.Client
+	spoolDir    string
+	sentSamples map[string]int    // Per container: samples already batched
+	sentEvents  map[string]int    // Per container: events already batched
+	sessions    map[string]string // Per container: session the counts refer to
+}
+
+// GetSpoolDir returns the directory holding batches an agent couldn't push yet
+func GetSpoolDir(configName string) string {
+	return filepath.Join(mdokDir, "spool", configName)
+}
+
+// RunAgent monitors a configuration like `mdok start --foreground` and pushes
+// its samples, events and host info to an aggregator
+func RunAgent(config Config, opts AgentOptions) error {
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+
+	if opts.Name == "" {
+		opts.Name = defaultAgentName(config)
+	}
+	if opts.BatchInterval <= 0 {
+		opts.BatchInterval = defaultAgentBatchInterval
+	}
+	opts.Aggregator = strings.TrimSuffix(opts.Aggregator, "/")
+
+	monitor, err := NewMonitor(config, logger)
+	if err != nil {
+		return err
+	}
+	if opts.Resume {
+		monitor.resumeSession()
+	}
+
+	f := &agentForwarder{
+		opts:        opts,
+		configName:  config.Name,
+		monitor:     monitor,
+		logger:      logger,
+		client:      &http.Client{Timeout: agentRequestTimeout},
+		spoolDir:    GetSpoolDir(config.Name),
+		sentSamples: make(map[string]int),
+		sentEvents:  make(map[string]int),
+		sessions:    make(map[string]string),
+	}
+	logger.Printf("Agent '%s' pushing to %s every %s\n", opts.Name, opts.Aggregator, opts.BatchInterval)
+
+	// Batches left over from a previous run go first
+	if err := f.drainSpool(); err != nil {
+		logger.Printf("Agent: aggregator unavailable, keeping spooled batches: %v\n", err)
+	}
+
+	done := make(chan struct{})
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		ticker := time.NewTicker(opts.BatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f.flush(false)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = monitor.Run()
+	close(done)
+	<-flushed
+
+	f.flush(true)
+	return err
+}
+
+// defaultAgentName is the runtime host's name, which is also right when the
+// agent itself runs in a container
+func defaultAgentName(config Config) string {
+	if docker, err := NewRuntime(config); err == nil {
+		defer docker.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if info, err := docker.GetHostInfo(ctx); err == nil && info.Hostname != "" {
+			return info.Hostname
+		}
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// collect builds a batch of everything collected since the previous one
+func (f *agentForwarder) collect(final bool) *IngestBatch {
+	batch := &IngestBatch{
+		Agent:      f.opts.Name,
+		ConfigName: f.configName,
+		SentAt:     time.Now(),
+		Final:      final,
+	}
+
+	m := f.monitor
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, data := range m.containerData {
+		if data == nil {
+			continue
+		}
+		if f.sessions[name] != data.SessionID {
+			f.sessions[name] = data.SessionID
+			f.sentSamples[name] = 0
+			f.sentEvents[name] = 0
+		}
+
+		samples := data.Samples[f.sentSamples[name]:]
+		events := data.Events[f.sentEvents[name]:]
+		if len(samples) == 0 && len(events) == 0 && !final {
+			continue
+		}
+
+		delta := &ContainerData{
+			ContainerID:    data.ContainerID,
+			ContainerName:  data.ContainerName,
+			ImageName:      data.ImageName,
+			ComposeService: data.ComposeService,
+			Endpoint:       data.Endpoint,
+			Host:           data.Host,
+			Limits:         data.Limits,
+			SessionID:      data.SessionID,
+			StartTime:      data.StartTime,
+			EndTime:        data.EndTime,
+			Interval:       data.Interval,
+			Samples:        append([]Sample(nil), samples...),
+			Events:         append([]SessionEvent(nil), events...),
+		}
+		batch.Containers = append(batch.Containers, delta)
+
+		f.sentSamples[name] = len(data.Samples)
+		f.sentEvents[name] = len(data.Events)
+	}
+
+	sort.Slice(batch.Containers, func(i, j int) bool {
+		return batch.Containers[i].ContainerName < batch.Containers[j].ContainerName
+	})
+	return batch
+}
+
+// flush pushes a batch after any spooled ones, spooling it on failure
+func (f *agentForwarder) flush(final bool) {
+	batch := f.collect(final)
+	if len(batch.Containers) == 0 {
+		return
+	}
+
+	body, err := encodeIngestBatch(batch)
+	if err != nil {
+		f.logger.Printf("Agent: failed to encode batch: %v\n", err)
+		return
+	}
+
+	if err := f.drainSpool(); err == nil {
+		if err = f.send(body); err == nil {
+			return
+		}
+		f.logger.Printf("Agent: push failed, spooling batch: %v\n", err)
+	}
+	if err := f.spool(body); err != nil {
+		f.logger.Printf("Agent: failed to spool batch, data lost: %v\n", err)
+	}
+}
+
+// encodeIngestBatch returns a gzip-compressed JSON batch
+func encodeIngestBatch(batch *IngestBatch) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(batch); err != nil {
+		return nil, fmt.Errorf("failed to marshal batch: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress batch: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// send pushes one compressed batch to the aggregator
+func (f *agentForwarder) send(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, f.opts.Aggregator+"/api/ingest", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	if f.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.opts.Token)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("aggregator returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// spool writes a batch to disk to be pushed later, dropping the oldest
+// batches beyond agentMaxSpoolFiles
+func (f *agentForwarder) spool(body []byte) error {
+	if err := os.MkdirAll(f.spoolDir, 0755); err != nil {
+		return fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	file := filepath.Join(f.spoolDir, fmt.Sprintf("%d.json.gz", time.Now().UnixNano()))
+	if err := os.WriteFile(file, body, 0644); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+
+	files := f.spooledFiles()
+	if excess := len(files) - agentMaxSpoolFiles; excess > 0 {
+		for _, old := range files[:excess] {
+			os.Remove(old)
+		}
+		f.logger.Printf("Agent: spool full, dropped %d oldest batches\n", excess)
+	}
+	return nil
+}
+
+// spooledFiles returns the spooled batches, oldest first
+func (f *agentForwarder) spooledFiles() []string {
+	files, _ := filepath.Glob(filepath.Join(f.spoolDir, "*.json.gz"))
+	sort.Strings(files)
+	return files
+}
+
+// drainSpool pushes spooled batches in order, stopping at the first failure
+func (f *agentForwarder) drainSpool() error {
+	files := f.spooledFiles()
+	for i, file := range files {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			os.Remove(file)
+			continue
+		}
+		if err := f.send(body); err != nil {
+			return err
+		}
+		os.Remove(file)
+		if i == len(files)-1 {
+			f.logger.Printf("Agent: pushed %d spooled batches\n", len(files))
+		}
+	}
+	return nil
+}
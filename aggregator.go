@@ -0,0 +1,304 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAggregatorListenAddr is the address `mdok aggregator` listens on by
+// default. Listening on other hosts' interfaces requires a token.
+const defaultAggregatorListenAddr = "127.0.0.1:7071"
+
+// maxIngestBody limits the decompressed size of an ingested batch
+const maxIngestBody = 256 << 20
+
+// AgentInfo describes an agent that has pushed data to the aggregator
+type AgentInfo struct {
+	Agent      string    `json:"agent"`
+	ConfigName string    `json:"config_name"`
+	LastSeen   time.Time `json:"last_seen"`
+	Batches    int       `json:"batches"`
+	Samples    int       `json:"samples"`
+	Containers []string  `json:"containers"`
+}
+
+// Aggregator stores batches pushed by agents in the regular data layout, so
+// view, export and serve work on them like on local data. Containers are
+// stored as "<agent>/<container>" under the agent's config name.
+type Aggregator struct {
+	token  string
+	mu     sync.Mutex
+	agents map[string]*AgentInfo // By "<agent>/<config>"
+}
+
+// NewAggregator creates an aggregator. An empty token accepts any client, so
+// ServeAggregator only allows it on loopback addresses and Unix sockets.
+func NewAggregator(token string) *Aggregator {
+	return &Aggregator{token: token, agents: make(map[string]*AgentInfo)}
+}
+
+// Handler returns the aggregator's HTTP routes
+func (a *Aggregator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/ingest", a.handleIngest)
+	mux.HandleFunc("GET /api/agents", a.handleAgents)
+	return mux
+}
+
+// ServeAggregator receives agent batches on a TCP address or Unix socket until
+// interrupted. It refuses to listen beyond the loopback interface without a
+// token.
+func ServeAggregator(listenAddr, socketPath, token string) error {
+	if token == "" && socketPath == "" && !isLoopbackAddr(listenAddr) {
+		return fmt.Errorf("refusing to listen on %s without a token (set --token or MDOK_AGGREGATOR_TOKEN, or listen on 127.0.0.1)", listenAddr)
+	}
+	return serveHTTP("mdok aggregator", NewAggregator(token).Handler(), listenAddr, socketPath)
+}
+
+// isLoopbackAddr reports whether a TCP listen address only accepts local
+// connections. An empty host listens on every interface.
+func isLoopbackAddr(listenAddr string) bool {
+	host, _, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authorized checks the request's bearer token
+func (a *Aggregator) authorized(r *http.Request) bool {
+	return a.token == "" || validBearerToken(r, a.token)
+}
+
+// validBearerToken checks a request's "Authorization: Bearer" token
+func validBearerToken(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func (a *Aggregator) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(r) {
+		writeAPIError(w, http.StatusUnauthorized, "invalid or missing token")
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid gzip body: %v", err)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+
+	var batch IngestBatch
+	if err := json.NewDecoder(io.LimitReader(body, maxIngestBody)).Decode(&batch); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid batch: %v", err)
+		return
+	}
+	if !validAggregateName(batch.Agent) || !validAggregateName(batch.ConfigName) {
+		writeAPIError(w, http.StatusBadRequest, "invalid agent or config name")
+		return
+	}
+
+	samples, err := a.store(&batch)
+	if errors.Is(err, errLocalConfig) {
+		writeAPIError(w, http.StatusConflict, "%v", err)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"samples": samples})
+}
+
+func (a *Aggregator) handleAgents(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(r) {
+		writeAPIError(w, http.StatusUnauthorized, "invalid or missing token")
+		return
+	}
+
+	a.mu.Lock()
+	agents := make([]AgentInfo, 0, len(a.agents))
+	for _, info := range a.agents {
+		agents = append(agents, *info)
+	}
+	a.mu.Unlock()
+
+	sort.Slice(agents, func(i, j int) bool {
+		if agents[i].ConfigName != agents[j].ConfigName {
+			return agents[i].ConfigName < agents[j].ConfigName
+		}
+		return agents[i].Agent < agents[j].Agent
+	})
+	writeJSON(w, http.StatusOK, agents)
+}
+
+// validAggregateName rejects names that could escape the data directory
+func validAggregateName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\")
+}
+
+// errLocalConfig is returned for batches whose config name belongs to a
+// local configuration, whose data the aggregator must not overwrite
+var errLocalConfig = errors.New("configuration exists locally and is not aggregated")
+
+// store merges a batch into the stored sessions and returns the number of new samples
+func (a *Aggregator) store(batch *IngestBatch) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Check before writing anything into the config's data directory
+	if ConfigExists(batch.ConfigName) {
+		config, err := LoadConfig(batch.ConfigName)
+		if err != nil {
+			return 0, err
+		}
+		if !config.Aggregated {
+			return 0, fmt.Errorf("'%s': %w", batch.ConfigName, errLocalConfig)
+		}
+	}
+
+	var refs []string
+	added := 0
+	for _, delta := range batch.Containers {
+		ref := batch.Agent + "/" + delta.ContainerName
+		refs = append(refs, ref)
+
+		path := filepath.Join(GetDataDir(batch.ConfigName), sanitizeFilename(ref)+".json")
+		data, err := LoadContainerData(path)
+		if err != nil || data.SessionID != delta.SessionID {
+			// A new session replaces the previous one, like a local daemon does
+			data = &ContainerData{SessionID: delta.SessionID, StartTime: delta.StartTime}
+		}
+
+		n := mergeContainerDelta(data, delta, ref)
+		added += n
+
+		if batch.Final && len(data.Samples) > 0 {
+			data.EndTime = batch.SentAt
+			summarizeContainerData(data)
+		} else {
+			data.Summary = nil
+		}
+
+		if err := SaveContainerData(batch.ConfigName, data); err != nil {
+			return added, err
+		}
+	}
+
+	if err := registerAggregatedConfig(batch.ConfigName, refs, batch.Containers); err != nil {
+		return added, err
+	}
+
+	key := batch.Agent + "/" + batch.ConfigName
+	info := a.agents[key]
+	if info == nil {
+		info = &AgentInfo{Agent: batch.Agent, ConfigName: batch.ConfigName}
+		a.agents[key] = info
+	}
+	info.LastSeen = time.Now()
+	info.Batches++
+	info.Samples += added
+	for _, ref := range refs {
+		info.Containers = appendUnique(info.Containers, ref)
+	}
+
+	return added, nil
+}
+
+// mergeContainerDelta applies an agent's metadata and new samples and events
+// to stored data. Samples and events already stored (e.g. from a retried
+// batch) are skipped. Returns the number of samples added.
+func mergeContainerDelta(data, delta *ContainerData, ref string) int {
+	data.ContainerName = ref
+	data.ContainerID = delta.ContainerID
+	data.ImageName = delta.ImageName
+	data.ComposeService = delta.ComposeService
+	data.Endpoint = delta.Endpoint
+	data.Host = delta.Host
+	data.Limits = delta.Limits
+	data.Interval = delta.Interval
+
+	var last time.Time
+	if n := len(data.Samples); n > 0 {
+		last = data.Samples[n-1].Timestamp
+	}
+	added := 0
+	for _, sample := range delta.Samples {
+		if sample.Timestamp.After(last) {
+			data.Samples = append(data.Samples, sample)
+			last = sample.Timestamp
+			added++
+		}
+	}
+
+	var lastEvent time.Time
+	if n := len(data.Events); n > 0 {
+		lastEvent = data.Events[n-1].Time
+	}
+	for _, event := range delta.Events {
+		if event.Time.After(lastEvent) {
+			data.Events = append(data.Events, event)
+			lastEvent = event.Time
+		}
+	}
+
+	if !last.IsZero() {
+		data.EndTime = last
+	}
+	return added
+}
+
+// registerAggregatedConfig creates or extends the config that lets view and
+// export find aggregated data
+func registerAggregatedConfig(configName string, refs []string, containers []*ContainerData) error {
+	config, err := LoadConfig(configName)
+	if err != nil {
+		interval := 5
+		if len(containers) > 0 && containers[0].Interval > 0 {
+			interval = containers[0].Interval
+		}
+		config = Config{
+			Name:       configName,
+			Interval:   interval,
+			CreatedAt:  time.Now().Format(time.RFC3339),
+			Aggregated: true,
+		}
+	} else if !config.Aggregated {
+		return fmt.Errorf("'%s': %w", configName, errLocalConfig)
+	}
+
+	changed := err != nil
+	for _, ref := range refs {
+		if !containsString(config.Containers, ref) {
+			config.Containers = append(config.Containers, ref)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := SaveConfig(config); err != nil {
+		return fmt.Errorf("failed to save aggregated config: %w", err)
+	}
+	return nil
+}
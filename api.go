@@ -0,0 +1,922 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultAPIListenAddr is the address `mdok serve` listens on by default
+const defaultAPIListenAddr = "127.0.0.1:7070"
+
+// maxAPIRequestBody limits the size of JSON request bodies
+const maxAPIRequestBody = 1 << 20
+
+// APIContainerSamples is the samples response for a single container
+type APIContainerSamples struct {
+	ContainerName string   `json:"container_name"`
+	ContainerID   string   `json:"container_id"`
+	SessionID     string   `json:"session_id,omitempty"`
+	Interval      int      `json:"interval_seconds"`
+	Samples       []Sample `json:"samples"`
+}
+
+// APISamplesResponse is returned by the samples endpoint
+type APISamplesResponse struct {
+	ConfigName  string                 `json:"config_name"`
+	SessionID   string                 `json:"session_id,omitempty"`
+	From        time.Time              `json:"from,omitempty"`
+	To          time.Time              `json:"to,omitempty"`
+	StepSeconds float64                `json:"step_seconds,omitempty"` // 0 when samples are not downsampled
+	Containers  []*APIContainerSamples `json:"containers"`
+}
+
+// APIContainerReport is a container's summary, warnings and recommendations
+// for one session, without samples
+type APIContainerReport struct {
+	ContainerName string                    `json:"container_name"`
+	ContainerID   string                    `json:"container_id"`
+	ImageName     string                    `json:"image_name"`
+	SessionID     string                    `json:"session_id,omitempty"`
+	Host          HostInfo                  `json:"host"`
+	Limits        ContainerLimits           `json:"limits"`
+	StartTime     time.Time                 `json:"start_time"`
+	EndTime       time.Time                 `json:"end_time,omitempty"`
+	Summary       *ContainerSummary         `json:"summary,omitempty"`
+	NetworkCost   *NetworkCostEstimate      `json:"network_cost,omitempty"`
+	Instances     []*InstanceRecommendation `json:"instances,omitempty"` // x86 and ARM
+	Fargate       []*FargateRecommendation  `json:"fargate,omitempty"`
+	LimitsAdvice  *LimitRecommendation      `json:"limits_recommendation,omitempty"`
+	Events        []SessionEvent            `json:"events,omitempty"`
+}
+
+// APILiveEvent is sent on the live stream for each container with new samples
+type APILiveEvent struct {
+	ContainerName string   `json:"container_name"`
+	SessionID     string   `json:"session_id,omitempty"`
+	Samples       []Sample `json:"samples"`
+}
+
+// NewAPIHandler returns the HTTP handler for the mdok REST API
+func NewAPIHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/configs", apiListConfigs)
+	mux.HandleFunc("POST /api/configs", apiCreateConfig)
+	mux.HandleFunc("GET /api/configs/{name}", apiGetConfig)
+	mux.HandleFunc("PUT /api/configs/{name}", apiUpdateConfig)
+	mux.HandleFunc("DELETE /api/configs/{name}", apiDeleteConfig)
+
+	mux.HandleFunc("GET /api/daemons", apiListDaemons)
+	mux.HandleFunc("POST /api/configs/{name}/start", apiStartDaemon)
+	mux.HandleFunc("POST /api/configs/{name}/stop", apiStopDaemon)
+
+	mux.HandleFunc("GET /api/configs/{name}/sessions", apiListSessions)
+	mux.HandleFunc("GET /api/configs/{name}/samples", apiGetSamples)
+	mux.HandleFunc("GET /api/configs/{name}/report", apiGetReport)
+	mux.HandleFunc("GET /api/configs/{name}/live", apiLiveSamples)
+
+	// Embedded web dashboard
+	mux.Handle("GET /", webUIHandler())
+
+	return apiRequireJSON(mux)
+}
+
+// apiRequireJSON rejects state-changing requests that aren't JSON. Browsers
+// can't send a cross-origin JSON request without a CORS preflight, which the
+// API never answers, so other sites can't start daemons or edit configs
+// through a visitor's browser.
+func apiRequireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				writeAPIError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiTokenCookie carries the API token for the dashboard, whose live
+// stream (EventSource) can't send an Authorization header
+const apiTokenCookie = "mdok_token"
+
+// ServeAPI serves the REST API on a TCP address or Unix socket until
+// interrupted. Like the aggregator, it refuses to listen beyond the loopback
+// interface without a token.
+func ServeAPI(listenAddr, socketPath, token string) error {
+	handler := NewAPIHandler()
+	if socketPath == "" {
+		if token == "" && !isLoopbackAddr(listenAddr) {
+			return fmt.Errorf("refusing to listen on %s without a token (set --token or MDOK_API_TOKEN, or listen on 127.0.0.1)", listenAddr)
+		}
+		handler = apiCheckHost(listenAddr, token, handler)
+	}
+	if token != "" {
+		handler = apiRequireToken(token, handler)
+	}
+	return serveHTTP("mdok API", handler, listenAddr, socketPath)
+}
+
+// apiCheckHost rejects requests whose Host header isn't a loopback name or
+// the listen host. A page on another site that rebinds its DNS name to
+// 127.0.0.1 is same-origin with the API; its requests still carry that name.
+// Listening on every interface with a token, the Host can be anything: the
+// token is what keeps other sites out.
+func apiCheckHost(listenAddr, token string, next http.Handler) http.Handler {
+	listenHost, _, _ := net.SplitHostPort(listenAddr)
+	anyHost := token != "" && (listenHost == "" || net.ParseIP(listenHost).IsUnspecified())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if !anyHost && !isLoopbackAddr(net.JoinHostPort(host, "0")) && !strings.EqualFold(host, listenHost) {
+			writeAPIError(w, http.StatusForbidden, "unexpected Host %q", r.Host)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiRequireToken requires the token on API routes, as a bearer token or the
+// dashboard's cookie. The dashboard's static files are served without it.
+func apiRequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") && !validBearerToken(r, token) && !validTokenCookie(r, token) {
+			writeAPIError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validTokenCookie checks the dashboard's token cookie
+func validTokenCookie(r *http.Request, token string) bool {
+	cookie, err := r.Cookie(apiTokenCookie)
+	if err != nil {
+		return false
+	}
+	value, err := url.QueryUnescape(cookie.Value) // Set with encodeURIComponent
+	return err == nil && subtle.ConstantTimeCompare([]byte(value), []byte(token)) == 1
+}
+
+// serveHTTP serves a handler on a TCP address or Unix socket until interrupted
+func serveHTTP(name string, handler http.Handler, listenAddr, socketPath string) error {
+	if err := EnsureDirs(); err != nil {
+		return err
+	}
+
+	var listener net.Listener
+	var err error
+	if socketPath != "" {
+		// Remove a stale socket left by a previous run (but never a regular file)
+		if info, err := os.Lstat(socketPath); err == nil {
+			if info.Mode()&os.ModeSocket == 0 {
+				return fmt.Errorf("%s exists and is not a socket", socketPath)
+			}
+			os.Remove(socketPath)
+		}
+		listener, err = net.Listen("unix", socketPath)
+		if err != nil {
+			return fmt.Errorf("failed to listen on socket: %w", err)
+		}
+		defer os.Remove(socketPath)
+		if err := os.Chmod(socketPath, 0600); err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to set socket permissions: %w", err)
+		}
+	} else {
+		listener, err = net.Listen("tcp", listenAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", listenAddr, err)
+		}
+	}
+
+	server := &http.Server{
+		Handler:           apiLogRequests(handler),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Shut down cleanly on SIGINT/SIGTERM
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		<-sigChan
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	log.Printf("%s listening on %s", name, listener.Addr())
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve %s: %w", name, err)
+	}
+	return nil
+}
+
+// apiLogRequests logs each request with its duration
+func apiLogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		log.Printf("%s %s (%s)", r.Method, r.URL.RequestURI(), time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// writeAPIError writes an error response of the form {"error": "..."}
+func writeAPIError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+// validConfigName rejects names that aren't usable as file names or could
+// escape the config and data directories
+func validConfigName(name string) bool {
+	return name != "." && name != ".." && !strings.ContainsAny(name, "/\\:*?\"<>|")
+}
+
+// apiConfigName returns the {name} path value if the configuration exists,
+// otherwise writes a 400 or 404 and returns ""
+func apiConfigName(w http.ResponseWriter, r *http.Request) string {
+	// PathValue decodes %2F, so the name may contain slashes
+	name := r.PathValue("name")
+	if name == "" || !validConfigName(name) {
+		writeAPIError(w, http.StatusBadRequest, "invalid configuration name")
+		return ""
+	}
+	if !ConfigExists(name) {
+		writeAPIError(w, http.StatusNotFound, "configuration '%s' not found", name)
+		return ""
+	}
+	return name
+}
+
+// decodeAPIConfig reads and validates a config from a request body
+func decodeAPIConfig(w http.ResponseWriter, r *http.Request) (Config, bool) {
+	var config Config
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid config: %v", err)
+		return Config{}, false
+	}
+
+	if config.Interval == 0 {
+		config.Interval = 5
+	}
+	if config.Interval < 1 {
+		writeAPIError(w, http.StatusBadRequest, "invalid interval: must be a positive number")
+		return Config{}, false
+	}
+	if len(config.Containers) == 0 {
+		writeAPIError(w, http.StatusBadRequest, "at least one container is required")
+		return Config{}, false
+	}
+	if err := ValidateEndpoints(config); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "%v", err)
+		return Config{}, false
+	}
+	return config, true
+}
+
+func apiListConfigs(w http.ResponseWriter, r *http.Request) {
+	configs, err := ListConfigs()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	if configs == nil {
+		configs = []Config{}
+	}
+	writeJSON(w, http.StatusOK, configs)
+}
+
+func apiCreateConfig(w http.ResponseWriter, r *http.Request) {
+	config, ok := decodeAPIConfig(w, r)
+	if !ok {
+		return
+	}
+
+	if config.Name == "" {
+		writeAPIError(w, http.StatusBadRequest, "configuration name is required")
+		return
+	}
+	if !validConfigName(config.Name) {
+		writeAPIError(w, http.StatusBadRequest, "invalid characters in name")
+		return
+	}
+	if ConfigExists(config.Name) {
+		writeAPIError(w, http.StatusConflict, "configuration '%s' already exists", config.Name)
+		return
+	}
+
+	config.CreatedAt = time.Now().Format(time.RFC3339)
+	if err := SaveConfig(config); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, config)
+}
+
+func apiGetConfig(w http.ResponseWriter, r *http.Request) {
+	name := apiConfigName(w, r)
+	if name == "" {
+		return
+	}
+	config, err := LoadConfig(name)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, config)
+}
+
+func apiUpdateConfig(w http.ResponseWriter, r *http.Request) {
+	name := apiConfigName(w, r)
+	if name == "" {
+		return
+	}
+	existing, err := LoadConfig(name)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	config, ok := decodeAPIConfig(w, r)
+	if !ok {
+		return
+	}
+	if config.Name != "" && config.Name != name {
+		writeAPIError(w, http.StatusBadRequest, "configuration name cannot be changed")
+		return
+	}
+
+	config.Name = name
+	config.CreatedAt = existing.CreatedAt
+	if err := SaveConfig(config); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	// Apply to a running daemon (it would also notice the file change on its own)
+	if ControlAvailable(name) {
+		if _, err := SendControlCommand(name, ControlRequest{Command: ControlReload}); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "config saved but reload failed: %v", err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, config)
+}
+
+func apiDeleteConfig(w http.ResponseWriter, r *http.Request) {
+	name := apiConfigName(w, r)
+	if name == "" {
+		return
+	}
+	if IsRunning(name) {
+		writeAPIError(w, http.StatusConflict, "cannot delete while monitoring is running")
+		return
+	}
+	if err := DeleteConfig(name); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func apiListDaemons(w http.ResponseWriter, r *http.Request) {
+	statuses, err := ListDaemons()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	if statuses == nil {
+		statuses = []DaemonStatus{}
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func apiStartDaemon(w http.ResponseWriter, r *http.Request) {
+	name := apiConfigName(w, r)
+	if name == "" {
+		return
+	}
+	if IsRunning(name) {
+		writeAPIError(w, http.StatusConflict, "monitoring for '%s' is already running", name)
+		return
+	}
+
+	config, err := LoadConfig(name)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	if err := StartDaemon(config); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	pid, _ := ReadPidFile(name)
+	writeJSON(w, http.StatusAccepted, DaemonStatus{
+		ConfigName: name,
+		PID:        pid,
+		StartTime:  time.Now(),
+		Running:    true,
+		Containers: config.Containers,
+	})
+}
+
+func apiStopDaemon(w http.ResponseWriter, r *http.Request) {
+	name := apiConfigName(w, r)
+	if name == "" {
+		return
+	}
+	if !IsRunning(name) {
+		writeAPIError(w, http.StatusConflict, "no running instance found for '%s'", name)
+		return
+	}
+	if err := StopDaemon(name); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func apiListSessions(w http.ResponseWriter, r *http.Request) {
+	name := apiConfigName(w, r)
+	if name == "" {
+		return
+	}
+	sessions, err := GetAllSessions(name)
+	if err != nil {
+		// No data yet is not an error for API consumers
+		sessions = []SessionInfo{}
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// apiGetSamples returns samples for a configuration.
+//
+// Query parameters:
+//
+//	container   limit to one container (repeatable)
+//	session     limit to a session ID ("current" for the most recent)
+//	from, to    RFC3339 time range
+//	last        duration ending now (e.g., 1h, 30m)
+//	step        downsample into buckets of this duration (e.g., 1m)
+//	max_points  downsample to at most this many samples per container
+func apiGetSamples(w http.ResponseWriter, r *http.Request) {
+	name := apiConfigName(w, r)
+	if name == "" {
+		return
+	}
+	query := r.URL.Query()
+
+	opts := ExportOptions{Last: query.Get("last")}
+	if opts.Last != "" {
+		if _, err := parseDuration(opts.Last); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid last: %v", err)
+			return
+		}
+	}
+	for _, param := range []struct {
+		key string
+		dst *time.Time
+	}{{"from", &opts.From}, {"to", &opts.To}} {
+		if v := query.Get(param.key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeAPIError(w, http.StatusBadRequest, "invalid %s: %v", param.key, err)
+				return
+			}
+			*param.dst = t
+		}
+	}
+
+	var step time.Duration
+	if v := query.Get("step"); v != "" {
+		d, err := parseDuration(v)
+		if err != nil || d <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "invalid step: %s", v)
+			return
+		}
+		step = d
+	}
+	maxPoints := 0
+	if v := query.Get("max_points"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, "invalid max_points: %s", v)
+			return
+		}
+		maxPoints = n
+	}
+
+	sessionID := query.Get("session")
+	var allData []*ContainerData
+	var err error
+	if sessionID != "" {
+		if sessionID == "current" {
+			sessionID = ""
+		}
+		allData, err = LoadSessionContainerData(name, sessionID)
+	} else {
+		allData, err = LoadAllContainerData(name)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	allData = filterDataByTime(allData, opts)
+
+	containers := query["container"]
+	response := APISamplesResponse{
+		ConfigName: name,
+		SessionID:  query.Get("session"),
+		From:       opts.From,
+		To:         opts.To,
+		Containers: []*APIContainerSamples{},
+	}
+	for _, data := range allData {
+		if data == nil || (len(containers) > 0 && !containsString(containers, data.ContainerName)) {
+			continue
+		}
+
+		samples := data.Samples
+		bucket := step
+		if bucket == 0 && maxPoints > 0 && len(samples) > maxPoints {
+			span := samples[len(samples)-1].Timestamp.Sub(samples[0].Timestamp)
+			bucket = (span / time.Duration(maxPoints)).Truncate(time.Second) + time.Second
+		}
+		if bucket > 0 {
+			samples = DownsampleSamples(samples, bucket)
+			response.StepSeconds = bucket.Seconds()
+		}
+		if samples == nil {
+			samples = []Sample{}
+		}
+
+		response.Containers = append(response.Containers, &APIContainerSamples{
+			ContainerName: data.ContainerName,
+			ContainerID:   data.ContainerID,
+			SessionID:     data.SessionID,
+			Interval:      data.Interval,
+			Samples:       samples,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// apiGetReport returns summaries, warnings and recommendations for a session
+// ("session" query parameter, default: the most recent session)
+func apiGetReport(w http.ResponseWriter, r *http.Request) {
+	name := apiConfigName(w, r)
+	if name == "" {
+		return
+	}
+
+	allData, err := LoadSessionContainerData(name, r.URL.Query().Get("session"))
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	policy, _ := GetHeadroomPolicy(defaultHeadroomPolicy)
+	reports := []*APIContainerReport{}
+	for _, data := range allData {
+		if data == nil || len(data.Samples) == 0 {
+			continue
+		}
+
+		report := &APIContainerReport{
+			ContainerName: data.ContainerName,
+			ContainerID:   data.ContainerID,
+			ImageName:     data.ImageName,
+			SessionID:     data.SessionID,
+			Host:          data.Host,
+			Limits:        data.Limits,
+			StartTime:     data.StartTime,
+			EndTime:       data.EndTime,
+			Summary:       data.Summary,
+			NetworkCost:   data.NetworkCost,
+			Fargate:       data.Fargate,
+			Events:        data.Events,
+		}
+		if data.Summary != nil {
+			if report.NetworkCost == nil {
+				report.NetworkCost = CalculateNetworkCost(data)
+			}
+			x86Rec, armRec := RecommendBothArchitectures(data.Summary, data.Host)
+			for _, rec := range []*InstanceRecommendation{x86Rec, armRec} {
+				if rec != nil {
+					report.Instances = append(report.Instances, rec)
+				}
+			}
+			if len(report.Fargate) == 0 {
+				report.Fargate = RecommendFargateBothArchitectures(data.Summary, data.Host)
+			}
+			report.LimitsAdvice = RecommendLimits(data.Summary, data.Limits, policy)
+		}
+		reports = append(reports, report)
+	}
+
+	writeJSON(w, http.StatusOK, reports)
+}
+
+// apiLiveSamples streams new samples from the current session as server-sent
+// events. The daemon saves data after every collection, so the data files are
+// polled once per interval. A "samples" event is sent per container with new
+// samples and a "status" event reports whether the daemon is still running.
+// The optional "since" parameter (RFC3339) replays samples after that time.
+func apiLiveSamples(w http.ResponseWriter, r *http.Request) {
+	name := apiConfigName(w, r)
+	if name == "" {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	config, err := LoadConfig(name)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	// Without "since", only samples collected after connecting are sent
+	since := time.Now()
+	if v := r.URL.Query().Get("since"); v != "" {
+		since, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid since: %v", err)
+			return
+		}
+	}
+	lastSent := make(map[string]time.Time)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, v interface{}) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	}
+
+	poll := func() {
+		allData, err := LoadAllContainerData(name)
+		if err == nil {
+			for _, data := range allData {
+				data = filterToCurrentSession(data)
+				after, ok := lastSent[data.ContainerName]
+				if !ok {
+					after = since
+				}
+
+				var fresh []Sample
+				for _, s := range data.Samples {
+					if s.Timestamp.After(after) {
+						fresh = append(fresh, s)
+					}
+				}
+				if len(fresh) == 0 {
+					continue
+				}
+				lastSent[data.ContainerName] = fresh[len(fresh)-1].Timestamp
+				send("samples", APILiveEvent{
+					ContainerName: data.ContainerName,
+					SessionID:     data.SessionID,
+					Samples:       fresh,
+				})
+			}
+		}
+		send("status", map[string]interface{}{"config_name": name, "running": IsRunning(name)})
+		flusher.Flush()
+	}
+
+	interval := time.Duration(config.Interval) * time.Second
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	poll()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			poll()
+		}
+	}
+}
+
+// averageZoneBandwidth averages the rates by zone of the samples that have
+// them, counting samples without a zone as 0
+func averageZoneBandwidth(samples []Sample) map[string]ZoneBandwidth {
+	var sum map[string]ZoneBandwidth
+	n := 0
+	for _, s := range samples {
+		if s.NetZoneBandwidth == nil {
+			continue
+		}
+		if sum == nil {
+			sum = make(map[string]ZoneBandwidth)
+		}
+		n++
+		for zone, b := range s.NetZoneBandwidth {
+			total := sum[zone]
+			total.Tx += b.Tx
+			total.Rx += b.Rx
+			sum[zone] = total
+		}
+	}
+	for zone, total := range sum {
+		sum[zone] = ZoneBandwidth{Tx: total.Tx / float64(n), Rx: total.Rx / float64(n)}
+	}
+	return sum
+}
+
+// sumZoneTraffic adds up the bytes by zone of samples, which count the
+// traffic since the previous sample. Connection counts are those of the
+// last sample.
+func sumZoneTraffic(samples []Sample) map[string]ZoneTraffic {
+	var sum map[string]ZoneTraffic
+	for _, s := range samples {
+		for zone, t := range s.NetZones {
+			if sum == nil {
+				sum = make(map[string]ZoneTraffic)
+			}
+			total := sum[zone]
+			total.TxBytes += t.TxBytes
+			total.RxBytes += t.RxBytes
+			sum[zone] = total
+		}
+	}
+	last := samples[len(samples)-1].NetZones
+	for zone, total := range sum {
+		total.Conns = last[zone].Conns
+		total.UDP = last[zone].UDP
+		sum[zone] = total
+	}
+	return sum
+}
+
+// sumPeerTraffic adds up the bytes by peer of samples, which count the
+// traffic since the previous sample. Connection counts are those of the last
+// sample.
+func sumPeerTraffic(samples []Sample) []PeerTraffic {
+	type key struct {
+		container, zone string
+		incoming        bool
+	}
+	sum := make(map[key]PeerTraffic)
+	for i, s := range samples {
+		last := i == len(samples)-1
+		for _, p := range s.NetPeers {
+			k := key{p.Container, p.Zone, p.Incoming}
+			total, ok := sum[k]
+			if !ok {
+				total = PeerTraffic{Container: p.Container, Zone: p.Zone, Incoming: p.Incoming}
+			}
+			total.Bytes += p.Bytes
+			if last {
+				total.Connections = p.Connections
+			}
+			sum[k] = total
+		}
+	}
+	if len(sum) == 0 {
+		return nil
+	}
+	list := slices.Collect(maps.Values(sum))
+	slices.SortFunc(list, comparePeerTraffic)
+	return list
+}
+
+// sumBytesByName adds up per-sample byte counts by container name
+func sumBytesByName(samples []Sample, field func(Sample) map[string]uint64) map[string]uint64 {
+	var sum map[string]uint64
+	for _, s := range samples {
+		for name, n := range field(s) {
+			if sum == nil {
+				sum = make(map[string]uint64)
+			}
+			sum[name] += n
+		}
+	}
+	return sum
+}
+
+// DownsampleSamples groups samples into fixed time buckets. Gauges and rates
+// are averaged, cumulative counters keep the last value in the bucket,
+// traffic counted since the previous sample is summed, and the bucket's
+// timestamp is that of its first sample.
+func DownsampleSamples(samples []Sample, step time.Duration) []Sample {
+	if step <= 0 || len(samples) == 0 {
+		return samples
+	}
+
+	var result []Sample
+	var bucket []Sample
+	flush := func() {
+		if len(bucket) == 0 {
+			return
+		}
+		n := float64(len(bucket))
+		out := bucket[len(bucket)-1] // counters and labels from the last sample
+		out.Timestamp = bucket[0].Timestamp
+
+		var cpu, memPct, rxRate, txRate, readRate, writeRate float64
+		var mem, cache, pids float64
+		for _, s := range bucket {
+			cpu += s.CPUPercent
+			memPct += s.MemoryPercent
+			rxRate += s.NetRxRate
+			txRate += s.NetTxRate
+			readRate += s.BlockReadRate
+			writeRate += s.BlockWriteRate
+			mem += float64(s.MemoryUsage)
+			cache += float64(s.MemoryCache)
+			pids += float64(s.PidsCount)
+		}
+		out.CPUPercent = cpu / n
+		out.MemoryPercent = memPct / n
+		out.NetRxRate = rxRate / n
+		out.NetTxRate = txRate / n
+		out.BlockReadRate = readRate / n
+		out.BlockWriteRate = writeRate / n
+		out.MemoryUsage = uint64(mem / n)
+		out.MemoryCache = uint64(cache / n)
+		out.PidsCount = uint64(pids/n + 0.5)
+		out.NetZoneBandwidth = averageZoneBandwidth(bucket)
+		out.NetZones = sumZoneTraffic(bucket)
+		out.NetCallers = sumBytesByName(bucket, func(s Sample) map[string]uint64 { return s.NetCallers })
+		out.NetViaProxy = sumBytesByName(bucket, func(s Sample) map[string]uint64 { return s.NetViaProxy })
+		out.NetPeers = sumPeerTraffic(bucket)
+
+		result = append(result, out)
+		bucket = bucket[:0]
+	}
+
+	bucketStart := samples[0].Timestamp
+	for _, s := range samples {
+		if s.Timestamp.Sub(bucketStart) >= step {
+			flush()
+			// Align to the step grid relative to the first sample
+			bucketStart = bucketStart.Add(s.Timestamp.Sub(bucketStart) / step * step)
+		}
+		bucket = append(bucket, s)
+	}
+	flush()
+
+	return result
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
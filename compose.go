@@ -0,0 +1,466 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const composeServiceLabel = "com.docker.compose.service"
+
+// ComposeChange describes the limits written to a single compose service
+type ComposeChange struct {
+	Service    string
+	Containers []string
+	Limits     *LimitRecommendation
+}
+
+// ApplyComposeLimits writes limits into the deploy.resources section of each
+// service in a compose file. The file is edited in place: existing values
+// are replaced where they stand and missing keys are inserted at the end of
+// their section, so everything else, comments and formatting included, is
+// kept byte for byte. It returns the updated file contents and the services
+// that were changed.
+func ApplyComposeLimits(original []byte, recs map[string]*ComposeChange) ([]byte, []string, error) {
+	names, err := ComposeServiceNames(original)
+	if err != nil {
+		return nil, nil, err
+	}
+	if names == nil {
+		return nil, nil, fmt.Errorf("compose file has no services section")
+	}
+
+	text := string(original)
+	indent := detectYAMLIndent(original)
+	var updated []string
+	for _, name := range names {
+		change, ok := recs[name]
+		if !ok || change.Limits == nil {
+			continue
+		}
+
+		rec := change.Limits
+		values := []struct {
+			path  []string
+			value string
+			style yaml.Style
+		}{
+			{[]string{"limits", "cpus"}, formatCores(rec.CPUs), yaml.DoubleQuotedStyle},
+			{[]string{"limits", "memory"}, formatComposeMemory(rec.MemoryLimit), 0},
+			{[]string{"limits", "pids"}, strconv.FormatInt(rec.PidsLimit, 10), 0},
+			{[]string{"reservations", "cpus"}, formatCores(rec.CPURequest), yaml.DoubleQuotedStyle},
+			{[]string{"reservations", "memory"}, formatComposeMemory(rec.MemoryReservation), 0},
+		}
+		for _, v := range values {
+			path := append([]string{"deploy", "resources"}, v.path...)
+			text, err = setComposeValue(text, name, path, v.value, v.style, indent)
+			if err != nil {
+				return nil, nil, fmt.Errorf("service %s: %w", name, err)
+			}
+		}
+		updated = append(updated, name)
+	}
+
+	var check yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &check); err != nil {
+		return nil, nil, fmt.Errorf("failed to update compose file: %w", err)
+	}
+	return []byte(text), updated, nil
+}
+
+// setComposeValue sets the scalar at path under a service in the text of a
+// compose file. Block mappings on the way that don't exist yet are created
+// at the end of their parent's block, indented by indent spaces per level.
+func setComposeValue(text, service string, path []string, value string, style yaml.Style, indent int) (string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+		return "", fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return "", fmt.Errorf("compose file is not a YAML mapping")
+	}
+	services := yamlMappingValue(doc.Content[0], "services")
+	key, node := yamlMappingEntry(services, service)
+	if node == nil {
+		return "", fmt.Errorf("not found in the compose file")
+	}
+
+	lines := strings.Split(text, "\n")
+	eol := ""
+	if strings.Contains(text, "\r\n") {
+		eol = "\r"
+	}
+	for i, name := range path {
+		switch {
+		case yamlIsBlockMapping(node):
+			childKey, child := yamlMappingEntry(node, name)
+			if child == nil {
+				// Append to the end of the existing block
+				at := yamlBlockEnd(lines, node.Line-1, node.Column-1)
+				added := composeBlock(path[i:], value, style, node.Column-1, indent, eol)
+				lines = slices.Insert(lines, at+1, added...)
+				return strings.Join(lines, "\n"), nil
+			}
+			key, node = childKey, child
+
+		case yamlIsEmpty(node):
+			// "deploy:", "deploy: ~" or "deploy: {}" - the block goes below
+			line, err := stripInlineValue(lines[key.Line-1], key.Column-1)
+			if err != nil {
+				return "", err
+			}
+			lines[key.Line-1] = line
+			added := composeBlock(path[i:], value, style, key.Column-1+indent, indent, eol)
+			lines = slices.Insert(lines, key.Line, added...)
+			return strings.Join(lines, "\n"), nil
+
+		default:
+			return "", fmt.Errorf("%s is not a block mapping; edit it by hand", strings.Join(path[:i], "."))
+		}
+	}
+
+	// The value exists: replace it where it stands
+	if node.Kind != yaml.ScalarNode || node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return "", fmt.Errorf("%s is not a single-line value; edit it by hand", strings.Join(path, "."))
+	}
+	line := lines[key.Line-1]
+	if yamlIsEmpty(node) {
+		stripped, err := stripInlineValue(line, key.Column-1)
+		if err != nil {
+			return "", err
+		}
+		head, comment, _ := strings.Cut(strings.TrimSuffix(stripped, "\r"), " #")
+		line = head + " " + quoteComposeValue(value, style)
+		if comment != "" {
+			line += " #" + comment
+		}
+		lines[key.Line-1] = line + eol
+		return strings.Join(lines, "\n"), nil
+	}
+	if node.Line != key.Line {
+		return "", fmt.Errorf("%s is not on the same line as its key; edit it by hand", strings.Join(path, "."))
+	}
+	start := node.Column - 1
+	end := start + scalarLength(line[start:])
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		style = node.Style
+	}
+	lines[key.Line-1] = line[:start] + quoteComposeValue(value, style) + line[end:]
+	return strings.Join(lines, "\n"), nil
+}
+
+// yamlMappingValue returns the value node for key in a mapping node
+func yamlMappingValue(m *yaml.Node, key string) *yaml.Node {
+	_, value := yamlMappingEntry(m, key)
+	return value
+}
+
+// yamlMappingEntry returns the key and value nodes for key in a mapping node
+func yamlMappingEntry(m *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i], m.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// yamlIsBlockMapping reports whether a node is a mapping written in block style
+func yamlIsBlockMapping(n *yaml.Node) bool {
+	return n.Kind == yaml.MappingNode && n.Style&yaml.FlowStyle == 0 && len(n.Content) > 0
+}
+
+// yamlIsEmpty reports whether a node is null or an empty flow mapping
+func yamlIsEmpty(n *yaml.Node) bool {
+	if n.Kind == yaml.ScalarNode {
+		return n.Tag == "!!null"
+	}
+	return n.Kind == yaml.MappingNode && len(n.Content) == 0
+}
+
+// yamlBlockEnd returns the index of the last content line of the block whose
+// keys start at line first with the given indentation. Comments and blank
+// lines after it are left outside the block.
+func yamlBlockEnd(lines []string, first, indent int) int {
+	last := first
+	for i := first + 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if len(lines[i])-len(strings.TrimLeft(lines[i], " ")) < indent {
+			break
+		}
+		last = i
+	}
+	return last
+}
+
+// composeBlock renders the mappings along path down to a scalar, as lines
+// starting at the given indentation
+func composeBlock(path []string, value string, style yaml.Style, at, indent int, eol string) []string {
+	var lines []string
+	for i, name := range path {
+		line := strings.Repeat(" ", at+i*indent) + name + ":"
+		if i == len(path)-1 {
+			line += " " + quoteComposeValue(value, style)
+		}
+		lines = append(lines, line+eol)
+	}
+	return lines
+}
+
+// stripInlineValue removes an empty value ("~", "null" or "{}") from a key's
+// line, keeping any comment
+func stripInlineValue(line string, keyColumn int) (string, error) {
+	colon := keyColumn + scalarLength(line[keyColumn:])
+	colon += strings.Index(line[colon:], ":")
+	if colon < keyColumn {
+		return "", fmt.Errorf("failed to find the key on line %q", line)
+	}
+	rest := strings.TrimSuffix(line[colon+1:], "\r")
+	result := line[:colon+1]
+	if i := strings.Index(rest, "#"); i >= 0 {
+		result += " " + rest[i:]
+	}
+	if strings.HasSuffix(line, "\r") {
+		result += "\r"
+	}
+	return result, nil
+}
+
+// scalarLength returns the length of the scalar a line starts with: up to
+// the closing quote for quoted scalars, else up to a comment, a ": " or the
+// end of the line, without trailing spaces
+func scalarLength(s string) int {
+	if s == "" {
+		return 0
+	}
+	switch s[0] {
+	case '"':
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == '"' {
+				return i + 1
+			}
+		}
+		return len(s)
+	case '\'':
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+		return len(s)
+	}
+	end := len(strings.TrimRight(s, " \r"))
+	if i := strings.Index(s, " #"); i >= 0 && i < end {
+		end = len(strings.TrimRight(s[:i], " "))
+	}
+	if i := strings.Index(s, ": "); i >= 0 && i < end {
+		end = i
+	}
+	if strings.HasSuffix(s[:end], ":") {
+		end--
+	}
+	return end
+}
+
+// quoteComposeValue writes a value in a YAML scalar style
+func quoteComposeValue(value string, style yaml.Style) string {
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		return strconv.Quote(value)
+	case style&yaml.SingleQuotedStyle != 0:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	return value
+}
+
+// detectYAMLIndent returns the indentation width used by a YAML document
+func detectYAMLIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		if indent := len(line) - len(trimmed); indent > 0 {
+			return indent
+		}
+	}
+	return 2
+}
+
+// WriteFilePreservingMode overwrites a file while keeping its permissions
+func WriteFilePreservingMode(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return os.WriteFile(path, data, mode)
+}
+
+// UnifiedDiff returns a unified diff between two texts with 3 lines of context
+func UnifiedDiff(fromName, toName string, a, b []byte) string {
+	aLines := splitLines(string(a))
+	bLines := splitLines(string(b))
+
+	// Longest common subsequence table
+	n, m := len(aLines), len(bLines)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if aLines[i] == bLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type diffOp struct {
+		kind byte // ' ', '-', '+'
+		text string
+		a, b int // line indices in a and b
+	}
+	var ops []diffOp
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && aLines[i] == bLines[j]:
+			ops = append(ops, diffOp{' ', aLines[i], i, j})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', aLines[i], i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', bLines[j], i, j})
+			j++
+		}
+	}
+
+	const context = 3
+	var out strings.Builder
+	for start := 0; start < len(ops); {
+		// Find next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start >= len(ops) {
+			break
+		}
+
+		// Extend hunk while changes are within 2*context lines of each other
+		end := start
+		for k := start; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				end = k
+			} else if k-end > 2*context {
+				break
+			}
+		}
+
+		hunkStart := start - context
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		hunkEnd := end + context + 1
+		if hunkEnd > len(ops) {
+			hunkEnd = len(ops)
+		}
+
+		if out.Len() == 0 {
+			out.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName))
+		}
+
+		var aCount, bCount int
+		for _, op := range ops[hunkStart:hunkEnd] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+		out.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n",
+			ops[hunkStart].a+1, aCount, ops[hunkStart].b+1, bCount))
+		for _, op := range ops[hunkStart:hunkEnd] {
+			out.WriteString(string(op.kind) + op.text + "\n")
+		}
+
+		start = hunkEnd
+	}
+
+	return out.String()
+}
+
+// splitLines splits text into lines without the trailing empty line
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// ComposeServiceNames returns the service names defined in a compose file
+func ComposeServiceNames(data []byte) ([]string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	services := yamlMappingValue(doc.Content[0], "services")
+	if services == nil {
+		return nil, nil
+	}
+
+	var names []string
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		names = append(names, services.Content[i].Value)
+	}
+	return names, nil
+}
+
+// guessComposeService matches a container name against compose service names,
+// following compose's "<project>-<service>-<n>" (or "_" separated) naming
+func guessComposeService(containerName string, services []string) string {
+	best := ""
+	for _, service := range services {
+		if containerName == service {
+			return service
+		}
+		for _, sep := range []string{"-", "_"} {
+			trimmed := containerName
+			if idx := strings.LastIndex(trimmed, sep); idx > 0 {
+				if _, err := strconv.Atoi(trimmed[idx+1:]); err == nil {
+					trimmed = trimmed[:idx]
+				}
+			}
+			if strings.HasSuffix(trimmed, sep+service) && len(service) > len(best) {
+				best = service
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,650 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// envNerdctl overrides the nerdctl binary used by the containerd runtime
+const envNerdctl = "MDOK_NERDCTL"
+
+// containerdCacheTTL is how long inspect output is reused, so that one
+// collection round inspects each container once instead of once per call
+const containerdCacheTTL = 500 * time.Millisecond
+
+// Default cgroup v2 values that mean "not set"
+const (
+	cgroupDefaultCPUWeight = 100
+	cgroupDefaultPeriod    = 100000
+)
+
+// ContainerdClient monitors containerd containers. Containers are listed,
+// inspected and exec'd into through nerdctl, which talks to the containerd
+// API; stats are read from the containers' cgroups and network namespaces.
+// The containerd namespace comes from $CONTAINERD_NAMESPACE like for nerdctl.
+// Inspect output is cached briefly, as every nerdctl call spawns a process.
+type ContainerdClient struct {
+	binary     string
+	mu         sync.Mutex
+	inspected  map[string]inspectedContainer // By the name or ID it was inspected as
+	networksMu sync.Mutex                    // Held while listing, so concurrent callers share one listing
+	networks   []NetworkContainer
+	networksAt time.Time
+}
+
+// inspectedContainer is a cached inspect result
+type inspectedContainer struct {
+	container nerdctlContainer
+	at        time.Time
+}
+
+// nerdctlContainer is the part of `nerdctl container inspect --mode=dockercompat` mdok uses
+type nerdctlContainer struct {
+	ID    string `json:"Id"`
+	Name  string `json:"Name"`
+	Image string `json:"Image"`
+	State struct {
+		Running bool `json:"Running"`
+		Pid     int  `json:"Pid"`
+	} `json:"State"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress         string `json:"IPAddress"`
+			GlobalIPv6Address string `json:"GlobalIPv6Address"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// image returns the container's image name
+func (c nerdctlContainer) image() string {
+	if c.Config.Image != "" {
+		return c.Config.Image
+	}
+	return c.Image
+}
+
+// nerdctlNetworksLabel lists the networks a container was started with, as
+// a JSON array, in nerdctl versions whose inspect output has no network mode
+const nerdctlNetworksLabel = "nerdctl/networks"
+
+// networkMode returns "host" or "container:<name or ID>" for containers
+// without a network namespace of their own
+func (c nerdctlContainer) networkMode() string {
+	if mode := c.HostConfig.NetworkMode; mode == NetworkModeHost || strings.HasPrefix(mode, networkModeContainer) {
+		return mode
+	}
+	var networks []string
+	if json.Unmarshal([]byte(c.Config.Labels[nerdctlNetworksLabel]), &networks) == nil && len(networks) == 1 {
+		if mode := networks[0]; mode == NetworkModeHost || strings.HasPrefix(mode, networkModeContainer) {
+			return mode
+		}
+	}
+	return ""
+}
+
+// NewContainerdClient finds nerdctl for talking to containerd
+func NewContainerdClient() (*ContainerdClient, error) {
+	binary, err := exec.LookPath(envOrDefault(envNerdctl, "nerdctl"))
+	if err != nil {
+		return nil, fmt.Errorf("the containerd runtime needs nerdctl: %w", err)
+	}
+	return &ContainerdClient{binary: binary, inspected: make(map[string]inspectedContainer)}, nil
+}
+
+// Close is a no-op: every call runs its own nerdctl process
+func (c *ContainerdClient) Close() error {
+	return nil
+}
+
+// run runs nerdctl and returns its output. Errors include what nerdctl printed.
+func (c *ContainerdClient) run(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, c.binary, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return output, fmt.Errorf("nerdctl %s: %s", args[0], msg)
+		}
+		return output, fmt.Errorf("nerdctl %s: %w", args[0], err)
+	}
+	return output, nil
+}
+
+// inspect returns the Docker-compatible inspect output of containers
+func (c *ContainerdClient) inspect(ctx context.Context, ids ...string) ([]nerdctlContainer, error) {
+	args := append([]string{"container", "inspect", "--mode=dockercompat"}, ids...)
+	output, err := c.run(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	var containers []nerdctlContainer
+	if err := json.Unmarshal(output, &containers); err != nil {
+		return nil, fmt.Errorf("failed to decode inspect output: %w", err)
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("container not found: %s", strings.Join(ids, ", "))
+	}
+	return containers, nil
+}
+
+// inspectCached returns the inspect output of one container, reusing output
+// up to containerdCacheTTL old
+func (c *ContainerdClient) inspectCached(ctx context.Context, nameOrID string) (nerdctlContainer, error) {
+	c.mu.Lock()
+	cached, ok := c.inspected[nameOrID]
+	c.mu.Unlock()
+	if ok && time.Since(cached.at) < containerdCacheTTL {
+		return cached.container, nil
+	}
+
+	containers, err := c.inspect(ctx, nameOrID)
+	if err != nil {
+		return nerdctlContainer{}, err
+	}
+	c.cacheInspected(map[string]nerdctlContainer{nameOrID: containers[0]})
+	return containers[0], nil
+}
+
+// cacheInspected stores inspect output and drops expired entries
+func (c *ContainerdClient) cacheInspected(containers map[string]nerdctlContainer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, cached := range c.inspected {
+		if now.Sub(cached.at) >= containerdCacheTTL {
+			delete(c.inspected, key)
+		}
+	}
+	for key, container := range containers {
+		c.inspected[key] = inspectedContainer{container: container, at: now}
+	}
+}
+
+// runningIDs returns the full IDs of running containers
+func (c *ContainerdClient) runningIDs(ctx context.Context) ([]string, error) {
+	output, err := c.run(ctx, "ps", "-q", "--no-trunc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// ListContainers returns a list of running containers, excluding mdok's own
+// container when it runs in one
+func (c *ContainerdClient) ListContainers(ctx context.Context) ([]ContainerInfo, error) {
+	output, err := c.run(ctx, "ps", "--no-trunc", "--format", "{{json .}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	var result []ContainerInfo
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		var c struct {
+			ID        string
+			Names     string
+			Image     string
+			Status    string
+			CreatedAt string
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil || len(c.ID) < 12 {
+			continue
+		}
+		if hostPaths.IsSelf(c.ID) {
+			continue
+		}
+		created, _ := time.Parse("2006-01-02 15:04:05 -0700 MST", c.CreatedAt)
+		result = append(result, ContainerInfo{
+			ID:      c.ID[:12],
+			Name:    c.Names,
+			Image:   c.Image,
+			Status:  c.Status,
+			Created: created,
+		})
+	}
+	return result, nil
+}
+
+// GetContainerFullID returns the full container ID from a short ID or name
+func (c *ContainerdClient) GetContainerFullID(ctx context.Context, nameOrID string) (string, error) {
+	container, err := c.inspectCached(ctx, nameOrID)
+	if err != nil {
+		return "", err
+	}
+	return container.ID, nil
+}
+
+// GetContainerImage returns the image name for a container
+func (c *ContainerdClient) GetContainerImage(ctx context.Context, containerID string) (string, error) {
+	container, err := c.inspectCached(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	return container.image(), nil
+}
+
+// GetContainerLabels returns the labels set on a container
+func (c *ContainerdClient) GetContainerLabels(ctx context.Context, containerID string) (map[string]string, error) {
+	container, err := c.inspectCached(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	return container.Config.Labels, nil
+}
+
+// IsContainerRunning checks if a container is still running
+func (c *ContainerdClient) IsContainerRunning(ctx context.Context, containerID string) (bool, error) {
+	container, err := c.inspectCached(ctx, containerID)
+	if err != nil {
+		return false, err
+	}
+	return container.State.Running, nil
+}
+
+// ContainerPID returns the host PID of a container's init process
+func (c *ContainerdClient) ContainerPID(ctx context.Context, containerID string) (int, error) {
+	container, err := c.inspectCached(ctx, containerID)
+	if err != nil {
+		return 0, err
+	}
+	if !container.State.Running || container.State.Pid == 0 {
+		return 0, fmt.Errorf("container %s is not running", containerID)
+	}
+	return container.State.Pid, nil
+}
+
+// cgroupDir returns the host path of a running container's cgroup v2 directory
+func (c *ContainerdClient) cgroupDir(ctx context.Context, containerID string) (string, int, error) {
+	container, err := c.inspectCached(ctx, containerID)
+	if err != nil {
+		return "", 0, err
+	}
+	pid := container.State.Pid
+	if !container.State.Running || pid == 0 {
+		return "", 0, fmt.Errorf("container %s is not running", containerID)
+	}
+
+	data, err := os.ReadFile(hostPaths.ProcPath(strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read cgroup of PID %d: %w", pid, err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return hostPaths.CgroupPath(path), pid, nil
+		}
+	}
+	return "", 0, fmt.Errorf("no cgroup v2 hierarchy for PID %d (cgroup v1 hosts are not supported by the containerd runtime)", pid)
+}
+
+// GetContainerLimits reads resource limits from a container's cgroup
+func (c *ContainerdClient) GetContainerLimits(ctx context.Context, containerID string) (ContainerLimits, error) {
+	dir, _, err := c.cgroupDir(ctx, containerID)
+	if err != nil {
+		return ContainerLimits{}, err
+	}
+
+	var limits ContainerLimits
+	if fields := strings.Fields(readCgroupString(dir, "cpu.max")); len(fields) == 2 && fields[0] != "max" {
+		limits.CPUQuota, _ = strconv.ParseInt(fields[0], 10, 64)
+		limits.CPUPeriod, _ = strconv.ParseInt(fields[1], 10, 64)
+	}
+	// cpu.weight back to Docker's shares (the inverse of runc's conversion)
+	if weight, ok := readCgroupUint(dir, "cpu.weight"); ok && weight != cgroupDefaultCPUWeight {
+		limits.CPUShares = int64(2 + (weight-1)*262142/9999)
+	}
+	limits.MemLimit, _ = readCgroupUint(dir, "memory.max")
+	limits.MemReservation, _ = readCgroupUint(dir, "memory.low")
+	if limits.MemLimit > 0 {
+		// Docker's memory-swap is memory plus swap, -1 for unlimited swap
+		if swap, ok := readCgroupUint(dir, "memory.swap.max"); ok {
+			limits.MemSwap = int64(limits.MemLimit + swap)
+		} else {
+			limits.MemSwap = -1
+		}
+	}
+	if pids, ok := readCgroupUint(dir, "pids.max"); ok {
+		limits.PidsLimit = int64(pids)
+	}
+	return limits, nil
+}
+
+// GetHostInfo retrieves host system information from containerd and the host's /proc
+func (c *ContainerdClient) GetHostInfo(ctx context.Context) (HostInfo, error) {
+	output, err := c.run(ctx, "info", "--format", "{{json .}}")
+	if err != nil {
+		return HostInfo{}, fmt.Errorf("failed to get containerd info: %w", err)
+	}
+	var info struct {
+		Name            string
+		NCPU            int
+		MemTotal        int64
+		Architecture    string
+		OperatingSystem string
+		KernelVersion   string
+		ServerVersion   string
+	}
+	if err := json.Unmarshal(output, &info); err != nil {
+		return HostInfo{}, fmt.Errorf("failed to decode containerd info: %w", err)
+	}
+
+	version := info.ServerVersion
+	if output, err := c.run(ctx, "version", "--format", "{{json .}}"); err == nil {
+		var v struct {
+			Server *struct {
+				Components []struct {
+					Name    string
+					Version string
+				}
+			}
+		}
+		if json.Unmarshal(output, &v) == nil && v.Server != nil {
+			for _, component := range v.Server.Components {
+				if component.Name == "containerd" {
+					version = component.Version
+				}
+			}
+		}
+	}
+
+	host := hostInfoFromProc()
+	host.Hostname = info.Name
+	host.OS = info.OperatingSystem
+	host.DockerVer = strings.TrimPrefix(version, "v")
+	host.Runtime = RuntimeContainerd
+	if info.NCPU > 0 {
+		host.CPUCores = info.NCPU
+	}
+	if info.MemTotal > 0 {
+		host.MemoryTotal = uint64(info.MemTotal)
+	}
+	if info.Architecture != "" {
+		host.Architecture = info.Architecture
+	}
+	if info.KernelVersion != "" {
+		host.KernelVer = info.KernelVersion
+	}
+	return host, nil
+}
+
+// CollectStats collects a single stats sample from a container's cgroup and
+// network namespace
+func (c *ContainerdClient) CollectStats(ctx context.Context, containerID string, prev *StatsResult) (*StatsResult, error) {
+	dir, pid, err := c.cgroupDir(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container stats: %w", err)
+	}
+
+	return readCgroupStats(dir, pid, prev), nil
+}
+
+// readCgroupStats reads a sample from a cgroup v2 directory, with network
+// counters from the namespace of one of its processes
+func readCgroupStats(dir string, pid int, prev *StatsResult) *StatsResult {
+	now := time.Now()
+	result := &StatsResult{
+		Sample: Sample{
+			Timestamp: now,
+		},
+	}
+
+	// CPU: usage against wall-clock time, so 100% is one core like Docker's
+	cpuStat := readCgroupKeyed(dir, "cpu.stat")
+	result.PrevCPU = cpuStat["usage_usec"] * 1000
+	result.PrevSystem = uint64(now.UnixNano())
+	if prev != nil && prev.PrevSystem > 0 && result.PrevCPU > prev.PrevCPU {
+		elapsed := float64(result.PrevSystem - prev.PrevSystem)
+		if elapsed > 0 {
+			result.Sample.CPUPercent = float64(result.PrevCPU-prev.PrevCPU) / elapsed * 100.0
+		}
+	}
+
+	// Memory, as a share of the limit or of host memory without one
+	result.Sample.MemoryUsage, _ = readCgroupUint(dir, "memory.current")
+	result.Sample.MemoryCache = readCgroupKeyed(dir, "memory.stat")["file"]
+	limit, _ := readCgroupUint(dir, "memory.max")
+	if limit == 0 {
+		limit = readMemTotal()
+	}
+	if limit > 0 {
+		result.Sample.MemoryPercent = float64(result.Sample.MemoryUsage) / float64(limit) * 100.0
+	}
+
+	// Network stats from the process's network namespace
+	result.Sample.NetRxBytes, result.Sample.NetTxBytes = readNetDev(hostPaths.ProcPath(strconv.Itoa(pid), "net", "dev"))
+
+	// Block I/O stats
+	for _, line := range strings.Split(readCgroupString(dir, "io.stat"), "\n") {
+		for _, field := range strings.Fields(line) {
+			if v, ok := strings.CutPrefix(field, "rbytes="); ok {
+				n, _ := strconv.ParseUint(v, 10, 64)
+				result.Sample.BlockRead += n
+			} else if v, ok := strings.CutPrefix(field, "wbytes="); ok {
+				n, _ := strconv.ParseUint(v, 10, 64)
+				result.Sample.BlockWrite += n
+			}
+		}
+	}
+	applyCounterRates(result, prev)
+
+	// PIDs
+	result.Sample.PidsCount, _ = readCgroupUint(dir, "pids.current")
+
+	return result
+}
+
+// ListNetworkContainers returns running containers with their network addresses
+func (c *ContainerdClient) ListNetworkContainers(ctx context.Context) ([]NetworkContainer, error) {
+	// Every monitored container's network breakdown lists them in a round
+	c.networksMu.Lock()
+	defer c.networksMu.Unlock()
+	if c.networks != nil && time.Since(c.networksAt) < containerdCacheTTL {
+		return c.networks, nil
+	}
+
+	ids, err := c.runningIDs(ctx)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	containers, err := c.inspect(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]nerdctlContainer, len(containers))
+	result := []NetworkContainer{}
+	for _, ctr := range containers {
+		byID[ctr.ID] = ctr
+		nc := NetworkContainer{
+			ID:       ctr.ID,
+			Names:    []string{strings.TrimPrefix(ctr.Name, "/")},
+			Image:    ctr.image(),
+			Labels:   ctr.Config.Labels,
+			Networks: make(map[string][]string),
+
+			NetworkMode: ctr.networkMode(),
+		}
+		for name, network := range ctr.NetworkSettings.Networks {
+			nc.Networks[name] = networkAddresses(network.IPAddress, network.GlobalIPv6Address)
+		}
+		result = append(result, nc)
+	}
+	c.cacheInspected(byID)
+	c.networks, c.networksAt = result, time.Now()
+	return result, nil
+}
+
+// Exec runs a command in a container and returns its standard output
+func (c *ContainerdClient) Exec(ctx context.Context, containerID string, cmd []string) ([]byte, error) {
+	return c.run(ctx, append([]string{"exec", containerID}, cmd...)...)
+}
+
+// Events streams container task starts and exits from containerd
+func (c *ContainerdClient) Events(ctx context.Context) (<-chan RuntimeEvent, <-chan error) {
+	out := make(chan RuntimeEvent)
+	errs := make(chan error, 1)
+
+	cmd := exec.CommandContext(ctx, c.binary, "events", "--format", "{{json .}}")
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		errs <- fmt.Errorf("failed to watch containerd events: %w", err)
+		close(out)
+		return out, errs
+	}
+
+	go func() {
+		defer close(out)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			event, ok := parseContainerdEvent(scanner.Bytes())
+			if !ok {
+				continue
+			}
+			// Stopped containers may already be gone; starts need the name to
+			// follow recreated containers
+			if event.Action == RuntimeEventStart {
+				if containers, err := c.inspect(ctx, event.ContainerID); err == nil {
+					event.Name = strings.TrimPrefix(containers[0].Name, "/")
+				}
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+			}
+		}
+		err := cmd.Wait()
+		if ctx.Err() == nil {
+			if err == nil {
+				err = fmt.Errorf("containerd event stream ended")
+			}
+			errs <- err
+		}
+	}()
+	return out, errs
+}
+
+// parseContainerdEvent turns a `nerdctl events` line into a start or stop of
+// a container's main task. Exec'd processes are ignored.
+func parseContainerdEvent(line []byte) (RuntimeEvent, bool) {
+	var envelope struct {
+		Timestamp time.Time
+		Topic     string
+		Event     json.RawMessage
+	}
+	if err := json.Unmarshal(line, &envelope); err != nil {
+		return RuntimeEvent{}, false
+	}
+
+	var action string
+	switch envelope.Topic {
+	case "/tasks/start":
+		action = RuntimeEventStart
+	case "/tasks/exit":
+		action = RuntimeEventStop
+	default:
+		return RuntimeEvent{}, false
+	}
+
+	// The payload is JSON, itself encoded as a string by some nerdctl versions
+	payload := []byte(envelope.Event)
+	var encoded string
+	if json.Unmarshal(payload, &encoded) == nil {
+		payload = []byte(encoded)
+	}
+	var task struct {
+		ContainerID string `json:"container_id"`
+		ID          string `json:"id"`
+	}
+	if err := json.Unmarshal(payload, &task); err != nil || task.ContainerID == "" {
+		return RuntimeEvent{}, false
+	}
+	if task.ID != "" && task.ID != task.ContainerID {
+		return RuntimeEvent{}, false
+	}
+
+	return RuntimeEvent{
+		ContainerID: task.ContainerID,
+		Action:      action,
+		Time:        envelope.Timestamp,
+	}, true
+}
+
+// readCgroupString reads a cgroup file, returning "" when it doesn't exist
+func readCgroupString(dir, file string) string {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readCgroupUint reads a single-value cgroup file. "max" and missing files
+// report false.
+func readCgroupUint(dir, file string) (uint64, bool) {
+	value, err := strconv.ParseUint(readCgroupString(dir, file), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// readCgroupKeyed reads a "key value" per line cgroup file such as cpu.stat
+func readCgroupKeyed(dir, file string) map[string]uint64 {
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(readCgroupString(dir, file), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = v
+		}
+	}
+	return values
+}
+
+// readNetDev sums received and transmitted bytes over the non-loopback
+// interfaces in a /proc/<pid>/net/dev file
+func readNetDev(path string) (rx, tx uint64) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, counters, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		r, _ := strconv.ParseUint(fields[0], 10, 64)
+		t, _ := strconv.ParseUint(fields[8], 10, 64)
+		rx += r
+		tx += t
+	}
+	return rx, tx
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// Control socket commands
+const (
+	ControlStatus   = "status"
+	ControlStream   = "stream"
+	ControlStop     = "stop"
+	ControlPause    = "pause"
+	ControlResume   = "resume"
+	ControlInterval = "interval"
+	ControlFlush    = "flush"
+	ControlReload   = "reload"
+)
+
+// controlDialTimeout bounds how long clients wait to connect to a daemon
+const controlDialTimeout = 2 * time.Second
+
+// ControlRequest is a single line-delimited JSON request on the control socket
+type ControlRequest struct {
+	Command  string `json:"command"`
+	Interval int    `json:"interval,omitempty"` // seconds, for "interval"
+}
+
+// ControlResponse is a single line-delimited JSON response. The "stream"
+// command answers with one response followed by one line per sample.
+type ControlResponse struct {
+	OK     bool           `json:"ok"`
+	Error  string         `json:"error,omitempty"`
+	Status *DaemonStatus  `json:"status,omitempty"`
+	Sample *ControlSample `json:"sample,omitempty"`
+}
+
+// ControlSample is a sample streamed from a running daemon
+type ControlSample struct {
+	ContainerName string `json:"container_name"`
+	Sample        Sample `json:"sample"`
+}
+
+// ServeControl accepts control connections for a monitor until the listener
+// is closed
+func ServeControl(listener net.Listener, m *Monitor) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			m.logger.Printf("Control socket error: %v\n", err)
+			continue
+		}
+		go handleControlConn(conn, m)
+	}
+}
+
+// ListenControl creates the control socket for a configuration, replacing a
+// stale socket left by a daemon that didn't shut down cleanly
+func ListenControl(configName string) (net.Listener, error) {
+	if err := EnsureDirs(); err != nil {
+		return nil, err
+	}
+
+	socketPath := GetSocketFile(configName)
+	if info, err := os.Lstat(socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", socketPath)
+		}
+		if conn, err := net.DialTimeout("unix", socketPath, controlDialTimeout); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another daemon is already listening on %s", socketPath)
+		}
+		os.Remove(socketPath)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create control socket: %w", err)
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set control socket permissions: %w", err)
+	}
+	return listener, nil
+}
+
+// handleControlConn serves requests on one control connection
+func handleControlConn(conn net.Conn, m *Monitor) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		var req ControlRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			encoder.Encode(ControlResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+			continue
+		}
+
+		switch req.Command {
+		case ControlStatus:
+			encoder.Encode(ControlResponse{OK: true, Status: m.Status()})
+
+		case ControlStream:
+			streamControlSamples(conn, encoder, m)
+			return
+
+		case ControlStop:
+			encoder.Encode(ControlResponse{OK: true})
+			m.Stop()
+			return
+
+		case ControlPause, ControlResume:
+			m.SetPaused(req.Command == ControlPause)
+			encoder.Encode(ControlResponse{OK: true, Status: m.Status()})
+
+		case ControlInterval:
+			if req.Interval < 1 {
+				encoder.Encode(ControlResponse{Error: "invalid interval: must be a positive number"})
+				continue
+			}
+			m.SetInterval(req.Interval)
+			encoder.Encode(ControlResponse{OK: true, Status: m.Status()})
+
+		case ControlFlush:
+			m.saveData()
+			encoder.Encode(ControlResponse{OK: true, Status: m.Status()})
+
+		case ControlReload:
+			if err := m.Reload(); err != nil {
+				encoder.Encode(ControlResponse{Error: fmt.Sprintf("reload failed: %v", err)})
+				continue
+			}
+			encoder.Encode(ControlResponse{OK: true, Status: m.Status()})
+
+		default:
+			encoder.Encode(ControlResponse{Error: fmt.Sprintf("unknown command: %s", req.Command)})
+		}
+	}
+}
+
+// streamControlSamples writes every new sample to the connection until the
+// client disconnects or the monitor stops
+func streamControlSamples(conn net.Conn, encoder *json.Encoder, m *Monitor) {
+	samples, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+
+	if err := encoder.Encode(ControlResponse{OK: true}); err != nil {
+		return
+	}
+
+	// Detect client disconnects (the client sends nothing after "stream")
+	closed := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case sample, ok := <-samples:
+			if !ok {
+				return
+			}
+			if err := encoder.Encode(ControlResponse{OK: true, Sample: &sample}); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// dialControl connects to a daemon's control socket
+func dialControl(configName string) (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", GetSocketFile(configName), controlDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to control socket: %w", err)
+	}
+	return conn, nil
+}
+
+// SendControlCommand sends a single command to a running daemon and returns its response
+func SendControlCommand(configName string, req ControlRequest) (*ControlResponse, error) {
+	conn, err := dialControl(configName)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send command: %w", err)
+	}
+
+	var resp ControlResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.OK {
+		return &resp, fmt.Errorf("%s", resp.Error)
+	}
+	return &resp, nil
+}
+
+// QueryDaemonStatus returns the status reported by a daemon's control socket
+func QueryDaemonStatus(configName string) (*DaemonStatus, error) {
+	resp, err := SendControlCommand(configName, ControlRequest{Command: ControlStatus})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Status, nil
+}
+
+// StreamDaemonSamples subscribes to a daemon's samples and delivers them on
+// the returned channel, which is closed when the stream ends. Closing the
+// returned connection ends the stream.
+func StreamDaemonSamples(configName string) (<-chan ControlSample, net.Conn, error) {
+	conn, err := dialControl(configName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := json.NewEncoder(conn).Encode(ControlRequest{Command: ControlStream}); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to send command: %w", err)
+	}
+
+	decoder := json.NewDecoder(conn)
+	var resp ControlResponse
+	if err := decoder.Decode(&resp); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.OK {
+		conn.Close()
+		return nil, nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	samples := make(chan ControlSample, 16)
+	go func() {
+		defer close(samples)
+		for {
+			var msg ControlResponse
+			if err := decoder.Decode(&msg); err != nil {
+				return
+			}
+			if msg.Sample != nil {
+				samples <- *msg.Sample
+			}
+		}
+	}()
+
+	return samples, conn, nil
+}
+
+// ControlAvailable reports whether a daemon answers on its control socket
+func ControlAvailable(configName string) bool {
+	conn, err := dialControl(configName)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
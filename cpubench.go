@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"time"
+)
+
+// referenceBenchmarkScore is the approximate single-thread benchmark result
+// (work units per second) of one m5 vCPU (Skylake/Cascade Lake Xeon,
+// hyper-threaded). Instance family performance factors in the pricing catalog
+// are relative to the same baseline.
+const referenceBenchmarkScore = 60000.0
+
+// defaultBenchmarkDuration is how long the built-in CPU benchmark runs
+const defaultBenchmarkDuration = 500 * time.Millisecond
+
+// RunCPUBenchmark runs a short single-threaded CPU micro-benchmark and returns
+// the host's per-core performance relative to the reference vCPU (1.0 = same
+// as one m5 vCPU, 1.5 = 50% faster)
+func RunCPUBenchmark(duration time.Duration) float64 {
+	if duration <= 0 {
+		duration = defaultBenchmarkDuration
+	}
+
+	// Keep the benchmark on one OS thread so it measures a single core
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// Short warm-up so frequency scaling kicks in
+	benchmarkRound()
+
+	var rounds int
+	start := time.Now()
+	for time.Since(start) < duration {
+		benchmarkRound()
+		rounds++
+	}
+	elapsed := time.Since(start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+
+	score := float64(rounds) / elapsed / referenceBenchmarkScore
+	return math.Round(score*100) / 100
+}
+
+// benchmarkSink prevents the compiler from optimizing the benchmark away
+var benchmarkSink uint64
+
+// benchmarkRound runs one unit of mixed integer, floating-point and memory
+// work, roughly resembling a typical service workload
+func benchmarkRound() {
+	var table [256]uint64
+	x := uint64(88172645463325252)
+	f := 1.0
+
+	for i := 0; i < 2048; i++ {
+		// xorshift PRNG (integer ALU)
+		x ^= x << 13
+		x ^= x >> 7
+		x ^= x << 17
+
+		// table lookups/updates (L1 cache)
+		idx := x & 255
+		table[idx] += x
+		x += table[(idx*31)&255]
+
+		// floating point
+		f = f*1.0000001 + float64(x&1023)/1024
+	}
+
+	benchmarkSink += x + uint64(f)
+}
@@ -6,12 +6,17 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 )
 
 // StartDaemon starts the monitoring daemon in the background
 func StartDaemon(config Config) error {
+	if config.Aggregated {
+		return fmt.Errorf("configuration '%s' holds data pushed by agents; monitor it on the agent hosts", config.Name)
+	}
+
 	// Get the path to the current executable
 	executable, err := os.Executable()
 	if err != nil {
@@ -24,8 +29,12 @@ func StartDaemon(config Config) error {
 		return fmt.Errorf("failed to create log file: %w", err)
 	}
 
-	// Start the process in foreground mode but detached
+	// Start the process in foreground mode but detached, under the
+	// supervisor when the config asks for restarts
 	cmd := exec.Command(executable, "start", config.Name, "--foreground")
+	if config.Restart == RestartOnFailure {
+		cmd = exec.Command(executable, "supervise", config.Name)
+	}
 
 	// Redirect stdout/stderr to log file
 	cmd.Stdout = logWriter
@@ -59,8 +68,22 @@ func StartDaemon(config Config) error {
 	return nil
 }
 
-// StopDaemon stops a running daemon
+// StopDaemon stops a running daemon, through its control socket when
+// available and by signalling the PID from its PID file otherwise
 func StopDaemon(configName string) error {
+	if _, err := SendControlCommand(configName, ControlRequest{Command: ControlStop}); err == nil {
+		// The socket closes once the final summary has been saved
+		deadline := time.Now().Add(30 * time.Second)
+		for time.Now().Before(deadline) {
+			if !ControlAvailable(configName) {
+				RemovePidFile(configName)
+				return nil
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		// Fall through to signals if the daemon didn't exit
+	}
+
 	pid, err := ReadPidFile(configName)
 	if err != nil {
 		return fmt.Errorf("failed to read PID file: %w", err)
@@ -98,11 +121,30 @@ func StopDaemon(configName string) error {
 	return nil
 }
 
-// ListDaemons returns status of all running daemons
+// ListDaemons returns status of all running daemons. Daemons are queried over
+// their control sockets; PID files are only used for daemons without one.
 func ListDaemons() ([]DaemonStatus, error) {
+	var statuses []DaemonStatus
+	seen := make(map[string]bool)
+
+	sockets, _ := filepath.Glob(filepath.Join(mdokDir, "sockets", "*.sock"))
+	for _, socket := range sockets {
+		configName := strings.TrimSuffix(filepath.Base(socket), ".sock")
+		status, err := QueryDaemonStatus(configName)
+		if err != nil {
+			// Nothing is listening - left over from a crashed daemon
+			if !ControlAvailable(configName) {
+				os.Remove(socket)
+			}
+			continue
+		}
+		statuses = append(statuses, *status)
+		seen[configName] = true
+	}
+
 	pidDir := filepath.Join(mdokDir, "pids")
 	if _, err := os.Stat(pidDir); os.IsNotExist(err) {
-		return nil, nil
+		return statuses, nil
 	}
 
 	files, err := filepath.Glob(filepath.Join(pidDir, "*.pid"))
@@ -110,10 +152,12 @@ func ListDaemons() ([]DaemonStatus, error) {
 		return nil, err
 	}
 
-	var statuses []DaemonStatus
 	for _, file := range files {
 		configName := filepath.Base(file)
 		configName = configName[:len(configName)-4] // Remove .pid extension
+		if seen[configName] {
+			continue
+		}
 
 		pid, err := ReadPidFile(configName)
 		if err != nil {
@@ -148,6 +192,7 @@ func ListDaemons() ([]DaemonStatus, error) {
 			StartTime:  startTime,
 			Running:    running,
 			Containers: config.Containers,
+			Source:     "pid",
 		})
 	}
 
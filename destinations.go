@@ -0,0 +1,294 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Top destinations
+const (
+	maxSampleDestinations = 10 // Kept per sample, by bytes and by connections each
+	maxTopDestinations    = 10 // Kept per session summary, by bytes and by connections each
+	reverseDNSTimeout     = 2 * time.Second
+	reverseDNSTTL         = time.Hour
+	reverseDNSNegativeTTL = 10 * time.Minute
+	reverseDNSMaxEntries  = 4096 // Cached names per daemon
+	reverseDNSMaxLookups  = 8    // Concurrent lookups per daemon
+)
+
+// DestinationNamer names the remote addresses of top destinations: other
+// containers by name, then the configuration's network labels, then reverse DNS
+type DestinationNamer struct {
+	labels     []networkLabel // Most specific first
+	reverseDNS bool
+}
+
+// networkLabel is a named CIDR from a configuration's network_labels
+type networkLabel struct {
+	network *net.IPNet
+	name    string
+}
+
+// NewDestinationNamer builds the destination namer of a configuration
+func NewDestinationNamer(config Config) (*DestinationNamer, error) {
+	namer := &DestinationNamer{reverseDNS: !config.NoReverseDNS}
+	for cidr, name := range config.NetworkLabels {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network label %q: %w", cidr, err)
+		}
+		namer.labels = append(namer.labels, networkLabel{network: network, name: name})
+	}
+
+	// A /24 inside a labeled /16 takes precedence
+	slices.SortFunc(namer.labels, func(a, b networkLabel) int {
+		aOnes, _ := a.network.Mask.Size()
+		bOnes, _ := b.network.Mask.Size()
+		if c := cmp.Compare(bOnes, aOnes); c != 0 {
+			return c
+		}
+		return strings.Compare(a.network.String(), b.network.String())
+	})
+	return namer, nil
+}
+
+// name names an IP. Reverse DNS names are looked up in the background, so
+// an address is named from the samples after its first one.
+func (n *DestinationNamer) name(ip net.IP, containers map[string]string) string {
+	if ip == nil {
+		return ""
+	}
+	if name, ok := containers[ip.String()]; ok {
+		return name
+	}
+	for _, label := range n.labels {
+		if label.network.Contains(ip) {
+			return label.name
+		}
+	}
+	if n.reverseDNS && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() {
+		return reverseDNS.lookup(ip.String())
+	}
+	return ""
+}
+
+// nameAll fills in the names of destinations and the containers they belong to
+func (n *DestinationNamer) nameAll(destinations []DestinationSample, containers map[string]string) []DestinationSample {
+	for i := range destinations {
+		host, _, _ := net.SplitHostPort(destinations[i].Addr)
+		destinations[i].Name = n.name(net.ParseIP(host), containers)
+		destinations[i].Container = containers[host]
+	}
+	return destinations
+}
+
+// reverseDNSCache remembers the reverse DNS names of addresses, including
+// failed lookups for a while. It holds at most reverseDNSMaxEntries names
+// and runs at most reverseDNSMaxLookups lookups at a time; addresses that
+// find no free slot are looked up on a later sample.
+type reverseDNSCache struct {
+	mu      sync.Mutex
+	entries map[string]reverseDNSEntry
+	slots   chan struct{}
+}
+
+type reverseDNSEntry struct {
+	name    string
+	expires time.Time
+	pending bool
+}
+
+// reverseDNS is shared by all containers of a daemon
+var reverseDNS = &reverseDNSCache{
+	entries: make(map[string]reverseDNSEntry),
+	slots:   make(chan struct{}, reverseDNSMaxLookups),
+}
+
+// lookup returns the cached name of an IP and starts a lookup when there is
+// none or it expired
+func (c *reverseDNSCache) lookup(ip string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[ip]
+	if ok && (entry.pending || time.Now().Before(entry.expires)) {
+		return entry.name
+	}
+	select {
+	case c.slots <- struct{}{}:
+	default:
+		return entry.name // Too many lookups running
+	}
+	if !ok {
+		c.makeRoom()
+	}
+	c.entries[ip] = reverseDNSEntry{name: entry.name, pending: true}
+	go c.resolve(ip)
+	return entry.name
+}
+
+// cached returns the cached name of an IP without looking it up
+func (c *reverseDNSCache) cached(ip string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[ip].name
+}
+
+// makeRoom drops expired entries when the cache is full, then the ones that
+// expire first. Pending lookups are kept. The caller holds c.mu.
+func (c *reverseDNSCache) makeRoom() {
+	if len(c.entries) < reverseDNSMaxEntries {
+		return
+	}
+	now := time.Now()
+	var live []string
+	for ip, entry := range c.entries {
+		switch {
+		case entry.pending:
+		case now.After(entry.expires):
+			delete(c.entries, ip)
+		default:
+			live = append(live, ip)
+		}
+	}
+	if excess := len(c.entries) - reverseDNSMaxEntries + 1; excess > 0 {
+		slices.SortFunc(live, func(a, b string) int {
+			return c.entries[a].expires.Compare(c.entries[b].expires)
+		})
+		for _, ip := range live[:min(excess, len(live))] {
+			delete(c.entries, ip)
+		}
+	}
+}
+
+// resolve looks up an IP's name and caches the result
+func (c *reverseDNSCache) resolve(ip string) {
+	ctx, cancel := context.WithTimeout(context.Background(), reverseDNSTimeout)
+	defer cancel()
+
+	entry := reverseDNSEntry{expires: time.Now().Add(reverseDNSNegativeTTL)}
+	if names, err := net.DefaultResolver.LookupAddr(ctx, ip); err == nil && len(names) > 0 {
+		entry = reverseDNSEntry{name: strings.TrimSuffix(names[0], "."), expires: time.Now().Add(reverseDNSTTL)}
+	}
+
+	c.mu.Lock()
+	c.entries[ip] = entry
+	c.mu.Unlock()
+	<-c.slots
+}
+
+// topDestinations returns the busiest destinations of a sample
+func topDestinations(tally destinationTally, n int) []DestinationSample {
+	all := make([]DestinationSample, 0, len(tally))
+	for _, d := range tally {
+		all = append(all, *d)
+	}
+	return busiest(all, n,
+		func(d DestinationSample) string { return d.Addr },
+		func(d DestinationSample) uint64 { return d.Bytes },
+		func(d DestinationSample) float64 { return float64(d.Connections) })
+}
+
+// summarizeDestinations adds up the destinations of a session's samples.
+// Bytes are summed like the byte breakdown; connections are averaged over
+// all samples.
+func summarizeDestinations(samples []Sample) []DestinationSummary {
+	byAddr := make(map[string]*DestinationSummary)
+	connections := make(map[string]int)
+	for _, s := range samples {
+		for _, d := range s.NetDestinations {
+			sum, ok := byAddr[d.Addr]
+			if !ok {
+				sum = &DestinationSummary{Addr: d.Addr}
+				byAddr[d.Addr] = sum
+			}
+			if d.Name != "" {
+				sum.Name = d.Name
+			}
+			sum.Class = d.Class
+			sum.Bytes += d.Bytes
+			sum.MaxConnections = max(sum.MaxConnections, d.Connections)
+			connections[d.Addr] += d.Connections
+		}
+	}
+	if len(byAddr) == 0 {
+		return nil
+	}
+
+	all := make([]DestinationSummary, 0, len(byAddr))
+	for addr, sum := range byAddr {
+		sum.AvgConnections = float64(connections[addr]) / float64(len(samples))
+		all = append(all, *sum)
+	}
+	return busiest(all, maxTopDestinations,
+		func(d DestinationSummary) string { return d.Addr },
+		func(d DestinationSummary) uint64 { return d.Bytes },
+		func(d DestinationSummary) float64 { return d.AvgConnections })
+}
+
+// busiest keeps the top n items by bytes and the top n by connections,
+// ordered by bytes and then connections
+func busiest[T any](items []T, n int, addr func(T) string, bytes func(T) uint64, conns func(T) float64) []T {
+	byConns := func(a, b T) int {
+		if c := cmp.Compare(conns(b), conns(a)); c != 0 {
+			return c
+		}
+		return strings.Compare(addr(a), addr(b))
+	}
+	slices.SortFunc(items, byConns)
+	keep := make(map[string]bool)
+	for i := 0; i < len(items) && i < n && conns(items[i]) > 0; i++ {
+		keep[addr(items[i])] = true
+	}
+
+	slices.SortFunc(items, func(a, b T) int {
+		if c := cmp.Compare(bytes(b), bytes(a)); c != 0 {
+			return c
+		}
+		return byConns(a, b)
+	})
+	var result []T
+	for i, item := range items {
+		if (i < n && bytes(item) > 0) || keep[addr(item)] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// formatDestination shows a destination as "name (ip:port)", or just the address
+func formatDestination(addr, name string) string {
+	if name == "" {
+		return addr
+	}
+	return name + " (" + addr + ")"
+}
+
+// formatTopDestinations lays out top destinations as aligned text lines
+func formatTopDestinations(destinations []DestinationSummary) []string {
+	width := 0
+	for _, d := range destinations {
+		width = max(width, len(formatDestination(d.Addr, d.Name)))
+	}
+
+	var lines []string
+	for _, d := range destinations {
+		line := fmt.Sprintf("%-*s  %-15s", width, formatDestination(d.Addr, d.Name), d.Class)
+		if d.Bytes > 0 {
+			line += fmt.Sprintf("  %10s", formatBytes(d.Bytes))
+		} else {
+			line += fmt.Sprintf("  %10s", "-")
+		}
+		if d.MaxConnections > 0 {
+			line += fmt.Sprintf("  conns avg %.1f max %d", d.AvgConnections, d.MaxConnections)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
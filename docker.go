@@ -2,9 +2,12 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"runtime"
 	"strings"
@@ -12,12 +15,17 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
-// DockerClient wraps the Docker API client
+// DockerClient wraps the Docker API client. It also talks to Podman's
+// Docker-compatible socket, working around where Podman answers differently.
 type DockerClient struct {
-	cli *client.Client
+	cli    *client.Client
+	podman bool
 }
 
 // NewDockerClient creates a new Docker client
@@ -34,7 +42,8 @@ func (d *DockerClient) Close() error {
 	return d.cli.Close()
 }
 
-// ListContainers returns a list of running containers
+// ListContainers returns a list of running containers, excluding mdok's own
+// container when it runs in one
 func (d *DockerClient) ListContainers(ctx context.Context) ([]ContainerInfo, error) {
 	containers, err := d.cli.ContainerList(ctx, container.ListOptions{All: false})
 	if err != nil {
@@ -43,6 +52,9 @@ func (d *DockerClient) ListContainers(ctx context.Context) ([]ContainerInfo, err
 
 	var result []ContainerInfo
 	for _, c := range containers {
+		if hostPaths.IsSelf(c.ID) || (d.podman && isPodmanInfraContainer(c.Image)) {
+			continue
+		}
 		name := ""
 		if len(c.Names) > 0 {
 			name = strings.TrimPrefix(c.Names[0], "/")
@@ -86,12 +98,19 @@ func (d *DockerClient) GetContainerLimits(ctx context.Context, containerID strin
 	}
 
 	limits := ContainerLimits{
-		CPUQuota:  inspect.HostConfig.CPUQuota,
-		CPUPeriod: inspect.HostConfig.CPUPeriod,
-		CPUShares: inspect.HostConfig.CPUShares,
-		MemLimit:  uint64(inspect.HostConfig.Memory),
-		MemSwap:   inspect.HostConfig.MemorySwap,
-		PidsLimit: pidsLimit,
+		CPUQuota:       inspect.HostConfig.CPUQuota,
+		CPUPeriod:      inspect.HostConfig.CPUPeriod,
+		CPUShares:      inspect.HostConfig.CPUShares,
+		MemLimit:       uint64(inspect.HostConfig.Memory),
+		MemReservation: uint64(inspect.HostConfig.MemoryReservation),
+		MemSwap:        inspect.HostConfig.MemorySwap,
+		PidsLimit:      pidsLimit,
+	}
+
+	// --cpus is stored as NanoCPUs rather than a quota
+	if limits.CPUQuota == 0 && inspect.HostConfig.NanoCPUs > 0 {
+		limits.CPUPeriod = 100000
+		limits.CPUQuota = inspect.HostConfig.NanoCPUs * limits.CPUPeriod / 1e9
 	}
 	return limits, nil
 }
@@ -114,6 +133,18 @@ func (d *DockerClient) GetContainerImage(ctx context.Context, containerID string
 	return inspect.Config.Image, nil
 }
 
+// GetContainerLabels returns the labels set on a container
+func (d *DockerClient) GetContainerLabels(ctx context.Context, containerID string) (map[string]string, error) {
+	inspect, err := d.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if inspect.Config == nil {
+		return nil, nil
+	}
+	return inspect.Config.Labels, nil
+}
+
 // GetHostInfo retrieves host system information
 func (d *DockerClient) GetHostInfo(ctx context.Context) (HostInfo, error) {
 	info, err := d.cli.Info(ctx)
@@ -126,8 +157,17 @@ func (d *DockerClient) GetHostInfo(ctx context.Context) (HostInfo, error) {
 		return HostInfo{}, fmt.Errorf("failed to get Docker version: %w", err)
 	}
 
-	// Get CPU model from /proc/cpuinfo on Linux
-	cpuModel := getCPUModel()
+	// The daemon doesn't report the CPU model. /proc/cpuinfo only describes
+	// its host when the daemon is local; remote endpoints leave it unknown.
+	cpuModel := "unknown"
+	if isLocalDaemonHost(d.cli.DaemonHost()) {
+		cpuModel = getCPUModel()
+	}
+
+	runtimeName := RuntimeDocker
+	if d.podman {
+		runtimeName = RuntimePodman
+	}
 
 	return HostInfo{
 		Hostname:     info.Name,
@@ -138,16 +178,24 @@ func (d *DockerClient) GetHostInfo(ctx context.Context) (HostInfo, error) {
 		OS:           info.OperatingSystem,
 		KernelVer:    info.KernelVersion,
 		DockerVer:    version.Version,
+		Runtime:      runtimeName,
 	}, nil
 }
 
-// getCPUModel attempts to get CPU model from /proc/cpuinfo
+// isLocalDaemonHost reports whether a daemon address is on this host. Unix
+// sockets are; tcp:// and ssh:// endpoints (dialed through a placeholder
+// http:// host) count as remote even on a loopback address.
+func isLocalDaemonHost(host string) bool {
+	return strings.HasPrefix(host, "unix://") || strings.HasPrefix(host, "npipe://")
+}
+
+// getCPUModel attempts to get CPU model from the host's /proc/cpuinfo
 func getCPUModel() string {
 	if runtime.GOOS != "linux" {
 		return "unknown"
 	}
 
-	file, err := os.Open("/proc/cpuinfo")
+	file, err := os.Open(hostPaths.ProcPath("cpuinfo"))
 	if err != nil {
 		return "unknown"
 	}
@@ -168,14 +216,17 @@ func getCPUModel() string {
 
 // StatsResult contains parsed container stats
 type StatsResult struct {
-	Sample       Sample
-	PrevCPU      uint64
-	PrevSystem   uint64
-	PrevNetRx    uint64
-	PrevNetTx    uint64
-	PrevBlockRd  uint64
-	PrevBlockWr  uint64
-	Error        error
+	Sample      Sample
+	PrevCPU     uint64
+	PrevSystem  uint64
+	PrevNetRx   uint64
+	PrevNetTx   uint64
+	PrevBlockRd uint64
+	PrevBlockWr uint64
+	PrevFlows   map[string]conntrackFlow // Conntrack counters by flow; nil until read
+	Proxies     []ProxyClassification    // How containers on the host were classified as proxies
+	Namespace   *NetworkNamespace        // The network namespace, if shared
+	Error       error
 }
 
 // CollectStats collects a single stats sample from a container
@@ -197,9 +248,16 @@ func (d *DockerClient) CollectStats(ctx context.Context, containerID string, pre
 		},
 	}
 
-	// Calculate CPU percentage
-	cpuDelta := float64(statsJSON.CPUStats.CPUUsage.TotalUsage - statsJSON.PreCPUStats.CPUUsage.TotalUsage)
-	systemDelta := float64(statsJSON.CPUStats.SystemUsage - statsJSON.PreCPUStats.SystemUsage)
+	// Calculate CPU percentage. Podman leaves precpu_stats empty for one-shot
+	// stats, so the previous sample's counters stand in.
+	preCPU := statsJSON.PreCPUStats.CPUUsage.TotalUsage
+	preSystem := statsJSON.PreCPUStats.SystemUsage
+	if preSystem == 0 && prev != nil {
+		preCPU = prev.PrevCPU
+		preSystem = prev.PrevSystem
+	}
+	cpuDelta := float64(statsJSON.CPUStats.CPUUsage.TotalUsage) - float64(preCPU)
+	systemDelta := float64(statsJSON.CPUStats.SystemUsage) - float64(preSystem)
 	numCPUs := float64(statsJSON.CPUStats.OnlineCPUs)
 	if numCPUs == 0 {
 		numCPUs = float64(len(statsJSON.CPUStats.CPUUsage.PercpuUsage))
@@ -208,7 +266,7 @@ func (d *DockerClient) CollectStats(ctx context.Context, containerID string, pre
 		numCPUs = 1
 	}
 
-	if systemDelta > 0 && cpuDelta > 0 {
+	if preSystem > 0 && systemDelta > 0 && cpuDelta > 0 {
 		result.Sample.CPUPercent = (cpuDelta / systemDelta) * numCPUs * 100.0
 	}
 
@@ -217,10 +275,14 @@ func (d *DockerClient) CollectStats(ctx context.Context, containerID string, pre
 	if statsJSON.MemoryStats.Stats != nil {
 		if cache, ok := statsJSON.MemoryStats.Stats["cache"]; ok {
 			result.Sample.MemoryCache = cache
+		} else if file, ok := statsJSON.MemoryStats.Stats["file"]; ok {
+			// cgroup v2 name
+			result.Sample.MemoryCache = file
 		}
 	}
-	if statsJSON.MemoryStats.Limit > 0 {
-		result.Sample.MemoryPercent = float64(statsJSON.MemoryStats.Usage) / float64(statsJSON.MemoryStats.Limit) * 100.0
+	// Podman reports "no limit" as the maximum value rather than host memory
+	if limit := statsJSON.MemoryStats.Limit; limit > 0 && limit < math.MaxInt64 {
+		result.Sample.MemoryPercent = float64(statsJSON.MemoryStats.Usage) / float64(limit) * 100.0
 	}
 
 	// Network stats (sum all interfaces)
@@ -231,17 +293,6 @@ func (d *DockerClient) CollectStats(ctx context.Context, containerID string, pre
 	}
 	result.Sample.NetRxBytes = netRx
 	result.Sample.NetTxBytes = netTx
-	result.PrevNetRx = netRx
-	result.PrevNetTx = netTx
-
-	// Calculate network rates if we have previous data
-	if prev != nil && prev.PrevNetRx > 0 {
-		elapsed := result.Sample.Timestamp.Sub(prev.Sample.Timestamp).Seconds()
-		if elapsed > 0 {
-			result.Sample.NetRxRate = float64(netRx-prev.PrevNetRx) / elapsed
-			result.Sample.NetTxRate = float64(netTx-prev.PrevNetTx) / elapsed
-		}
-	}
 
 	// Block I/O stats
 	var blockRead, blockWrite uint64
@@ -255,32 +306,11 @@ func (d *DockerClient) CollectStats(ctx context.Context, containerID string, pre
 	}
 	result.Sample.BlockRead = blockRead
 	result.Sample.BlockWrite = blockWrite
-	result.PrevBlockRd = blockRead
-	result.PrevBlockWr = blockWrite
-
-	// Calculate block I/O rates if we have previous data
-	if prev != nil && prev.PrevBlockRd > 0 {
-		elapsed := result.Sample.Timestamp.Sub(prev.Sample.Timestamp).Seconds()
-		if elapsed > 0 {
-			result.Sample.BlockReadRate = float64(blockRead-prev.PrevBlockRd) / elapsed
-			result.Sample.BlockWriteRate = float64(blockWrite-prev.PrevBlockWr) / elapsed
-		}
-	}
+	applyCounterRates(result, prev)
 
 	// PIDs
 	result.Sample.PidsCount = statsJSON.PidsStats.Current
 
-	// Network breakdown (classify active connections and bytes)
-	// This is best-effort and may fail silently
-	netStats := d.getNetworkStats(ctx, containerID)
-	result.Sample.NetConnInterContainer = netStats.ConnInterContainer
-	result.Sample.NetConnInternal = netStats.ConnInternal
-	result.Sample.NetConnInternet = netStats.ConnInternet
-	result.Sample.NetBytesInterContainer = netStats.BytesInterContainer
-	result.Sample.NetBytesInternal = netStats.BytesInternal
-	result.Sample.NetBytesInternet = netStats.BytesInternet
-	result.Sample.NetBytesSource = netStats.BytesSource
-
 	// Store CPU values for next calculation
 	result.PrevCPU = statsJSON.CPUStats.CPUUsage.TotalUsage
 	result.PrevSystem = statsJSON.CPUStats.SystemUsage
@@ -288,6 +318,64 @@ func (d *DockerClient) CollectStats(ctx context.Context, containerID string, pre
 	return result, nil
 }
 
+// applyCounterRates stores a sample's network and block I/O counters for the
+// next sample and calculates rates against the previous one
+func applyCounterRates(result *StatsResult, prev *StatsResult) {
+	result.PrevNetRx = result.Sample.NetRxBytes
+	result.PrevNetTx = result.Sample.NetTxBytes
+	result.PrevBlockRd = result.Sample.BlockRead
+	result.PrevBlockWr = result.Sample.BlockWrite
+	if prev == nil {
+		return
+	}
+
+	elapsed := result.Sample.Timestamp.Sub(prev.Sample.Timestamp).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	if prev.PrevNetRx > 0 {
+		result.Sample.NetRxRate = counterRate(result.PrevNetRx, prev.PrevNetRx, elapsed)
+		result.Sample.NetTxRate = counterRate(result.PrevNetTx, prev.PrevNetTx, elapsed)
+	}
+	if prev.PrevBlockRd > 0 {
+		result.Sample.BlockReadRate = counterRate(result.PrevBlockRd, prev.PrevBlockRd, elapsed)
+		result.Sample.BlockWriteRate = counterRate(result.PrevBlockWr, prev.PrevBlockWr, elapsed)
+	}
+}
+
+// counterRate is the per-second rate of a cumulative counter. A counter that
+// went down was reset (e.g. a new container process), so there is no rate;
+// subtracting would wrap around to a huge one.
+func counterRate(current, previous uint64, elapsed float64) float64 {
+	if current < previous {
+		return 0
+	}
+	return float64(current-previous) / elapsed
+}
+
+// applyNetworkStats copies the network breakdown into a sample
+func applyNetworkStats(result *StatsResult, netStats NetworkStats) {
+	if len(netStats.Zones) > 0 {
+		result.Sample.NetZones = netStats.Zones
+	}
+	result.Sample.NetBytesSource = netStats.BytesSource
+	result.Sample.NetCallers = netStats.Callers
+	result.Sample.NetViaProxy = netStats.ViaProxy
+	result.Sample.NetListen = netStats.Listening
+	if len(netStats.TCPStates) > 0 {
+		result.Sample.NetTCPStates = netStats.TCPStates
+	}
+	result.Sample.NetUnixSockets = netStats.UnixSockets
+	result.Sample.NetUnixListening = netStats.UnixListening
+	result.Sample.NetEphemeralPorts = netStats.EphemeralPorts
+	result.Sample.NetEphemeralRange = netStats.EphemeralRange
+	result.PrevFlows = netStats.Flows
+	result.Sample.NetDestinations = netStats.Destinations
+	result.Sample.NetPeers = netStats.Peers
+	result.Proxies = netStats.Proxies
+	result.Namespace = netStats.Namespace
+}
+
 // IsContainerRunning checks if a container is still running
 func (d *DockerClient) IsContainerRunning(ctx context.Context, containerID string) (bool, error) {
 	inspect, err := d.cli.ContainerInspect(ctx, containerID)
@@ -296,3 +384,127 @@ func (d *DockerClient) IsContainerRunning(ctx context.Context, containerID strin
 	}
 	return inspect.State.Running, nil
 }
+
+// ContainerPID returns the PID of a container's init process. It is only
+// meaningful for the local daemon; callers check it against the host's /proc.
+func (d *DockerClient) ContainerPID(ctx context.Context, containerID string) (int, error) {
+	inspect, err := d.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return 0, err
+	}
+	if !inspect.State.Running || inspect.State.Pid == 0 {
+		return 0, fmt.Errorf("container %s is not running", containerID)
+	}
+	return inspect.State.Pid, nil
+}
+
+// ListNetworkContainers returns running containers with their network addresses
+func (d *DockerClient) ListNetworkContainers(ctx context.Context) ([]NetworkContainer, error) {
+	containers, err := d.cli.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	var result []NetworkContainer
+	for _, c := range containers {
+		nc := NetworkContainer{
+			ID:       c.ID,
+			Names:    c.Names,
+			Image:    c.Image,
+			Labels:   c.Labels,
+			Networks: make(map[string][]string),
+
+			NetworkMode: c.HostConfig.NetworkMode,
+		}
+		if c.NetworkSettings != nil {
+			for name, network := range c.NetworkSettings.Networks {
+				nc.Networks[name] = networkAddresses(network.IPAddress, network.GlobalIPv6Address)
+			}
+		}
+		result = append(result, nc)
+	}
+	return result, nil
+}
+
+// networkAddresses returns the non-empty addresses of a network attachment
+func networkAddresses(addrs ...string) []string {
+	var result []string
+	for _, addr := range addrs {
+		if addr != "" {
+			result = append(result, addr)
+		}
+	}
+	return result
+}
+
+// Exec runs a command in a container and returns its standard output
+func (d *DockerClient) Exec(ctx context.Context, containerID string, cmd []string) ([]byte, error) {
+	execID, err := d.cli.ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.cli.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	// Without a TTY, stdout and stderr are multiplexed
+	var stdout bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, io.Discard, resp.Reader); err != nil {
+		return nil, err
+	}
+
+	inspect, err := d.cli.ContainerExecInspect(ctx, execID.ID)
+	if err == nil && inspect.ExitCode != 0 {
+		return stdout.Bytes(), fmt.Errorf("%s exited with code %d", cmd[0], inspect.ExitCode)
+	}
+	return stdout.Bytes(), nil
+}
+
+// Events streams container starts and stops
+func (d *DockerClient) Events(ctx context.Context) (<-chan RuntimeEvent, <-chan error) {
+	out := make(chan RuntimeEvent)
+	errs := make(chan error, 1)
+
+	f := filters.NewArgs(
+		filters.Arg("type", string(events.ContainerEventType)),
+		filters.Arg("event", string(events.ActionStart)),
+		filters.Arg("event", string(events.ActionDie)),
+	)
+	messages, messageErrs := d.cli.Events(ctx, types.EventsOptions{Filters: f})
+
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case msg := <-messages:
+				event := RuntimeEvent{
+					ContainerID: msg.Actor.ID,
+					Name:        strings.TrimPrefix(msg.Actor.Attributes["name"], "/"),
+					Action:      RuntimeEventStart,
+					Time:        time.Unix(0, msg.TimeNano),
+				}
+				if msg.Action == events.ActionDie {
+					event.Action = RuntimeEventStop
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			case err := <-messageErrs:
+				errs <- err
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, errs
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ServiceEgress is a container's internet egress over a session, with the
+// egress of the monitored proxies it called attributed to it
+type ServiceEgress struct {
+	Container      string   `json:"container"`
+	Monitored      bool     `json:"monitored"`
+	DirectBytes    uint64   `json:"direct_bytes"`              // Sent to the internet itself, or through proxies that aren't monitored
+	ViaProxyBytes  uint64   `json:"via_proxy_bytes"`           // Its share of monitored proxies' egress
+	ForwardedBytes uint64   `json:"forwarded_bytes,omitempty"` // For proxies: egress attributed to their callers
+	SharedWith     []string `json:"shared_with,omitempty"`     // Monitored containers in the same network namespace, whose egress this is too
+	PricePerGB     float64  `json:"price_per_gb"`
+	CostUSD        float64  `json:"cost_usd"`
+}
+
+// TotalBytes is the internet egress a container is responsible for
+func (e ServiceEgress) TotalBytes() uint64 {
+	return e.DirectBytes + e.ViaProxyBytes
+}
+
+// AttributeEgress splits the internet egress of monitored proxies back to the
+// containers that called them, in proportion to the bytes each sent the proxy
+// in the same sample. A caller's traffic to such a proxy no longer counts as
+// its own internet egress, so each byte that left for the internet is counted
+// once. Egress in samples where a proxy had no callers stays with the proxy.
+// Containers sharing a network namespace see the same traffic, which counts
+// once, for the first of them. It returns nothing when no monitored proxy
+// recorded its callers.
+func AttributeEgress(allData []*ContainerData) []ServiceEgress {
+	counted := namespaceCounted(SharedNamespaces(allData))
+	byRef := make(map[string]*ContainerData)
+	for _, data := range allData {
+		if ref, ok := counted[data.ContainerName]; ok && ref != data.ContainerName {
+			continue
+		}
+		byRef[data.ContainerName] = data
+	}
+	countedRef := func(ref string) string {
+		if c, ok := counted[ref]; ok {
+			return c
+		}
+		return ref
+	}
+
+	// Monitored proxies, as seen by the containers talking to them
+	proxies := make(map[string]bool)
+	for _, data := range byRef {
+		for _, class := range data.Proxies {
+			if ref := countedRef(peerRef(data, class.Container)); class.Proxy && class.InternetFraction > 0 && byRef[ref] != nil {
+				proxies[ref] = true
+			}
+		}
+		for _, s := range data.Samples {
+			for name := range s.NetViaProxy {
+				if ref := countedRef(peerRef(data, name)); byRef[ref] != nil {
+					proxies[ref] = true
+				}
+			}
+		}
+	}
+	for ref := range proxies {
+		if !recordsCallers(byRef[ref]) {
+			delete(proxies, ref) // Recorded before attribution, or without conntrack
+		}
+	}
+	if len(proxies) == 0 {
+		return nil
+	}
+
+	// Split each proxy's egress between its callers, sample by sample
+	attributed := make(map[string]uint64)
+	forwarded := make(map[string]uint64)
+	rates := make(map[string]float64)
+	for ref := range proxies {
+		proxy := byRef[ref]
+		for _, s := range proxy.Samples {
+			// What a proxy sends through another monitored proxy comes back
+			// to it from that one, and stays there
+			egress := sampleZones(s)[ZoneInternet].TxBytes
+			for name, n := range s.NetViaProxy {
+				if proxies[countedRef(peerRef(proxy, name))] {
+					egress -= min(egress, n)
+				}
+			}
+			var requests uint64
+			for _, n := range s.NetCallers {
+				requests += n
+			}
+			if egress == 0 || requests == 0 {
+				continue
+			}
+			for name, n := range s.NetCallers {
+				caller := countedRef(peerRef(proxy, name))
+				share := uint64(float64(egress) * float64(n) / float64(requests))
+				attributed[caller] += share
+				forwarded[ref] += share
+				if _, ok := rates[caller]; !ok {
+					rates[caller] = internetRate(proxy)
+				}
+			}
+		}
+	}
+
+	var services []ServiceEgress
+	for _, data := range allData {
+		if byRef[data.ContainerName] == nil {
+			continue // Counted with its network namespace
+		}
+		var internet, viaMonitored uint64
+		for _, s := range data.Samples {
+			internet += sampleZones(s)[ZoneInternet].TxBytes
+			for name, n := range s.NetViaProxy {
+				if proxies[countedRef(peerRef(data, name))] {
+					viaMonitored += n
+				}
+			}
+		}
+		ref := data.ContainerName
+		service := ServiceEgress{
+			Container:      ref,
+			Monitored:      true,
+			DirectBytes:    internet - min(internet, viaMonitored+forwarded[ref]),
+			ViaProxyBytes:  attributed[ref],
+			ForwardedBytes: forwarded[ref],
+			PricePerGB:     internetRate(data),
+		}
+		for name, c := range counted {
+			if c == ref && name != ref {
+				service.SharedWith = append(service.SharedWith, name)
+			}
+		}
+		slices.Sort(service.SharedWith)
+		delete(attributed, ref)
+		services = append(services, service)
+	}
+	for ref, n := range attributed {
+		services = append(services, ServiceEgress{Container: ref, ViaProxyBytes: n, PricePerGB: rates[ref]})
+	}
+
+	for i := range services {
+		services[i].CostUSD = float64(services[i].TotalBytes()) / (1024 * 1024 * 1024) * services[i].PricePerGB
+	}
+	slices.SortFunc(services, func(a, b ServiceEgress) int {
+		if c := cmp.Compare(b.TotalBytes(), a.TotalBytes()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Container, b.Container)
+	})
+	return services
+}
+
+// recordsCallers reports whether a container's samples recorded the
+// containers calling it
+func recordsCallers(data *ContainerData) bool {
+	for _, s := range data.Samples {
+		if s.NetCallers != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// internetRate is the internet egress rate of a container's configuration
+func internetRate(data *ContainerData) float64 {
+	return zoneRate(ZoneInternet, data.ZoneRates, awsDataTransferPricing[defaultPricingRegion])
+}
+
+// nonEmpty returns the non-zero entries of a byte count map, or nil if there
+// are none
+func nonEmpty(counts map[string]uint64) map[string]uint64 {
+	var kept map[string]uint64
+	for key, n := range counts {
+		if n == 0 {
+			continue
+		}
+		if kept == nil {
+			kept = make(map[string]uint64)
+		}
+		kept[key] = n
+	}
+	return kept
+}
+
+// serviceEgressName names a container in egress tables, noting proxies,
+// callers that aren't monitored and shared network namespaces
+func serviceEgressName(e ServiceEgress) string {
+	var notes []string
+	switch {
+	case !e.Monitored:
+		notes = append(notes, "not monitored")
+	case e.ForwardedBytes > 0:
+		notes = append(notes, fmt.Sprintf("proxy, %s attributed to callers", formatBytes(e.ForwardedBytes)))
+	}
+	if len(e.SharedWith) > 0 {
+		notes = append(notes, "network namespace shared with "+strings.Join(e.SharedWith, ", "))
+	}
+	if len(notes) == 0 {
+		return e.Container
+	}
+	return e.Container + " (" + strings.Join(notes, "; ") + ")"
+}
+
+// formatServiceEgress lays out egress by service as aligned text lines
+func formatServiceEgress(services []ServiceEgress) []string {
+	width := 0
+	for _, e := range services {
+		width = max(width, len(e.Container))
+	}
+	lines := make([]string, 0, len(services))
+	for _, e := range services {
+		line := fmt.Sprintf("%-*s  %10s direct  %10s via proxies  $%.2f",
+			width, e.Container, formatBytes(e.DirectBytes), formatBytes(e.ViaProxyBytes), e.CostUSD)
+		if name := serviceEgressName(e); name != e.Container {
+			line += "  " + name[len(e.Container)+1:]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// SplitContainerRef splits a configured container reference of the form
+// "endpoint/name" into its endpoint and container name. Docker container names
+// can't contain "/", so a plain name refers to the local (default) daemon.
+func SplitContainerRef(ref string) (endpoint, name string) {
+	if i := strings.Index(ref, "/"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return "", ref
+}
+
+// ValidateEndpoints checks endpoint names and addresses, and that every
+// container refers to a configured endpoint
+func ValidateEndpoints(config Config) error {
+	if err := ValidateRuntime(config.Runtime); err != nil {
+		return err
+	}
+	if (config.Runtime == RuntimeContainerd || config.Runtime == RuntimeKubernetes) && len(config.Endpoints) > 0 {
+		return fmt.Errorf("endpoints speak the Docker API and can't be used with the %s runtime", config.Runtime)
+	}
+	if config.Runtime == RuntimeKubernetes {
+		if err := ValidateKubernetesTarget(config.Kubernetes); err != nil {
+			return err
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, ep := range config.Endpoints {
+		if ep.Name == "" || strings.ContainsAny(ep.Name, "/ ") {
+			return fmt.Errorf("invalid endpoint name %q", ep.Name)
+		}
+		if names[ep.Name] {
+			return fmt.Errorf("duplicate endpoint %q", ep.Name)
+		}
+		names[ep.Name] = true
+
+		u, err := url.Parse(ep.Host)
+		if err != nil {
+			return fmt.Errorf("invalid host for endpoint %q: %w", ep.Name, err)
+		}
+		switch u.Scheme {
+		case "unix", "tcp", "ssh":
+		default:
+			return fmt.Errorf("unsupported host %q for endpoint %q (expected unix://, tcp:// or ssh://)", ep.Host, ep.Name)
+		}
+		if (ep.TLSCert == "") != (ep.TLSKey == "") {
+			return fmt.Errorf("endpoint %q needs both a TLS certificate and key", ep.Name)
+		}
+		if config.Runtime == RuntimePodman && u.Scheme == "ssh" {
+			return fmt.Errorf("endpoint %q: ssh:// needs a Docker daemon on the remote host; expose Podman's socket over tcp:// instead", ep.Name)
+		}
+	}
+
+	// Aggregated containers are named after the agent that pushed them
+	if config.Aggregated {
+		return nil
+	}
+	for _, ref := range config.Containers {
+		if endpoint, _ := SplitContainerRef(ref); endpoint != "" && !names[endpoint] {
+			return fmt.Errorf("container %q refers to unknown endpoint %q", ref, endpoint)
+		}
+	}
+	return nil
+}
+
+// NewDockerClientForEndpoint creates a client for a named Docker endpoint
+func NewDockerClientForEndpoint(ep DockerEndpoint) (*DockerClient, error) {
+	opts := []client.Opt{client.WithAPIVersionNegotiation()}
+
+	if strings.HasPrefix(ep.Host, "ssh://") {
+		dial, err := sshDialer(ep.Host)
+		if err != nil {
+			return nil, err
+		}
+		// The host is only used to build request URLs; connections go over ssh
+		opts = append(opts,
+			client.WithHost("http://docker.example.com"),
+			client.WithHTTPClient(&http.Client{Transport: &http.Transport{DialContext: dial}}))
+	} else {
+		opts = append(opts, client.WithHost(ep.Host))
+		if ep.TLSCACert != "" || ep.TLSCert != "" {
+			opts = append(opts, client.WithTLSClientConfig(ep.TLSCACert, ep.TLSCert, ep.TLSKey))
+		}
+	}
+
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client for endpoint %s: %w", ep.Name, err)
+	}
+	return &DockerClient{cli: cli}, nil
+}
+
+// sshDialer returns a dialer that reaches a remote Docker daemon by running
+// `docker system dial-stdio` over ssh, like the docker CLI does
+func sshDialer(host string) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ssh host: %w", err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid ssh host %q: no hostname", host)
+	}
+
+	var args []string
+	if u.User != nil {
+		args = append(args, "-l", u.User.Username())
+	}
+	if u.Port() != "" {
+		args = append(args, "-p", u.Port())
+	}
+	args = append(args, "-o", "BatchMode=yes", "--", u.Hostname(), "docker", "system", "dial-stdio")
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		// Not tied to ctx: the connection outlives the dial
+		cmd := exec.Command("ssh", args...)
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		conn := &commandConn{cmd: cmd, stdin: stdin, stdout: stdout, host: u.Host}
+		cmd.Stderr = &conn.stderr
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to run ssh: %w", err)
+		}
+		return conn, nil
+	}, nil
+}
+
+// commandConn is a net.Conn over the stdin and stdout of a command
+type commandConn struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	stdout    io.Reader
+	stderr    bytes.Buffer
+	host      string
+	closeOnce sync.Once
+}
+
+// Read reads from the command's output. When the command exits early (e.g.
+// ssh can't connect), the error includes what it printed on stderr.
+func (c *commandConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err == io.EOF && n == 0 {
+		c.cmd.Wait()
+		if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+			return 0, fmt.Errorf("ssh %s: %s", c.host, msg)
+		}
+	}
+	return n, err
+}
+
+func (c *commandConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+// Close ends the command; the remote dial-stdio exits with it
+func (c *commandConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.stdin.Close()
+		c.cmd.Process.Kill()
+		c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr                { return commandAddr("ssh") }
+func (c *commandConn) RemoteAddr() net.Addr               { return commandAddr(c.host) }
+func (c *commandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// commandAddr is the address of a commandConn
+type commandAddr string
+
+func (a commandAddr) Network() string { return "ssh" }
+func (a commandAddr) String() string  { return string(a) }
+
+// RuntimePool holds one client per endpoint of a configuration. The local
+// runtime selected by the configuration is the endpoint named "". Remote
+// endpoints speak the Docker API (Docker or Podman's compatible socket).
+type RuntimePool struct {
+	mu        sync.Mutex
+	local     Config // Runtime settings of the local endpoint
+	endpoints map[string]DockerEndpoint
+	clients   map[string]Runtime
+}
+
+// NewRuntimePool creates a pool for a configuration's runtime and endpoints.
+// Clients are created on first use.
+func NewRuntimePool(config Config) *RuntimePool {
+	p := &RuntimePool{
+		local:   Config{Name: config.Name, Runtime: config.Runtime, Kubernetes: config.Kubernetes},
+		clients: make(map[string]Runtime),
+	}
+	p.SetEndpoints(config.Endpoints)
+	return p
+}
+
+// SetEndpoints replaces the endpoint definitions, dropping clients of
+// endpoints that were removed or changed
+func (p *RuntimePool) SetEndpoints(endpoints []DockerEndpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	updated := make(map[string]DockerEndpoint)
+	for _, ep := range endpoints {
+		updated[ep.Name] = ep
+	}
+	for name, cli := range p.clients {
+		if name == "" {
+			continue
+		}
+		if ep, ok := updated[name]; !ok || ep != p.endpoints[name] {
+			cli.Close()
+			delete(p.clients, name)
+		}
+	}
+	p.endpoints = updated
+}
+
+// SetKubernetes replaces a kubernetes configuration's target. A running
+// kubelet client picks up selector changes; connection changes reconnect.
+func (p *RuntimePool) SetKubernetes(target *KubernetesTarget) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.local.Kubernetes = target
+	kube, ok := p.clients[""].(*KubeletClient)
+	if !ok || target == nil {
+		return
+	}
+	if !kube.SetTarget(*target) {
+		kube.Close()
+		delete(p.clients, "")
+	}
+}
+
+// Client returns the client for an endpoint
+func (p *RuntimePool) Client(endpoint string) (Runtime, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if cli, ok := p.clients[endpoint]; ok {
+		return cli, nil
+	}
+
+	var cli Runtime
+	var err error
+	if endpoint == "" {
+		cli, err = NewRuntime(p.local)
+	} else if ep, ok := p.endpoints[endpoint]; ok {
+		var docker *DockerClient
+		docker, err = NewDockerClientForEndpoint(ep)
+		if err == nil {
+			docker.podman = p.local.Runtime == RuntimePodman
+			cli = docker
+		}
+	} else {
+		err = fmt.Errorf("unknown endpoint %q", endpoint)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p.clients[endpoint] = cli
+	return cli, nil
+}
+
+// Resolve returns the client and container name for a container reference
+func (p *RuntimePool) Resolve(ref string) (Runtime, string, error) {
+	endpoint, name := SplitContainerRef(ref)
+	cli, err := p.Client(endpoint)
+	if err != nil {
+		return nil, "", err
+	}
+	return cli, name, nil
+}
+
+// Close closes every client in the pool
+func (p *RuntimePool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for name, cli := range p.clients {
+		cli.Close()
+		delete(p.clients, name)
+	}
+}
+
+// ListAllContainers lists running containers on the local runtime and every
+// endpoint, naming remote ones "endpoint/name". Endpoints that can't be
+// reached are returned in failed instead of failing the whole listing.
+func (p *RuntimePool) ListAllContainers(ctx context.Context) (containers []ContainerInfo, failed map[string]error) {
+	p.mu.Lock()
+	var names []string
+	for name := range p.endpoints {
+		names = append(names, name)
+	}
+	p.mu.Unlock()
+	sort.Strings(names)
+	names = append([]string{""}, names...)
+
+	failed = make(map[string]error)
+	for _, endpoint := range names {
+		cli, err := p.Client(endpoint)
+		if err != nil {
+			failed[endpoint] = err
+			continue
+		}
+		list, err := cli.ListContainers(ctx)
+		if err != nil {
+			failed[endpoint] = err
+			continue
+		}
+		for _, c := range list {
+			if endpoint != "" {
+				c.Name = endpoint + "/" + c.Name
+			}
+			containers = append(containers, c)
+		}
+	}
+	return containers, failed
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"html"
 	"os"
 	"strings"
 	"time"
@@ -26,6 +27,20 @@ func Export(configName string, opts ExportOptions) error {
 		allData = filterDataByTime(allData, opts)
 	}
 
+	// Fill in recommendations for data saved before they existed
+	for _, data := range allData {
+		if data.Summary != nil && len(data.Fargate) == 0 {
+			data.Fargate = RecommendFargateBothArchitectures(data.Summary, data.Host)
+		}
+		if data.Summary != nil && data.Recommendation != nil && data.Recommendation.PurchaseOptions == nil {
+			arch := data.Recommendation.Architecture
+			if arch == "" {
+				arch = "x86"
+			}
+			data.Recommendation = RecommendInstanceForHost(data.Summary, arch, data.Host)
+		}
+	}
+
 	// Generate output
 	var output string
 	var outputBytes []byte
@@ -168,6 +183,30 @@ func exportMarkdown(configName string, allData []*ContainerData) (string, error)
 	buf.WriteString(fmt.Sprintf("# Monitoring Report: %s\n\n", configName))
 	buf.WriteString(fmt.Sprintf("Generated: %s\n\n", time.Now().Format(time.RFC3339)))
 
+	if namespaces := SharedNamespaces(allData); len(namespaces) > 0 {
+		buf.WriteString("## Shared Network Namespaces\n\n")
+		buf.WriteString("Containers sharing a network namespace report the same network figures, which count once per namespace.\n\n")
+		buf.WriteString("| Namespace | Containers | Network Rx | Network Tx | Counted Via |\n")
+		buf.WriteString("|-----------|------------|------------|------------|-------------|\n")
+		for _, ns := range namespaces {
+			buf.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s |\n",
+				ns.Name(), strings.Join(sharedNamespaceMembers(ns), ", "), formatBytes(ns.RxBytes), formatBytes(ns.TxBytes), ns.Containers[0]))
+		}
+		buf.WriteString("\n")
+	}
+
+	if services := AttributeEgress(allData); len(services) > 0 {
+		buf.WriteString("## Internet Egress by Service\n\n")
+		buf.WriteString("Egress of monitored proxies is attributed to the containers calling them.\n\n")
+		buf.WriteString("| Container | Direct | Via Proxies | Total | Cost |\n")
+		buf.WriteString("|-----------|--------|-------------|-------|------|\n")
+		for _, e := range services {
+			buf.WriteString(fmt.Sprintf("| %s | %s | %s | %s | $%.2f |\n",
+				serviceEgressName(e), formatBytes(e.DirectBytes), formatBytes(e.ViaProxyBytes), formatBytes(e.TotalBytes()), e.CostUSD))
+		}
+		buf.WriteString("\n")
+	}
+
 	for _, data := range allData {
 		buf.WriteString(fmt.Sprintf("## %s\n\n", data.ContainerName))
 		buf.WriteString(fmt.Sprintf("- **Container ID:** %s\n", data.ContainerID[:12]))
@@ -200,10 +239,64 @@ func exportMarkdown(configName string, allData []*ContainerData) (string, error)
 			buf.WriteString("### Network & I/O Totals\n\n")
 			buf.WriteString(fmt.Sprintf("- **Network Rx:** %s\n", formatBytes(s.NetRxTotal)))
 			buf.WriteString(fmt.Sprintf("- **Network Tx:** %s\n", formatBytes(s.NetTxTotal)))
+			if data.NetworkNamespace != nil {
+				buf.WriteString(fmt.Sprintf("- **Network Namespace:** %s\n", formatNetworkNamespace(data.NetworkNamespace)))
+			}
+			if s.NetworkBreakdown != nil {
+				buf.WriteString(fmt.Sprintf("- **Traffic:** %s\n", formatNetworkBreakdown(s.NetworkBreakdown)))
+			}
 			buf.WriteString(fmt.Sprintf("- **Block Read:** %s\n", formatBytes(s.BlockReadTotal)))
 			buf.WriteString(fmt.Sprintf("- **Block Write:** %s\n", formatBytes(s.BlockWriteTotal)))
 			buf.WriteString("\n")
 
+			if len(s.ZoneBandwidth) > 0 {
+				buf.WriteString("### Bandwidth by Zone\n\n")
+				buf.WriteString("| Zone | Direction | Min | Avg | P95 | Max |\n")
+				buf.WriteString("|------|-----------|-----|-----|-----|-----|\n")
+				for _, zone := range bandwidthZones(s.ZoneBandwidth) {
+					b := s.ZoneBandwidth[zone]
+					for _, dir := range []struct {
+						name  string
+						stats Summary
+					}{{"out", b.Tx}, {"in", b.Rx}} {
+						buf.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s |\n", zone, dir.name,
+							formatRate(dir.stats.Min), formatRate(dir.stats.Avg), formatRate(dir.stats.P95), formatRate(dir.stats.Max)))
+					}
+				}
+				buf.WriteString("\n")
+			}
+
+			if len(s.TopDestinations) > 0 {
+				buf.WriteString("### Top Destinations\n\n")
+				buf.WriteString("| Destination | Class | Bytes | Avg Conns | Max Conns |\n")
+				buf.WriteString("|-------------|-------|-------|-----------|-----------|\n")
+				for _, d := range s.TopDestinations {
+					buf.WriteString(fmt.Sprintf("| %s | %s | %s | %.1f | %d |\n",
+						formatDestination(d.Addr, d.Name), d.Class, formatBytes(d.Bytes), d.AvgConnections, d.MaxConnections))
+				}
+				buf.WriteString("\n")
+			}
+
+			if s.Sockets != nil {
+				buf.WriteString("### Sockets\n\n")
+				for _, line := range formatSocketSummary(s.Sockets) {
+					name, value, _ := strings.Cut(line, ":")
+					buf.WriteString(fmt.Sprintf("- **%s:** %s\n", name, strings.TrimSpace(value)))
+				}
+				buf.WriteString("\n")
+			}
+
+			if len(data.Proxies) > 0 {
+				buf.WriteString("### Proxies\n\n")
+				buf.WriteString("| Container | Image | Classified as | Internet | Reason |\n")
+				buf.WriteString("|-----------|-------|---------------|----------|--------|\n")
+				for _, p := range data.Proxies {
+					buf.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s |\n",
+						p.Container, p.Image, proxyKind(p), proxyInternetShare(p), strings.ReplaceAll(p.Reason, "|", "\\|")))
+				}
+				buf.WriteString("\n")
+			}
+
 			if len(s.Warnings) > 0 {
 				buf.WriteString("### Warnings\n\n")
 				for _, w := range s.Warnings {
@@ -219,6 +312,15 @@ func exportMarkdown(configName string, allData []*ContainerData) (string, error)
 			buf.WriteString(fmt.Sprintf("- **Egress:** %.2f GB\n", data.NetworkCost.EgressGB))
 			buf.WriteString(fmt.Sprintf("- **Estimated Cost:** $%.2f\n", data.NetworkCost.EstimatedCostUSD))
 			buf.WriteString("\n")
+
+			if len(data.NetworkCost.Zones) > 0 {
+				buf.WriteString("| Zone | Egress | Rate | Cost |\n")
+				buf.WriteString("|------|--------|------|------|\n")
+				for _, z := range data.NetworkCost.Zones {
+					buf.WriteString(fmt.Sprintf("| %s | %.2f GB | $%.3f/GB | $%.2f |\n", z.Zone, z.EgressGB, z.PricePerGB, z.CostUSD))
+				}
+				buf.WriteString("\n")
+			}
 		}
 
 		if data.Recommendation != nil {
@@ -229,6 +331,37 @@ func exportMarkdown(configName string, allData []*ContainerData) (string, error)
 				data.Recommendation.MemoryGB))
 			buf.WriteString(fmt.Sprintf("- **Hourly Cost:** $%.4f\n", data.Recommendation.HourlyPrice))
 			buf.WriteString(fmt.Sprintf("- **Reason:** %s\n", data.Recommendation.Reason))
+			if b := data.Recommendation.Burstable; b != nil {
+				buf.WriteString(fmt.Sprintf("- **CPU Credits:** %s\n", b.Note))
+			}
+			buf.WriteString("\n")
+
+			if po := data.Recommendation.PurchaseOptions; po != nil {
+				buf.WriteString("| Purchase Option | Hourly | Monthly |\n")
+				buf.WriteString("|-----------------|--------|---------|\n")
+				for _, opt := range purchaseOptionRows(po) {
+					buf.WriteString(fmt.Sprintf("| %s | $%.4f | $%.2f |\n", opt.label, opt.hourly, opt.hourly*hoursPerMonth))
+				}
+				buf.WriteString("\n")
+			}
+		}
+
+		if len(data.Fargate) > 0 {
+			buf.WriteString("### Fargate Task Size\n\n")
+			buf.WriteString("| Architecture | Task Size | Hourly | Monthly | Spot Monthly |\n")
+			buf.WriteString("|--------------|-----------|--------|---------|--------------|\n")
+			for _, f := range data.Fargate {
+				spot := "n/a"
+				if f.SpotMonthlyPrice > 0 {
+					spot = fmt.Sprintf("$%.2f", f.SpotMonthlyPrice)
+				}
+				buf.WriteString(fmt.Sprintf("| %s | %s | $%.4f | $%.2f | %s |\n",
+					f.Architecture, formatFargateTaskSize(f), f.HourlyPrice, f.MonthlyPrice, spot))
+			}
+			if data.Recommendation != nil {
+				buf.WriteString(fmt.Sprintf("\nEC2 comparison: %s at ~$%.2f/month\n",
+					data.Recommendation.InstanceType, data.Recommendation.MonthlyPrice))
+			}
 			buf.WriteString("\n")
 		}
 
@@ -309,6 +442,17 @@ func exportHTML(configName string, allData []*ContainerData) (string, error) {
             color: #666;
             font-size: 14px;
         }
+        .service-graph { max-width: 100%; height: auto; font-size: 12px; user-select: none; }
+        .graph-node { cursor: move; }
+        .graph-node rect { fill: #e8f0fa; stroke: #205493; stroke-width: 1.5; }
+        .graph-node.peer rect { fill: white; stroke-dasharray: 5 3; }
+        .graph-node.zone rect { fill: #eeeeee; stroke: #888; }
+        .graph-node.selected rect { stroke-width: 3; }
+        .graph-edge line { stroke: #888; }
+        .graph-edge text { fill: #555; font-size: 11px; }
+        .has-selection .graph-edge { opacity: 0.15; }
+        .has-selection .graph-edge.selected { opacity: 1; }
+        .graph-edge.selected line { stroke: #d9534f; }
     </style>
 </head>
 <body>
@@ -316,6 +460,50 @@ func exportHTML(configName string, allData []*ContainerData) (string, error) {
     <p>Generated: ` + time.Now().Format("2006-01-02 15:04:05") + `</p>
 `)
 
+	if namespaces := SharedNamespaces(allData); len(namespaces) > 0 {
+		buf.WriteString(`
+    <div class="container-section">
+        <h2>Shared Network Namespaces</h2>
+        <p>Containers sharing a network namespace report the same network figures, which count once per namespace.</p>
+        <table>
+            <tr><th>Namespace</th><th>Containers</th><th>Network Rx</th><th>Network Tx</th><th>Counted Via</th></tr>
+`)
+		for _, ns := range namespaces {
+			buf.WriteString(fmt.Sprintf(`            <tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>
+`, html.EscapeString(ns.Name()), html.EscapeString(strings.Join(sharedNamespaceMembers(ns), ", ")), formatBytes(ns.RxBytes), formatBytes(ns.TxBytes), html.EscapeString(ns.Containers[0])))
+		}
+		buf.WriteString(`        </table>
+    </div>
+`)
+	}
+
+	if services := AttributeEgress(allData); len(services) > 0 {
+		buf.WriteString(`
+    <div class="container-section">
+        <h2>Internet Egress by Service</h2>
+        <p>Egress of monitored proxies is attributed to the containers calling them.</p>
+        <table>
+            <tr><th>Container</th><th>Direct</th><th>Via Proxies</th><th>Total</th><th>Cost</th></tr>
+`)
+		for _, e := range services {
+			buf.WriteString(fmt.Sprintf(`            <tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>$%.2f</td></tr>
+`, html.EscapeString(serviceEgressName(e)), formatBytes(e.DirectBytes), formatBytes(e.ViaProxyBytes), formatBytes(e.TotalBytes()), e.CostUSD))
+		}
+		buf.WriteString(`        </table>
+    </div>
+`)
+	}
+
+	if graph := BuildServiceGraph(allData); len(graph.Edges) > 0 {
+		buf.WriteString(`
+    <div class="container-section">
+        <h2>Service Graph</h2>
+`)
+		buf.WriteString(renderGraphHTML(graph))
+		buf.WriteString(`    </div>
+`)
+	}
+
 	for i, data := range allData {
 		chartID := fmt.Sprintf("chart%d", i)
 
@@ -326,6 +514,9 @@ func exportHTML(configName string, allData []*ContainerData) (string, error) {
         <p><strong>Host:</strong> %s | <strong>Duration:</strong> %s</p>
 `, data.ContainerName, data.ImageName, data.ContainerID[:12], data.Host.Hostname,
 			data.EndTime.Sub(data.StartTime).Round(time.Second)))
+		if data.NetworkNamespace != nil {
+			buf.WriteString(fmt.Sprintf("        <p><strong>Network namespace:</strong> %s</p>\n", html.EscapeString(formatNetworkNamespace(data.NetworkNamespace))))
+		}
 
 		if data.Summary != nil {
 			s := data.Summary
@@ -376,6 +567,78 @@ func exportHTML(configName string, allData []*ContainerData) (string, error) {
         </table>
 `)
 
+			// Busiest destinations (names come from reverse DNS, so escape them)
+			if len(s.TopDestinations) > 0 {
+				buf.WriteString(`
+        <h3>Top Destinations</h3>
+        <table>
+            <tr><th>Destination</th><th>Class</th><th>Bytes</th><th>Avg Conns</th><th>Max Conns</th></tr>
+`)
+				for _, d := range s.TopDestinations {
+					buf.WriteString(fmt.Sprintf(`            <tr><td>%s</td><td>%s</td><td>%s</td><td>%.1f</td><td>%d</td></tr>
+`, html.EscapeString(formatDestination(d.Addr, d.Name)), html.EscapeString(d.Class), formatBytes(d.Bytes), d.AvgConnections, d.MaxConnections))
+				}
+				buf.WriteString(`        </table>
+`)
+			}
+
+			// Proxy classification (rules come from the config, so escape them)
+			if len(data.Proxies) > 0 {
+				buf.WriteString(`
+        <h3>Proxies</h3>
+        <table>
+            <tr><th>Container</th><th>Image</th><th>Classified as</th><th>Internet</th><th>Reason</th></tr>
+`)
+				for _, p := range data.Proxies {
+					buf.WriteString(fmt.Sprintf(`            <tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>
+`, html.EscapeString(p.Container), html.EscapeString(p.Image), proxyKind(p), proxyInternetShare(p), html.EscapeString(p.Reason)))
+				}
+				buf.WriteString(`        </table>
+`)
+			}
+
+			// Cost comparison: EC2 vs Fargate
+			if data.Recommendation != nil || len(data.Fargate) > 0 {
+				buf.WriteString(`
+        <h3>Compute Cost Options</h3>
+        <table>
+            <tr><th>Option</th><th>Size</th><th>Hourly</th><th>Monthly</th><th>Spot Monthly</th></tr>
+`)
+				if r := data.Recommendation; r != nil {
+					spot := "-"
+					if r.PurchaseOptions != nil {
+						spot = fmt.Sprintf("$%.2f", r.PurchaseOptions.SpotHourly*hoursPerMonth)
+					}
+					buf.WriteString(fmt.Sprintf(`            <tr><td>EC2 %s</td><td>%s (%d vCPU, %.1f GB)</td><td>$%.4f</td><td>$%.2f</td><td>%s</td></tr>
+`, r.Architecture, r.InstanceType, r.VCPU, r.MemoryGB, r.HourlyPrice, r.MonthlyPrice, spot))
+					if po := r.PurchaseOptions; po != nil {
+						for _, opt := range purchaseOptionRows(po)[1:5] {
+							buf.WriteString(fmt.Sprintf(`            <tr><td>EC2 %s %s</td><td>%s</td><td>$%.4f</td><td>$%.2f</td><td>-</td></tr>
+`, r.Architecture, opt.label, r.InstanceType, opt.hourly, opt.hourly*hoursPerMonth))
+						}
+					}
+				}
+				for _, f := range data.Fargate {
+					spot := "n/a"
+					if f.SpotMonthlyPrice > 0 {
+						spot = fmt.Sprintf("$%.2f", f.SpotMonthlyPrice)
+					}
+					buf.WriteString(fmt.Sprintf(`            <tr><td>Fargate %s</td><td>%s</td><td>$%.4f</td><td>$%.2f</td><td>%s</td></tr>
+`, f.Architecture, formatFargateTaskSize(f), f.HourlyPrice, f.MonthlyPrice, spot))
+				}
+				buf.WriteString(`        </table>
+`)
+				if r := data.Recommendation; r != nil && r.Burstable != nil {
+					if r.Burstable.Sustainable {
+						buf.WriteString(fmt.Sprintf(`        <p>%s CPU credits: %s</p>
+`, r.InstanceType, r.Burstable.Note))
+					} else {
+						buf.WriteString(fmt.Sprintf(`        <div class="warning">⚠️ %s CPU credits: %s</div>
+`, r.InstanceType, r.Burstable.Note))
+					}
+				}
+			}
+
 			// Warnings
 			if len(s.Warnings) > 0 {
 				buf.WriteString(`        <h3>Warnings</h3>`)
@@ -430,6 +693,62 @@ func exportHTML(configName string, allData []*ContainerData) (string, error) {
 `, chartID, chartID, generateChartLabels(data.Samples), generateChartData(data.Samples, "cpu"), generateChartData(data.Samples, "mem")))
 		}
 
+		// Per-zone rates as stacked areas, so peaks show next to averages
+		if data.Summary != nil && len(data.Summary.ZoneBandwidth) > 0 {
+			zones := bandwidthZones(data.Summary.ZoneBandwidth)
+			buf.WriteString(`
+        <h3>Bandwidth by Zone</h3>
+        <table>
+            <tr><th>Zone</th><th>Direction</th><th>Min</th><th>Avg</th><th>P95</th><th>Max</th></tr>
+`)
+			for _, zone := range zones {
+				b := data.Summary.ZoneBandwidth[zone]
+				for _, dir := range []struct {
+					name  string
+					stats Summary
+				}{{"out", b.Tx}, {"in", b.Rx}} {
+					buf.WriteString(fmt.Sprintf(`            <tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>
+`, html.EscapeString(zone), dir.name, formatRate(dir.stats.Min), formatRate(dir.stats.Avg), formatRate(dir.stats.P95), formatRate(dir.stats.Max)))
+				}
+			}
+			buf.WriteString(`        </table>
+`)
+			for _, dir := range []struct {
+				id    string
+				title string
+				tx    bool
+			}{{"egress", "Egress by Zone", true}, {"ingress", "Ingress by Zone", false}} {
+				zoneChartID := fmt.Sprintf("%s-%s", chartID, dir.id)
+				buf.WriteString(fmt.Sprintf(`
+        <h4>%s</h4>
+        <div class="chart-container">
+            <canvas id="%s"></canvas>
+        </div>
+        <script>
+            new Chart(document.getElementById('%s'), {
+                type: 'line',
+                data: {
+                    labels: [%s],
+                    datasets: [%s]
+                },
+                options: {
+                    responsive: true,
+                    maintainAspectRatio: false,
+                    interaction: { mode: 'index', intersect: false },
+                    scales: {
+                        y: {
+                            stacked: true,
+                            min: 0,
+                            title: { display: true, text: 'KB/s' }
+                        }
+                    }
+                }
+            });
+        </script>
+`, dir.title, zoneChartID, zoneChartID, generateChartLabels(data.Samples), generateZoneBandwidthDatasets(data.Samples, zones, dir.tx)))
+			}
+		}
+
 		buf.WriteString(`    </div>
 `)
 	}
@@ -456,6 +775,36 @@ func generateChartLabels(samples []Sample) string {
 	return strings.Join(labels, ",")
 }
 
+// zoneChartColors are the colors of zones in stacked charts
+var zoneChartColors = []string{"75, 192, 192", "255, 159, 64", "255, 99, 132", "54, 162, 235", "153, 102, 255", "201, 203, 207"}
+
+// generateZoneBandwidthDatasets generates the Chart.js datasets of a stacked
+// chart of zone rates in KB/s
+func generateZoneBandwidthDatasets(samples []Sample, zones []string, tx bool) string {
+	step := 1
+	if len(samples) > 100 {
+		step = len(samples) / 100
+	}
+
+	var datasets []string
+	for k, zone := range zones {
+		var values []string
+		for i := 0; i < len(samples); i += step {
+			b := samples[i].NetZoneBandwidth[zone]
+			val := b.Rx
+			if tx {
+				val = b.Tx
+			}
+			values = append(values, fmt.Sprintf("%.2f", val/1024))
+		}
+		label, _ := json.Marshal(zone)
+		color := zoneChartColors[k%len(zoneChartColors)]
+		datasets = append(datasets, fmt.Sprintf("{ label: %s, data: [%s], fill: true, pointRadius: 0, borderColor: 'rgb(%s)', backgroundColor: 'rgba(%s, 0.5)' }",
+			label, strings.Join(values, ","), color, color))
+	}
+	return strings.Join(datasets, ", ")
+}
+
 // generateChartData generates JavaScript array of values
 func generateChartData(samples []Sample, metric string) string {
 	var values []string
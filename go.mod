@@ -12,6 +12,7 @@ require (
 	github.com/guptarohit/asciigraph v0.7.3
 	github.com/mattn/go-isatty v0.0.20
 	github.com/spf13/cobra v1.8.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
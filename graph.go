@@ -0,0 +1,484 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Service graph node kinds
+const (
+	GraphNodeContainer = "container" // A monitored container
+	GraphNodePeer      = "peer"      // A container the configuration doesn't monitor
+	GraphNodeZone      = "zone"      // A network zone
+)
+
+// ServiceGraph is the directed dependency graph of a configuration's
+// containers: edges point from the side that opens connections to the other,
+// so from a container to the containers and network zones it calls, and to
+// it from unmonitored containers and zones that call it
+type ServiceGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a container or network zone of a service graph
+type GraphNode struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Kind  string `json:"kind"`
+}
+
+// GraphEdge is the traffic on connections one node opens to another over a
+// session
+type GraphEdge struct {
+	From           string  `json:"from"`
+	To             string  `json:"to"`
+	Bytes          uint64  `json:"bytes,omitempty"`           // Bytes sent and received
+	AvgConnections float64 `json:"avg_connections,omitempty"` // Averaged over all samples
+	MaxConnections int     `json:"max_connections,omitempty"`
+}
+
+// BuildServiceGraph builds the service graph of a configuration from the
+// traffic by peer of its containers' samples, or the top destinations of
+// samples recorded before peers. Traffic with other containers goes to their
+// node, and everything else to the node of its network zone. Calls between
+// monitored containers are counted at the caller. Containers sharing a
+// network namespace see the same traffic, so they share a node, whose edges
+// are the first container's.
+func BuildServiceGraph(allData []*ContainerData) *ServiceGraph {
+	nodes := make(map[string]GraphNode)
+	for _, data := range allData {
+		nodes[data.ContainerName] = GraphNode{ID: data.ContainerName, Label: data.ContainerName, Kind: GraphNodeContainer}
+	}
+	namespaces := SharedNamespaces(allData)
+	counted := namespaceCounted(namespaces)
+	for _, ns := range namespaces {
+		for _, name := range ns.Containers[1:] {
+			delete(nodes, name)
+		}
+		node := nodes[ns.Containers[0]]
+		node.Label = strings.Join(ns.Containers, " + ")
+		nodes[node.ID] = node
+	}
+
+	type edgeKey struct{ from, to string }
+	edges := make(map[edgeKey]*GraphEdge)
+	for _, data := range allData {
+		if len(data.Samples) == 0 || counted[data.ContainerName] != "" && counted[data.ContainerName] != data.ContainerName {
+			continue
+		}
+		connections := make(map[edgeKey]int)
+		for _, s := range data.Samples {
+			perSample := make(map[edgeKey]int)
+			add := func(peer GraphNode, incoming bool, bytes uint64, conns int) {
+				if ref, ok := counted[peer.ID]; ok {
+					peer = nodes[ref]
+				}
+				if incoming && nodes[peer.ID].Kind == GraphNodeContainer {
+					return // Counted at the caller
+				}
+				if _, ok := nodes[peer.ID]; !ok {
+					nodes[peer.ID] = peer
+				}
+				if peer.ID == data.ContainerName {
+					return
+				}
+
+				key := edgeKey{data.ContainerName, peer.ID}
+				if incoming {
+					key = edgeKey{peer.ID, data.ContainerName}
+				}
+				edge, ok := edges[key]
+				if !ok {
+					edge = &GraphEdge{From: key.from, To: key.to}
+					edges[key] = edge
+				}
+				edge.Bytes += bytes
+				perSample[key] += conns
+			}
+			if s.NetPeers != nil {
+				for _, p := range s.NetPeers {
+					add(peerNode(data, p), p.Incoming, p.Bytes, p.Connections)
+				}
+			} else {
+				for _, d := range s.NetDestinations {
+					add(destinationNode(data, d), false, d.Bytes, d.Connections)
+				}
+			}
+			for key, n := range perSample {
+				connections[key] += n
+				edges[key].MaxConnections = max(edges[key].MaxConnections, n)
+			}
+		}
+		for key, n := range connections {
+			edges[key].AvgConnections = float64(n) / float64(len(data.Samples))
+		}
+	}
+
+	graph := &ServiceGraph{}
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	kindOrder := map[string]int{GraphNodeContainer: 0, GraphNodePeer: 1, GraphNodeZone: 2}
+	slices.SortFunc(graph.Nodes, func(a, b GraphNode) int {
+		if c := cmp.Compare(kindOrder[a.Kind], kindOrder[b.Kind]); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	for _, edge := range edges {
+		graph.Edges = append(graph.Edges, *edge)
+	}
+	slices.SortFunc(graph.Edges, func(a, b GraphEdge) int {
+		if c := strings.Compare(a.From, b.From); c != 0 {
+			return c
+		}
+		return strings.Compare(a.To, b.To)
+	})
+	return graph
+}
+
+// peerNode returns the node of a container's peer
+func peerNode(data *ContainerData, p PeerTraffic) GraphNode {
+	if p.Container == "" {
+		return GraphNode{ID: "zone:" + p.Zone, Label: p.Zone, Kind: GraphNodeZone}
+	}
+	container := peerRef(data, p.Container)
+	return GraphNode{ID: container, Label: container, Kind: GraphNodePeer}
+}
+
+// destinationNode returns the node a container's destination belongs to.
+// Samples recorded before destinations carried their container only name
+// inter-container destinations after the container.
+func destinationNode(data *ContainerData, d DestinationSample) GraphNode {
+	container := d.Container
+	if container == "" && d.Class == ZoneInterContainer {
+		container = d.Name
+	}
+	if container == "" {
+		zone := d.Class
+		if zone == "" {
+			zone = ZoneInternet
+		}
+		return GraphNode{ID: "zone:" + zone, Label: zone, Kind: GraphNodeZone}
+	}
+
+	container = peerRef(data, container)
+	return GraphNode{ID: container, Label: container, Kind: GraphNodePeer}
+}
+
+// peerRef turns the name of a container another one talks to into a
+// container reference. Peers are listed by the daemon the container runs on.
+func peerRef(data *ContainerData, name string) string {
+	if data.Endpoint != "" {
+		return data.Endpoint + "/" + name
+	}
+	return name
+}
+
+// node returns a graph's node by ID
+func (g *ServiceGraph) node(id string) GraphNode {
+	for _, node := range g.Nodes {
+		if node.ID == id {
+			return node
+		}
+	}
+	return GraphNode{ID: id, Label: id}
+}
+
+// weight scales an edge between 0 and 1 against the heaviest edge, by bytes
+// when the graph has byte counts and by connections otherwise
+func (g *ServiceGraph) weight(e GraphEdge) float64 {
+	var maxBytes uint64
+	var maxConns float64
+	for _, edge := range g.Edges {
+		maxBytes = max(maxBytes, edge.Bytes)
+		maxConns = max(maxConns, edge.AvgConnections)
+	}
+	switch {
+	case maxBytes > 0:
+		return float64(e.Bytes) / float64(maxBytes)
+	case maxConns > 0:
+		return e.AvgConnections / maxConns
+	}
+	return 0
+}
+
+// formatGraphEdge describes an edge's bytes and connections
+func formatGraphEdge(e GraphEdge) string {
+	var parts []string
+	if e.Bytes > 0 {
+		parts = append(parts, formatBytes(e.Bytes))
+	}
+	if e.MaxConnections > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f conns avg, %d max", e.AvgConnections, e.MaxConnections))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// renderGraphDOT renders a service graph in Graphviz DOT
+func renderGraphDOT(name string, g *ServiceGraph) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "digraph %s {\n", dotQuote(name))
+	buf.WriteString("    rankdir=LR;\n")
+	buf.WriteString("    node [shape=box, style=rounded, fontname=\"Helvetica\"];\n")
+	buf.WriteString("    edge [fontname=\"Helvetica\", fontsize=10];\n")
+	for _, node := range g.Nodes {
+		switch node.Kind {
+		case GraphNodePeer:
+			fmt.Fprintf(&buf, "    %s [label=%s, style=\"rounded,dashed\"];\n", dotQuote(node.ID), dotQuote(node.Label))
+		case GraphNodeZone:
+			fmt.Fprintf(&buf, "    %s [label=%s, shape=ellipse, style=filled, fillcolor=\"#eeeeee\"];\n", dotQuote(node.ID), dotQuote(node.Label))
+		default:
+			fmt.Fprintf(&buf, "    %s [label=%s];\n", dotQuote(node.ID), dotQuote(node.Label))
+		}
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&buf, "    %s -> %s [label=%s, penwidth=%.1f];\n",
+			dotQuote(edge.From), dotQuote(edge.To), dotQuote(formatGraphEdge(edge)), 1+4*g.weight(edge))
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// dotQuote quotes a DOT ID
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// renderGraphMermaid renders a service graph as a Mermaid flowchart
+func renderGraphMermaid(g *ServiceGraph) string {
+	ids := make(map[string]string)
+	var buf strings.Builder
+	buf.WriteString("flowchart LR\n")
+	for i, node := range g.Nodes {
+		ids[node.ID] = fmt.Sprintf("n%d", i)
+		switch node.Kind {
+		case GraphNodePeer:
+			fmt.Fprintf(&buf, "    %s[%s]:::peer\n", ids[node.ID], mermaidQuote(node.Label))
+		case GraphNodeZone:
+			fmt.Fprintf(&buf, "    %s([%s]):::zone\n", ids[node.ID], mermaidQuote(node.Label))
+		default:
+			fmt.Fprintf(&buf, "    %s[%s]\n", ids[node.ID], mermaidQuote(node.Label))
+		}
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&buf, "    %s -->|%s| %s\n", ids[edge.From], mermaidQuote(formatGraphEdge(edge)), ids[edge.To])
+	}
+	buf.WriteString("    classDef peer stroke-dasharray: 5 5\n")
+	buf.WriteString("    classDef zone fill:#eeeeee\n")
+	return buf.String()
+}
+
+// mermaidQuote quotes a Mermaid label
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
+
+// Service graph layout in the HTML export
+const (
+	graphNodeWidth  = 170
+	graphNodeHeight = 40
+	graphColumnGap  = 250
+	graphRowGap     = 80
+	graphMargin     = 20
+)
+
+// layoutGraph places a service graph's nodes in columns: containers by their
+// depth in the graph, then network zones last. It returns the top left corner
+// of each node and the size of the drawing.
+func layoutGraph(g *ServiceGraph) (positions map[string][2]int, width, height int) {
+	// Longest path from a container nothing calls, stopping at cycles
+	rank := make(map[string]int)
+	for range g.Nodes {
+		changed := false
+		for _, edge := range g.Edges {
+			if g.node(edge.From).Kind == GraphNodeZone || g.node(edge.To).Kind == GraphNodeZone || rank[edge.To] > rank[edge.From] {
+				continue
+			}
+			if rank[edge.From]+1 < len(g.Nodes) {
+				rank[edge.To] = rank[edge.From] + 1
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	zoneRank := 0
+	for _, node := range g.Nodes {
+		if node.Kind != GraphNodeZone {
+			zoneRank = max(zoneRank, rank[node.ID]+1)
+		}
+	}
+
+	positions = make(map[string][2]int)
+	rows := make(map[int]int)
+	for _, node := range g.Nodes {
+		column := rank[node.ID]
+		if node.Kind == GraphNodeZone {
+			column = zoneRank
+		}
+		x := graphMargin + column*graphColumnGap
+		y := graphMargin + rows[column]*graphRowGap
+		rows[column]++
+		positions[node.ID] = [2]int{x, y}
+		width = max(width, x+graphNodeWidth+graphMargin)
+		height = max(height, y+graphNodeHeight+graphMargin)
+	}
+	return positions, width, height
+}
+
+// renderGraphHTML renders a service graph as an SVG that can be rearranged
+// by dragging nodes; clicking a node highlights its edges
+func renderGraphHTML(g *ServiceGraph) string {
+	positions, width, height := layoutGraph(g)
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, `        <svg id="service-graph" class="service-graph" width="%d" height="%d" viewBox="0 0 %d %d">
+            <defs>
+                <marker id="graph-arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse">
+                    <path d="M 0 0 L 10 5 L 0 10 z" fill="#888"/>
+                </marker>
+            </defs>
+`, width, height, width, height)
+	for _, edge := range g.Edges {
+		weight := formatGraphEdge(edge)
+		fmt.Fprintf(&buf, `            <g class="graph-edge" data-from="%s" data-to="%s">
+                <line stroke-width="%.1f" marker-end="url(#graph-arrow)"><title>%s</title></line>
+                <text text-anchor="middle">%s</text>
+            </g>
+`, html.EscapeString(edge.From), html.EscapeString(edge.To), 1+4*g.weight(edge),
+			html.EscapeString(edge.From+" → "+g.node(edge.To).Label+": "+weight), html.EscapeString(weight))
+	}
+	for _, node := range g.Nodes {
+		pos := positions[node.ID]
+		fmt.Fprintf(&buf, `            <g class="graph-node %s" data-id="%s" transform="translate(%d,%d)">
+                <rect width="%d" height="%d" rx="%d"/>
+                <text x="%d" y="%d" text-anchor="middle">%s</text>
+                <title>%s (%s)</title>
+            </g>
+`, node.Kind, html.EscapeString(node.ID), pos[0], pos[1], graphNodeWidth, graphNodeHeight, graphNodeRadius(node),
+			graphNodeWidth/2, graphNodeHeight/2+5, html.EscapeString(node.Label), html.EscapeString(node.Label), node.Kind)
+	}
+	buf.WriteString(`        </svg>
+        <p class="metric-label">Drag nodes to rearrange them; click a node to highlight its traffic. Dashed nodes are containers this configuration doesn't monitor.</p>
+        <script>
+        (function() {
+            const svg = document.getElementById('service-graph');
+            const nodes = {};
+            svg.querySelectorAll('.graph-node').forEach(function(el) {
+                const m = /translate\(([-\d.]+),([-\d.]+)\)/.exec(el.getAttribute('transform'));
+                nodes[el.dataset.id] = { el: el, x: +m[1], y: +m[2] };
+            });
+            const w = ` + fmt.Sprint(graphNodeWidth) + `, h = ` + fmt.Sprint(graphNodeHeight) + `;
+
+            // Ends a line at the border of a node's box
+            function clip(from, to) {
+                const dx = to.x - from.x, dy = to.y - from.y;
+                const s = Math.min(dx ? (w / 2) / Math.abs(dx) : Infinity, dy ? (h / 2) / Math.abs(dy) : Infinity, 1);
+                return { x: to.x - dx * s, y: to.y - dy * s };
+            }
+            function update() {
+                svg.querySelectorAll('.graph-edge').forEach(function(el) {
+                    const a = nodes[el.dataset.from], b = nodes[el.dataset.to];
+                    const ca = { x: a.x + w / 2, y: a.y + h / 2 }, cb = { x: b.x + w / 2, y: b.y + h / 2 };
+                    const start = clip(cb, ca), end = clip(ca, cb);
+                    const line = el.querySelector('line'), text = el.querySelector('text');
+                    line.setAttribute('x1', start.x); line.setAttribute('y1', start.y);
+                    line.setAttribute('x2', end.x); line.setAttribute('y2', end.y);
+                    text.setAttribute('x', (start.x + end.x) / 2); text.setAttribute('y', (start.y + end.y) / 2 - 6);
+                });
+            }
+
+            let dragging = null, moved = false, selected = null;
+            function point(e) {
+                const p = svg.createSVGPoint();
+                p.x = e.clientX; p.y = e.clientY;
+                return p.matrixTransform(svg.getScreenCTM().inverse());
+            }
+            Object.values(nodes).forEach(function(n) {
+                n.el.addEventListener('mousedown', function(e) {
+                    const p = point(e);
+                    dragging = { node: n, dx: p.x - n.x, dy: p.y - n.y };
+                    moved = false;
+                    e.preventDefault();
+                });
+            });
+            svg.addEventListener('mousemove', function(e) {
+                if (!dragging) return;
+                const p = point(e), n = dragging.node;
+                n.x = p.x - dragging.dx; n.y = p.y - dragging.dy;
+                n.el.setAttribute('transform', 'translate(' + n.x + ',' + n.y + ')');
+                moved = true;
+                update();
+            });
+            window.addEventListener('mouseup', function() {
+                if (dragging && !moved) {
+                    const id = dragging.node.el.dataset.id;
+                    selected = selected === id ? null : id;
+                    svg.classList.toggle('has-selection', selected !== null);
+                    svg.querySelectorAll('.graph-edge').forEach(function(el) {
+                        el.classList.toggle('selected', el.dataset.from === selected || el.dataset.to === selected);
+                    });
+                    Object.values(nodes).forEach(function(n) {
+                        n.el.classList.toggle('selected', n.el.dataset.id === selected);
+                    });
+                }
+                dragging = null;
+            });
+            update();
+        })();
+        </script>
+`)
+	return buf.String()
+}
+
+// graphNodeRadius rounds zone nodes into pills
+func graphNodeRadius(node GraphNode) int {
+	if node.Kind == GraphNodeZone {
+		return graphNodeHeight / 2
+	}
+	return 6
+}
+
+// Graph renders the service graph of a configuration's saved data
+func Graph(configName string, opts ExportOptions) error {
+	allData, err := LoadAllContainerData(configName)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+	if len(allData) == 0 {
+		return fmt.Errorf("no monitoring data found for '%s'", configName)
+	}
+	allData = filterDataByTime(allData, opts)
+
+	graph := BuildServiceGraph(allData)
+	if len(graph.Edges) == 0 {
+		return fmt.Errorf("no network destinations recorded for '%s'", configName)
+	}
+
+	var output string
+	switch opts.Format {
+	case "dot":
+		output = renderGraphDOT(configName, graph)
+	case "mermaid":
+		output = renderGraphMermaid(graph)
+	default:
+		return fmt.Errorf("unsupported format: %s", opts.Format)
+	}
+
+	if opts.Output != "" {
+		if err := os.WriteFile(opts.Output, []byte(output), 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		fmt.Printf("Graph written to %s\n", opts.Output)
+		return nil
+	}
+	fmt.Print(output)
+	return nil
+}
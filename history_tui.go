@@ -358,7 +358,7 @@ func (m HistoryTUIModel) renderContainerContent() string {
 
 	// Calculate network cost if not present
 	if data.NetworkCost == nil && data.Summary != nil {
-		data.NetworkCost = CalculateNetworkCost(data.Summary.NetTxTotal)
+		data.NetworkCost = CalculateNetworkCost(data)
 	}
 
 	var s strings.Builder
@@ -378,7 +378,10 @@ func (m HistoryTUIModel) renderContainerContent() string {
 	s.WriteString(fmt.Sprintf("  CPU: %s (%d cores, %s)\n", data.Host.CPUModel, data.Host.CPUCores, data.Host.Architecture))
 	s.WriteString(fmt.Sprintf("  Memory: %s\n", formatBytes(data.Host.MemoryTotal)))
 	s.WriteString(fmt.Sprintf("  OS: %s (kernel %s)\n", data.Host.OS, data.Host.KernelVer))
-	s.WriteString(fmt.Sprintf("  Docker: %s\n", data.Host.DockerVer))
+	s.WriteString(fmt.Sprintf("  Runtime: %s\n", runtimeVersionLabel(data.Host)))
+	if data.Host.CPUScore > 0 {
+		s.WriteString(fmt.Sprintf("  CPU score: %.2fx reference vCPU (%s)\n", data.Host.CPUScore, data.Host.CPUScoreSource))
+	}
 
 	// Architecture warning
 	if strings.Contains(strings.ToLower(data.Host.Architecture), "arm") ||
@@ -468,6 +471,22 @@ func (m HistoryTUIModel) renderContainerContent() string {
 			asciigraph.Caption(fmt.Sprintf("Total Ingress: %s", formatBytes(data.Summary.NetRxTotal))))
 		s.WriteString(netRxGraph)
 		s.WriteString("\n\n")
+
+		// Per-zone rates, stacked so the top line is the total
+		if len(data.Summary.ZoneBandwidth) > 0 {
+			zones := bandwidthZones(data.Summary.ZoneBandwidth)
+			for _, dir := range []struct {
+				title string
+				tx    bool
+			}{{"Egress by Zone (KB/s, stacked)", true}, {"Ingress by Zone (KB/s, stacked)", false}} {
+				series := stackedZoneBandwidth(data.Samples, zones, step, dir.tx)
+				s.WriteString(dir.title + "\n")
+				s.WriteString(asciigraph.PlotMany(series, asciigraph.Height(8), asciigraph.Width(70),
+					asciigraph.SeriesColors(zoneGraphColors(len(zones))...),
+					asciigraph.SeriesLegends(zones...)))
+				s.WriteString("\n\n")
+			}
+		}
 	}
 
 	// Summary Statistics
@@ -484,20 +503,36 @@ func (m HistoryTUIModel) renderContainerContent() string {
 		s.WriteString(fmt.Sprintf("  Net I/O:  rx=%s tx=%s\n",
 			formatBytes(sum.NetRxTotal),
 			formatBytes(sum.NetTxTotal)))
+		if data.NetworkNamespace != nil {
+			s.WriteString("  Net namespace: " + formatNetworkNamespace(data.NetworkNamespace) + "\n")
+		}
 
 		// Network breakdown (if available)
 		if sum.NetworkBreakdown != nil {
-			s.WriteString("  Traffic:  ")
-			if sum.NetworkBreakdown.InterContainerPct > 0 {
-				s.WriteString(fmt.Sprintf("inter-container=%.1f%% ", sum.NetworkBreakdown.InterContainerPct))
+			s.WriteString("  Traffic:  " + formatNetworkBreakdown(sum.NetworkBreakdown) + "\n")
+		}
+		if len(sum.ZoneBandwidth) > 0 {
+			s.WriteString("  Bandwidth by zone:\n")
+			for _, line := range formatZoneBandwidth(sum.ZoneBandwidth) {
+				s.WriteString("    " + line + "\n")
 			}
-			if sum.NetworkBreakdown.InternalPct > 0 {
-				s.WriteString(fmt.Sprintf("internal=%.1f%% ", sum.NetworkBreakdown.InternalPct))
+		}
+		if len(sum.TopDestinations) > 0 {
+			s.WriteString("  Top destinations:\n")
+			for _, line := range formatTopDestinations(sum.TopDestinations) {
+				s.WriteString("    " + line + "\n")
 			}
-			if sum.NetworkBreakdown.InternetPct > 0 {
-				s.WriteString(fmt.Sprintf("internet=%.1f%%", sum.NetworkBreakdown.InternetPct))
+		}
+		if sum.Sockets != nil {
+			for _, line := range formatSocketSummary(sum.Sockets) {
+				s.WriteString("  " + line + "\n")
+			}
+		}
+		if len(data.Proxies) > 0 {
+			s.WriteString("  Proxies:\n")
+			for _, line := range formatProxies(data.Proxies) {
+				s.WriteString("    " + line + "\n")
 			}
-			s.WriteString("\n")
 		}
 
 		s.WriteString(fmt.Sprintf("  Block I/O: read=%s write=%s\n",
@@ -516,6 +551,15 @@ func (m HistoryTUIModel) renderContainerContent() string {
 		}
 	}
 
+	// Reconfigurations during the session
+	if len(data.Events) > 0 {
+		s.WriteString("Session Events:\n")
+		for _, e := range data.Events {
+			s.WriteString(fmt.Sprintf("  %s  %s: %s\n", e.Time.Format("15:04:05"), e.Type, e.Message))
+		}
+		s.WriteString("\n")
+	}
+
 	// Network Cost with Monthly Projection
 	if data.NetworkCost != nil {
 		s.WriteString(fmt.Sprintf("AWS Network Cost Estimate (%s):\n", data.NetworkCost.Region))
@@ -523,6 +567,9 @@ func (m HistoryTUIModel) renderContainerContent() string {
 			data.NetworkCost.EgressGB,
 			data.NetworkCost.PricePerGB,
 			data.NetworkCost.EstimatedCostUSD))
+		for _, z := range data.NetworkCost.Zones {
+			s.WriteString(fmt.Sprintf("    %-20s %.2f GB @ $%.3f/GB = $%.2f\n", z.Zone+":", z.EgressGB, z.PricePerGB, z.CostUSD))
+		}
 
 		// Calculate monthly projection
 		duration := data.EndTime.Sub(data.StartTime)
@@ -542,21 +589,22 @@ func (m HistoryTUIModel) renderContainerContent() string {
 
 	// AWS Instance Recommendations (both x86 and ARM)
 	if data.Summary != nil {
-		x86Rec, armRec := RecommendBothArchitectures(data.Summary)
+		x86Rec, armRec := RecommendBothArchitectures(data.Summary, data.Host)
 
 		s.WriteString("AWS Instance Recommendations:\n\n")
 
 		if x86Rec != nil {
-			monthlyPrice := x86Rec.HourlyPrice * 730 // hours in month
 			s.WriteString("  x86_64 (Intel/AMD):\n")
 			s.WriteString(fmt.Sprintf("    Instance: %s (%d vCPU, %.0f GB RAM)\n",
 				x86Rec.InstanceType, x86Rec.VCPU, x86Rec.MemoryGB))
-			s.WriteString(fmt.Sprintf("    Cost: $%.4f/hour (~$%.2f/month)\n", x86Rec.HourlyPrice, monthlyPrice))
+			s.WriteString(fmt.Sprintf("    Cost: $%.4f/hour (~$%.2f/month)\n", x86Rec.HourlyPrice, x86Rec.MonthlyPrice))
+			for _, line := range formatPurchaseOptions(x86Rec) {
+				s.WriteString("    " + line + "\n")
+			}
 			s.WriteString(fmt.Sprintf("    Reason: %s\n\n", x86Rec.Reason))
 		}
 
 		if armRec != nil {
-			monthlyPrice := armRec.HourlyPrice * 730
 			savings := 0.0
 			if x86Rec != nil {
 				savings = ((x86Rec.HourlyPrice - armRec.HourlyPrice) / x86Rec.HourlyPrice) * 100
@@ -564,16 +612,50 @@ func (m HistoryTUIModel) renderContainerContent() string {
 			s.WriteString("  ARM64 (Graviton):\n")
 			s.WriteString(fmt.Sprintf("    Instance: %s (%d vCPU, %.0f GB RAM)\n",
 				armRec.InstanceType, armRec.VCPU, armRec.MemoryGB))
-			s.WriteString(fmt.Sprintf("    Cost: $%.4f/hour (~$%.2f/month)", armRec.HourlyPrice, monthlyPrice))
+			s.WriteString(fmt.Sprintf("    Cost: $%.4f/hour (~$%.2f/month)", armRec.HourlyPrice, armRec.MonthlyPrice))
 			if savings > 0 {
 				s.WriteString(fmt.Sprintf(" [%.0f%% cheaper than x86]", savings))
 			}
-			s.WriteString(fmt.Sprintf("\n    Reason: %s\n\n", armRec.Reason))
+			s.WriteString("\n")
+			for _, line := range formatPurchaseOptions(armRec) {
+				s.WriteString("    " + line + "\n")
+			}
+			s.WriteString(fmt.Sprintf("    Reason: %s\n\n", armRec.Reason))
+		}
+
+		// Fargate task sizes (alternative to EC2)
+		fargateRecs := data.Fargate
+		if len(fargateRecs) == 0 {
+			fargateRecs = RecommendFargateBothArchitectures(data.Summary, data.Host)
+		}
+		if len(fargateRecs) > 0 {
+			s.WriteString("  AWS Fargate (ECS task size):\n")
+			for _, f := range fargateRecs {
+				label := "x86_64"
+				ec2Rec := x86Rec
+				if f.Architecture == "arm" {
+					label = "ARM64 "
+					ec2Rec = armRec
+				}
+				s.WriteString(fmt.Sprintf("    %s: %s - $%.4f/hour (~$%.2f/month)",
+					label, formatFargateTaskSize(f), f.HourlyPrice, f.MonthlyPrice))
+				if f.SpotHourlyPrice > 0 {
+					s.WriteString(fmt.Sprintf(", Spot ~$%.2f/month", f.SpotMonthlyPrice))
+				}
+				if ec2Rec != nil {
+					s.WriteString(fmt.Sprintf(" [EC2 %s: ~$%.2f/month]", ec2Rec.InstanceType, ec2Rec.MonthlyPrice))
+				}
+				s.WriteString("\n")
+			}
+			s.WriteString("\n")
 		}
 
 		// Architecture note
 		hostArch := strings.ToLower(data.Host.Architecture)
-		if strings.Contains(hostArch, "arm") || strings.Contains(hostArch, "aarch") {
+		if data.Host.CPUScore > 0 {
+			s.WriteString(fmt.Sprintf("  ℹ️  vCPU requirements normalized using host CPU score %.2f (%s).\n",
+				data.Host.CPUScore, data.Host.CPUScoreSource))
+		} else if strings.Contains(hostArch, "arm") || strings.Contains(hostArch, "aarch") {
 			s.WriteString("  ℹ️  Measured on ARM hardware. Performance may differ on x86 instances.\n")
 		} else if strings.Contains(hostArch, "x86") || strings.Contains(hostArch, "amd64") {
 			s.WriteString("  ℹ️  Measured on x86 hardware. ARM instances may perform differently.\n")
@@ -585,3 +667,13 @@ func (m HistoryTUIModel) renderContainerContent() string {
 
 	return s.String()
 }
+
+// zoneGraphColors picks a color for each zone series of a graph
+func zoneGraphColors(n int) []asciigraph.AnsiColor {
+	palette := []asciigraph.AnsiColor{asciigraph.Green, asciigraph.Yellow, asciigraph.Red, asciigraph.Blue, asciigraph.Magenta, asciigraph.Cyan}
+	colors := make([]asciigraph.AnsiColor, n)
+	for i := range colors {
+		colors[i] = palette[i%len(palette)]
+	}
+	return colors
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Environment variables for running mdok inside a container
+const (
+	envInContainer = "MDOK_IN_CONTAINER" // "1" or "0" overrides container detection
+	envHostProc    = "MDOK_HOST_PROC"    // Where the host's /proc is mounted
+	envHostSys     = "MDOK_HOST_SYS"     // Where the host's /sys is mounted
+)
+
+// Default mount points of the host's /proc and /sys in container mode
+const (
+	defaultHostProc = "/host/proc"
+	defaultHostSys  = "/host/sys"
+)
+
+// Container IDs in cgroup and mount paths, and the short ID Docker uses as hostname
+var (
+	containerIDPattern      = regexp.MustCompile(`[0-9a-f]{64}`)
+	shortContainerIDPattern = regexp.MustCompile(`^[0-9a-f]{12}$`)
+)
+
+// HostPaths locates the host's /proc and /sys. They are mdok's own unless it
+// runs in a container, where the host's are expected under mount points.
+type HostPaths struct {
+	InContainer bool
+	Proc        string
+	Sys         string
+	SelfID      string   // mdok's own container ID (full or 12-char), if known
+	Warnings    []string // Missing mounts and other setup problems
+}
+
+// hostPaths is detected once at startup
+var hostPaths HostPaths
+
+// DetectHostPaths works out whether mdok runs in a container and where the
+// host's /proc and /sys are. Missing mounts fall back to mdok's own view.
+func DetectHostPaths() HostPaths {
+	paths := HostPaths{Proc: "/proc", Sys: "/sys"}
+
+	switch os.Getenv(envInContainer) {
+	case "1", "true":
+		paths.InContainer = true
+	case "0", "false":
+		paths.InContainer = false
+	default:
+		paths.InContainer = runningInContainer()
+	}
+	if !paths.InContainer {
+		return paths
+	}
+
+	paths.SelfID = selfContainerID()
+
+	proc := envOrDefault(envHostProc, defaultHostProc)
+	if _, err := os.Stat(filepath.Join(proc, "meminfo")); err == nil {
+		paths.Proc = proc
+	} else {
+		paths.Warnings = append(paths.Warnings,
+			"host /proc is not mounted at "+proc+" (add -v /proc:"+proc+":ro); using the container's own view")
+	}
+
+	sys := envOrDefault(envHostSys, defaultHostSys)
+	if _, err := os.Stat(HostPaths{Sys: sys}.CgroupPath()); err == nil {
+		paths.Sys = sys
+	} else {
+		paths.Warnings = append(paths.Warnings,
+			"host /sys is not mounted at "+sys+" (add -v /sys:"+sys+":ro); using the container's own view")
+	}
+
+	return paths
+}
+
+// ProcPath returns a path under the host's /proc
+func (p HostPaths) ProcPath(elem ...string) string {
+	return filepath.Join(append([]string{p.Proc}, elem...)...)
+}
+
+// CgroupPath returns a path under the host's cgroup filesystem
+func (p HostPaths) CgroupPath(elem ...string) string {
+	return filepath.Join(append([]string{p.Sys, "fs", "cgroup"}, elem...)...)
+}
+
+// IsSelf reports whether a container ID belongs to mdok's own container
+func (p HostPaths) IsSelf(containerID string) bool {
+	if p.SelfID == "" {
+		return false
+	}
+	return strings.HasPrefix(containerID, p.SelfID) || strings.HasPrefix(p.SelfID, containerID)
+}
+
+// runningInContainer checks the markers left by Docker and Podman, and the
+// cgroup of PID 1 on cgroup v1 hosts
+func runningInContainer() bool {
+	for _, marker := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+
+	data, err := os.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	for _, name := range []string{"docker", "containerd", "kubepods", "libpod"} {
+		if strings.Contains(string(data), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// selfContainerID finds mdok's own container ID from its mounts or cgroup,
+// falling back to the hostname Docker sets to the short ID
+func selfContainerID() string {
+	// Docker bind-mounts /etc/hostname etc. from .../containers/<id>/
+	if data, err := os.ReadFile("/proc/self/mountinfo"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if !strings.Contains(line, "containers/") {
+				continue
+			}
+			if id := containerIDPattern.FindString(line); id != "" {
+				return id
+			}
+		}
+	}
+
+	if data, err := os.ReadFile("/proc/self/cgroup"); err == nil {
+		if id := containerIDPattern.FindString(string(data)); id != "" {
+			return id
+		}
+	}
+
+	if hostname, err := os.Hostname(); err == nil && shortContainerIDPattern.MatchString(hostname) {
+		return hostname
+	}
+	return ""
+}
+
+// hostInfoFromProc reads what it can about the host from its /proc, for when
+// the Docker daemon can't tell us
+func hostInfoFromProc() HostInfo {
+	info := HostInfo{
+		CPUModel:     getCPUModel(),
+		CPUCores:     countCPUs(),
+		MemoryTotal:  readMemTotal(),
+		Architecture: hostArchitecture(),
+	}
+
+	if release, err := os.ReadFile(hostPaths.ProcPath("sys", "kernel", "osrelease")); err == nil {
+		info.KernelVer = strings.TrimSpace(string(release))
+	}
+
+	// Inside a container the hostname is the container's own
+	if !hostPaths.InContainer {
+		info.Hostname, _ = os.Hostname()
+	}
+
+	return info
+}
+
+// countCPUs counts the processors listed in the host's /proc/cpuinfo
+func countCPUs() int {
+	file, err := os.Open(hostPaths.ProcPath("cpuinfo"))
+	if err != nil {
+		return runtime.NumCPU()
+	}
+	defer file.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "processor") {
+			count++
+		}
+	}
+	if count == 0 {
+		return runtime.NumCPU()
+	}
+	return count
+}
+
+// readMemTotal returns MemTotal from the host's /proc/meminfo in bytes
+func readMemTotal() uint64 {
+	file, err := os.Open(hostPaths.ProcPath("meminfo"))
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kb * 1024
+		}
+	}
+	return 0
+}
+
+// hostArchitecture returns the architecture in the form Docker reports it
+func hostArchitecture() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	}
+	return runtime.GOARCH
+}
+
+// envOrDefault returns an environment variable, or a default when it's unset
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
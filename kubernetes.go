@@ -0,0 +1,910 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kubernetes defaults
+const (
+	defaultKubeletURL     = "https://127.0.0.1:10250"
+	defaultKubeNamespace  = "default"
+	serviceAccountToken   = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	kubeletRequestTimeout = 15 * time.Second
+	kubeletCacheTTL       = 500 * time.Millisecond // Shared by the containers of one collection round
+	kubeletPollInterval   = 5 * time.Second        // How often pods are compared for start/stop events
+	kubeSyncInterval      = 15 * time.Second       // How often the daemon re-matches the selector
+)
+
+// Sample sources of a kubernetes configuration
+const (
+	KubeSourceKubelet = "kubelet"
+	KubeSourceCgroups = "cgroups"
+)
+
+// kubeBuildInfoPattern finds the kubelet version in its /metrics output
+var kubeBuildInfoPattern = regexp.MustCompile(`^kubernetes_build_info\{.*git_version="([^"]+)"`)
+
+// KubeletClient monitors Kubernetes pods through a kubelet's API. Pods and
+// their requests and limits come from /pods; samples come from /stats/summary
+// or, with the cgroups source, from the node's cgroups (mdok must then run on
+// the node, e.g. as a DaemonSet with the host's /proc and /sys mounted).
+type KubeletClient struct {
+	mu         sync.Mutex
+	target     KubernetesTarget
+	http       *http.Client
+	base       string
+	pods       *kubePodList
+	podsAt     time.Time
+	summary    *kubeSummary
+	summaryAt  time.Time
+	cgroupDirs map[string]string // Container ID -> cgroup directory
+}
+
+// kubePodList is the part of the kubelet's /pods response mdok uses
+type kubePodList struct {
+	Items []kubePod `json:"items"`
+}
+
+type kubePod struct {
+	Metadata struct {
+		Name      string            `json:"name"`
+		Namespace string            `json:"namespace"`
+		UID       string            `json:"uid"`
+		Labels    map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers  []kubeContainer `json:"containers"`
+		HostNetwork bool            `json:"hostNetwork"`
+	} `json:"spec"`
+	Status struct {
+		Phase             string                `json:"phase"`
+		PodIP             string                `json:"podIP"`
+		PodIPs            []struct{ IP string } `json:"podIPs"`
+		ContainerStatuses []kubeContainerStatus `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+type kubeContainer struct {
+	Name      string `json:"name"`
+	Image     string `json:"image"`
+	Resources struct {
+		Requests map[string]string `json:"requests"`
+		Limits   map[string]string `json:"limits"`
+	} `json:"resources"`
+}
+
+type kubeContainerStatus struct {
+	Name         string `json:"name"`
+	ContainerID  string `json:"containerID"` // "<runtime>://<id>"
+	Ready        bool   `json:"ready"`
+	RestartCount int    `json:"restartCount"`
+	State        struct {
+		Running *struct {
+			StartedAt time.Time `json:"startedAt"`
+		} `json:"running"`
+	} `json:"state"`
+}
+
+// id returns the container ID without the runtime prefix
+func (s kubeContainerStatus) id() string {
+	if i := strings.Index(s.ContainerID, "://"); i >= 0 {
+		return s.ContainerID[i+3:]
+	}
+	return s.ContainerID
+}
+
+// kubeSummary is the part of the kubelet's /stats/summary response mdok uses
+type kubeSummary struct {
+	Node struct {
+		NodeName string          `json:"nodeName"`
+		Memory   kubeMemoryStats `json:"memory"`
+	} `json:"node"`
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		Containers []struct {
+			Name string `json:"name"`
+			CPU  struct {
+				UsageNanoCores       uint64 `json:"usageNanoCores"`
+				UsageCoreNanoSeconds uint64 `json:"usageCoreNanoSeconds"`
+			} `json:"cpu"`
+			Memory kubeMemoryStats `json:"memory"`
+		} `json:"containers"`
+		Network struct {
+			RxBytes uint64 `json:"rxBytes"`
+			TxBytes uint64 `json:"txBytes"`
+		} `json:"network"`
+		ProcessStats struct {
+			ProcessCount uint64 `json:"process_count"`
+		} `json:"process_stats"`
+	} `json:"pods"`
+}
+
+type kubeMemoryStats struct {
+	AvailableBytes  uint64 `json:"availableBytes"`
+	UsageBytes      uint64 `json:"usageBytes"`
+	WorkingSetBytes uint64 `json:"workingSetBytes"`
+}
+
+// kubeContainerRef is a container of a pod, found by ID or "pod.container" name
+type kubeContainerRef struct {
+	pod    *kubePod
+	spec   *kubeContainer
+	status *kubeContainerStatus
+}
+
+// ref returns the "pod.container" name
+func (r kubeContainerRef) ref() string {
+	return r.pod.Metadata.Name + "." + r.spec.Name
+}
+
+// SplitKubernetesRef splits a "pod.container" name. Container names can't
+// contain dots, so pod names with dots split correctly.
+func SplitKubernetesRef(ref string) (pod, container string) {
+	if i := strings.LastIndex(ref, "."); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// kubeContainerName returns the name to use in Kubernetes manifests for a
+// monitored container
+func kubeContainerName(data *ContainerData) string {
+	if data.Host.Runtime != RuntimeKubernetes {
+		return data.ContainerName
+	}
+	_, name := SplitKubernetesRef(data.ContainerName)
+	return name
+}
+
+// ValidateKubernetesTarget checks a kubernetes configuration's target
+func ValidateKubernetesTarget(target *KubernetesTarget) error {
+	if target == nil {
+		return fmt.Errorf("kubernetes runtime needs a target; set one with `mdok kube`")
+	}
+	u, err := url.Parse(target.Kubelet)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid kubelet URL %q (expected https://host:10250)", target.Kubelet)
+	}
+	if target.Namespace == "" {
+		return fmt.Errorf("kubernetes target needs a namespace")
+	}
+	if _, err := parseLabelSelector(target.Selector); err != nil {
+		return err
+	}
+	switch target.Source {
+	case "", KubeSourceKubelet, KubeSourceCgroups:
+	default:
+		return fmt.Errorf("unknown sample source %q (expected %q or %q)", target.Source, KubeSourceKubelet, KubeSourceCgroups)
+	}
+	return nil
+}
+
+// NewKubeletClient creates a client for a kubelet's API
+func NewKubeletClient(target KubernetesTarget) (*KubeletClient, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: target.Insecure}
+	if target.CACert != "" {
+		pem, err := os.ReadFile(target.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubelet CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", target.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &KubeletClient{
+		target:     target,
+		base:       strings.TrimSuffix(target.Kubelet, "/"),
+		cgroupDirs: make(map[string]string),
+		http: &http.Client{
+			Timeout:   kubeletRequestTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// SetTarget applies a changed target. It returns false when the connection
+// settings changed and a new client is needed.
+func (k *KubeletClient) SetTarget(target KubernetesTarget) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if target.Kubelet != k.target.Kubelet || target.TokenFile != k.target.TokenFile ||
+		target.CACert != k.target.CACert || target.Insecure != k.target.Insecure {
+		return false
+	}
+	k.target = target
+	return true
+}
+
+// Close closes idle connections to the kubelet
+func (k *KubeletClient) Close() error {
+	k.http.CloseIdleConnections()
+	return nil
+}
+
+// get fetches a kubelet API path into v
+func (k *KubeletClient) get(ctx context.Context, path string, v any) error {
+	body, err := k.fetch(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode kubelet %s: %w", path, err)
+	}
+	return nil
+}
+
+// fetch requests a kubelet API path with the target's token
+func (k *KubeletClient) fetch(ctx context.Context, path string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.base+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	tokenFile := k.target.TokenFile
+	if tokenFile == "" {
+		tokenFile = serviceAccountToken
+	}
+	// Re-read every time: projected service account tokens are rotated
+	if token, err := os.ReadFile(tokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	} else if k.target.TokenFile != "" {
+		return nil, fmt.Errorf("failed to read token: %w", err)
+	}
+
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach kubelet: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("kubelet %s returned %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
+
+// podList returns the node's pods, cached briefly so that the containers of
+// one collection round share a request
+func (k *KubeletClient) podList(ctx context.Context) (*kubePodList, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.pods != nil && time.Since(k.podsAt) < kubeletCacheTTL {
+		return k.pods, nil
+	}
+	var pods kubePodList
+	if err := k.get(ctx, "/pods", &pods); err != nil {
+		return nil, err
+	}
+	k.pods, k.podsAt = &pods, time.Now()
+	return k.pods, nil
+}
+
+// statsSummary returns the kubelet's stats summary, cached like podList
+func (k *KubeletClient) statsSummary(ctx context.Context) (*kubeSummary, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.summary != nil && time.Since(k.summaryAt) < kubeletCacheTTL {
+		return k.summary, nil
+	}
+	var summary kubeSummary
+	if err := k.get(ctx, "/stats/summary", &summary); err != nil {
+		return nil, err
+	}
+	k.summary, k.summaryAt = &summary, time.Now()
+	return k.summary, nil
+}
+
+// containers returns the containers of the target's pods. With running, only
+// running containers of running pods are returned.
+func (k *KubeletClient) containers(ctx context.Context, running bool) ([]kubeContainerRef, error) {
+	pods, err := k.podList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	target := k.target
+	k.mu.Unlock()
+	selector, err := parseLabelSelector(target.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []kubeContainerRef
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Metadata.Namespace != target.Namespace || !selector.matches(pod.Metadata.Labels) {
+			continue
+		}
+		if running && pod.Status.Phase != "Running" {
+			continue
+		}
+		for j := range pod.Spec.Containers {
+			spec := &pod.Spec.Containers[j]
+			if len(target.Containers) > 0 && !containsString(target.Containers, spec.Name) {
+				continue
+			}
+			ref := kubeContainerRef{pod: pod, spec: spec}
+			for s := range pod.Status.ContainerStatuses {
+				if pod.Status.ContainerStatuses[s].Name == spec.Name {
+					ref.status = &pod.Status.ContainerStatuses[s]
+				}
+			}
+			if running && (ref.status == nil || ref.status.State.Running == nil || hostPaths.IsSelf(ref.status.id())) {
+				continue
+			}
+			result = append(result, ref)
+		}
+	}
+	return result, nil
+}
+
+// lookup finds a container of the target's pods by ID or "pod.container" name
+func (k *KubeletClient) lookup(ctx context.Context, nameOrID string) (kubeContainerRef, error) {
+	containers, err := k.containers(ctx, false)
+	if err != nil {
+		return kubeContainerRef{}, err
+	}
+	for _, c := range containers {
+		if c.status != nil && c.status.id() != "" && c.status.id() == nameOrID {
+			return c, nil
+		}
+	}
+	for _, c := range containers {
+		if c.ref() == nameOrID {
+			return c, nil
+		}
+	}
+	return kubeContainerRef{}, fmt.Errorf("container not found: %s", nameOrID)
+}
+
+// MatchingContainers returns the sorted "pod.container" names of the running
+// containers the target selects
+func (k *KubeletClient) MatchingContainers(ctx context.Context) ([]string, error) {
+	containers, err := k.containers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]string, 0, len(containers))
+	for _, c := range containers {
+		refs = append(refs, c.ref())
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+// ListContainers returns the running containers the target selects
+func (k *KubeletClient) ListContainers(ctx context.Context) ([]ContainerInfo, error) {
+	containers, err := k.containers(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	var result []ContainerInfo
+	for _, c := range containers {
+		status := "Running"
+		if !c.status.Ready {
+			status += " (not ready)"
+		}
+		if c.status.RestartCount > 0 {
+			status += fmt.Sprintf(", %d restarts", c.status.RestartCount)
+		}
+		result = append(result, ContainerInfo{
+			ID:      shortID(c.status.id()),
+			Name:    c.ref(),
+			Image:   c.spec.Image,
+			Status:  status,
+			Created: c.status.State.Running.StartedAt,
+		})
+	}
+	return result, nil
+}
+
+// GetContainerFullID returns the container ID of a "pod.container" name
+func (k *KubeletClient) GetContainerFullID(ctx context.Context, nameOrID string) (string, error) {
+	c, err := k.lookup(ctx, nameOrID)
+	if err != nil {
+		return "", err
+	}
+	if c.status == nil || c.status.id() == "" {
+		return "", fmt.Errorf("container %s has not started", nameOrID)
+	}
+	return c.status.id(), nil
+}
+
+// GetContainerLimits returns a container's requests and limits from its pod spec
+func (k *KubeletClient) GetContainerLimits(ctx context.Context, containerID string) (ContainerLimits, error) {
+	c, err := k.lookup(ctx, containerID)
+	if err != nil {
+		return ContainerLimits{}, err
+	}
+	return kubeContainerLimits(c.spec)
+}
+
+// kubeContainerLimits maps Kubernetes resources onto Docker-style limits: a
+// CPU limit becomes a quota, a CPU request CPURequest and shares (like the
+// kubelet sets them), and a memory request the memory reservation
+func kubeContainerLimits(spec *kubeContainer) (ContainerLimits, error) {
+	var limits ContainerLimits
+	if q, ok := spec.Resources.Limits["cpu"]; ok {
+		cores, err := parseKubernetesQuantity(q)
+		if err != nil {
+			return limits, fmt.Errorf("invalid CPU limit %q: %w", q, err)
+		}
+		limits.CPUPeriod = cgroupDefaultPeriod
+		limits.CPUQuota = int64(math.Ceil(cores * cgroupDefaultPeriod))
+	}
+	if q, ok := spec.Resources.Requests["cpu"]; ok {
+		cores, err := parseKubernetesQuantity(q)
+		if err != nil {
+			return limits, fmt.Errorf("invalid CPU request %q: %w", q, err)
+		}
+		limits.CPURequest = int64(math.Ceil(cores * 1000))
+		limits.CPUShares = max(limits.CPURequest*1024/1000, 2)
+	}
+	if q, ok := spec.Resources.Limits["memory"]; ok {
+		bytes, err := parseKubernetesQuantity(q)
+		if err != nil {
+			return limits, fmt.Errorf("invalid memory limit %q: %w", q, err)
+		}
+		limits.MemLimit = uint64(bytes)
+	}
+	if q, ok := spec.Resources.Requests["memory"]; ok {
+		bytes, err := parseKubernetesQuantity(q)
+		if err != nil {
+			return limits, fmt.Errorf("invalid memory request %q: %w", q, err)
+		}
+		limits.MemReservation = uint64(bytes)
+	}
+	return limits, nil
+}
+
+// GetContainerImage returns the image in a container's pod spec
+func (k *KubeletClient) GetContainerImage(ctx context.Context, containerID string) (string, error) {
+	c, err := k.lookup(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	return c.spec.Image, nil
+}
+
+// GetContainerLabels returns the labels of a container's pod
+func (k *KubeletClient) GetContainerLabels(ctx context.Context, containerID string) (map[string]string, error) {
+	c, err := k.lookup(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	return c.pod.Metadata.Labels, nil
+}
+
+// IsContainerRunning checks if a container is still running
+func (k *KubeletClient) IsContainerRunning(ctx context.Context, containerID string) (bool, error) {
+	c, err := k.lookup(ctx, containerID)
+	if err != nil {
+		return false, err
+	}
+	return c.status != nil && c.status.State.Running != nil, nil
+}
+
+// GetHostInfo describes the node. CPU and memory come from the local /proc,
+// which is the node's when mdok runs there.
+func (k *KubeletClient) GetHostInfo(ctx context.Context) (HostInfo, error) {
+	summary, err := k.statsSummary(ctx)
+	if err != nil {
+		return HostInfo{}, fmt.Errorf("failed to get kubelet stats: %w", err)
+	}
+
+	info := hostInfoFromProc()
+	info.Hostname = summary.Node.NodeName
+	info.Runtime = RuntimeKubernetes
+	info.DockerVer = k.kubeletVersion(ctx)
+	return info, nil
+}
+
+// kubeletVersion reads the kubelet's version from its build info metric
+func (k *KubeletClient) kubeletVersion(ctx context.Context) string {
+	body, err := k.fetch(ctx, "/metrics")
+	if err != nil {
+		return "unknown"
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if m := kubeBuildInfoPattern.FindStringSubmatch(scanner.Text()); m != nil {
+			return m[1]
+		}
+	}
+	return "unknown"
+}
+
+// CollectStats collects a sample from the kubelet's stats summary or the
+// container's cgroup
+func (k *KubeletClient) CollectStats(ctx context.Context, containerID string, prev *StatsResult) (*StatsResult, error) {
+	c, err := k.lookup(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container stats: %w", err)
+	}
+
+	k.mu.Lock()
+	source := k.target.Source
+	k.mu.Unlock()
+	if source == KubeSourceCgroups {
+		dir, pid, err := k.cgroupDir(containerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get container stats: %w", err)
+		}
+		return readCgroupStats(dir, pid, prev), nil
+	}
+
+	summary, err := k.statsSummary(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container stats: %w", err)
+	}
+	return kubeSummaryStats(summary, c, prev)
+}
+
+// kubeSummaryStats builds a sample from the kubelet's stats summary. Network
+// and process counts are per pod: the containers of a pod share them.
+func kubeSummaryStats(summary *kubeSummary, c kubeContainerRef, prev *StatsResult) (*StatsResult, error) {
+	for _, pod := range summary.Pods {
+		if pod.PodRef.Namespace != c.pod.Metadata.Namespace || pod.PodRef.Name != c.pod.Metadata.Name {
+			continue
+		}
+		for _, stats := range pod.Containers {
+			if stats.Name != c.spec.Name {
+				continue
+			}
+
+			now := time.Now()
+			result := &StatsResult{
+				Sample: Sample{
+					Timestamp: now,
+				},
+			}
+
+			// The kubelet already averages CPU usage; 1e9 nanocores is one core
+			result.Sample.CPUPercent = float64(stats.CPU.UsageNanoCores) / 1e7
+			result.PrevCPU = stats.CPU.UsageCoreNanoSeconds
+			result.PrevSystem = uint64(now.UnixNano())
+
+			// Memory against the limit, or the node's memory without one
+			mem := stats.Memory
+			result.Sample.MemoryUsage = mem.UsageBytes
+			if mem.UsageBytes > mem.WorkingSetBytes {
+				result.Sample.MemoryCache = mem.UsageBytes - mem.WorkingSetBytes
+			}
+			limit := mem.WorkingSetBytes + mem.AvailableBytes
+			if mem.AvailableBytes == 0 {
+				limit = summary.Node.Memory.WorkingSetBytes + summary.Node.Memory.AvailableBytes
+			}
+			if limit > 0 {
+				result.Sample.MemoryPercent = float64(mem.UsageBytes) / float64(limit) * 100.0
+			}
+
+			result.Sample.NetRxBytes = pod.Network.RxBytes
+			result.Sample.NetTxBytes = pod.Network.TxBytes
+			result.Sample.PidsCount = pod.ProcessStats.ProcessCount
+			applyCounterRates(result, prev)
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("no kubelet stats for %s yet", c.ref())
+}
+
+// cgroupDir finds a container's cgroup under the node's kubepods hierarchy
+// (cgroupfs or systemd layout) and one of its processes
+func (k *KubeletClient) cgroupDir(containerID string) (string, int, error) {
+	k.mu.Lock()
+	dir, ok := k.cgroupDirs[containerID]
+	k.mu.Unlock()
+
+	if !ok {
+		for _, root := range []string{hostPaths.CgroupPath("kubepods.slice"), hostPaths.CgroupPath("kubepods")} {
+			filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || !d.IsDir() {
+					return nil
+				}
+				if strings.Contains(d.Name(), containerID) {
+					dir = path
+					return filepath.SkipAll
+				}
+				return nil
+			})
+			if dir != "" {
+				break
+			}
+		}
+		if dir == "" {
+			return "", 0, fmt.Errorf("no cgroup for container %s under %s (cgroup v2 and the host's /sys are needed)", shortID(containerID), hostPaths.CgroupPath())
+		}
+		k.mu.Lock()
+		k.cgroupDirs[containerID] = dir
+		k.mu.Unlock()
+	}
+
+	procs := strings.Fields(readCgroupString(dir, "cgroup.procs"))
+	if len(procs) == 0 {
+		return "", 0, fmt.Errorf("container %s has no processes", shortID(containerID))
+	}
+	pid, _ := strconv.Atoi(procs[0])
+	return dir, pid, nil
+}
+
+// ListNetworkContainers returns the running containers with their pod IPs.
+// The containers of a pod share its network namespace, so all but the first
+// are listed as joining the first's; hostNetwork pods use the node's.
+func (k *KubeletClient) ListNetworkContainers(ctx context.Context) ([]NetworkContainer, error) {
+	containers, err := k.containers(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []NetworkContainer
+	podOwners := make(map[string]string) // Pod UID -> ID of its first container
+	for _, c := range containers {
+		var mode string
+		if c.pod.Spec.HostNetwork {
+			mode = NetworkModeHost
+		} else if owner, ok := podOwners[c.pod.Metadata.UID]; ok {
+			mode = networkModeContainer + owner
+		} else {
+			podOwners[c.pod.Metadata.UID] = c.status.id()
+		}
+
+		addrs := networkAddresses(c.pod.Status.PodIP)
+		for _, ip := range c.pod.Status.PodIPs {
+			if ip.IP != c.pod.Status.PodIP {
+				addrs = append(addrs, ip.IP)
+			}
+		}
+		result = append(result, NetworkContainer{
+			ID:       c.status.id(),
+			Names:    []string{c.ref()},
+			Image:    c.spec.Image,
+			Labels:   c.pod.Metadata.Labels,
+			Networks: map[string][]string{"pod": addrs},
+
+			NetworkMode: mode,
+		})
+	}
+	return result, nil
+}
+
+// ContainerPID returns a process of the container from its cgroup on the node
+func (k *KubeletClient) ContainerPID(ctx context.Context, containerID string) (int, error) {
+	_, pid, err := k.cgroupDir(containerID)
+	return pid, err
+}
+
+// Exec isn't available: the kubelet's exec API needs a streaming upgrade
+func (k *KubeletClient) Exec(ctx context.Context, containerID string, cmd []string) ([]byte, error) {
+	return nil, fmt.Errorf("exec is not supported by the kubernetes runtime")
+}
+
+// Events compares the running containers every kubeletPollInterval, since
+// the kubelet has no event stream. A restarted container shows up as a stop
+// of its old ID and a start of a new one under the same name.
+func (k *KubeletClient) Events(ctx context.Context) (<-chan RuntimeEvent, <-chan error) {
+	out := make(chan RuntimeEvent)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(kubeletPollInterval)
+		defer ticker.Stop()
+
+		var known map[string]string // Container ID -> "pod.container"
+		for {
+			containers, err := k.containers(ctx, true)
+			if err != nil {
+				if ctx.Err() == nil {
+					errs <- err
+				}
+				return
+			}
+			running := make(map[string]string)
+			for _, c := range containers {
+				running[c.status.id()] = c.ref()
+			}
+
+			var events []RuntimeEvent
+			if known != nil {
+				now := time.Now()
+				for id, ref := range known {
+					if _, ok := running[id]; !ok {
+						events = append(events, RuntimeEvent{ContainerID: id, Name: ref, Action: RuntimeEventStop, Time: now})
+					}
+				}
+				for id, ref := range running {
+					if _, ok := known[id]; !ok {
+						events = append(events, RuntimeEvent{ContainerID: id, Name: ref, Action: RuntimeEventStart, Time: now})
+					}
+				}
+			}
+			known = running
+
+			for _, event := range events {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, errs
+}
+
+// labelRequirement is one term of a label selector
+type labelRequirement struct {
+	key    string
+	op     string // "=", "!=", "in", "notin", "exists", "!exists"
+	values []string
+}
+
+// labelSelector is a parsed Kubernetes label selector; all terms must match
+type labelSelector []labelRequirement
+
+// parseLabelSelector parses equality-based ("app=api", "tier!=cache"),
+// set-based ("env in (prod,staging)", "env notin (dev)") and existence
+// ("canary", "!canary") terms separated by commas
+func parseLabelSelector(s string) (labelSelector, error) {
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	terms = append(terms, s[start:])
+
+	var selector labelSelector
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		req, err := parseLabelRequirement(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector %q: %w", s, err)
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// parseLabelRequirement parses one selector term
+func parseLabelRequirement(term string) (labelRequirement, error) {
+	if key, values, ok := strings.Cut(term, " notin "); ok {
+		return setRequirement(key, "notin", values)
+	}
+	if key, values, ok := strings.Cut(term, " in "); ok {
+		return setRequirement(key, "in", values)
+	}
+	if key, value, ok := strings.Cut(term, "!="); ok {
+		return labelRequirement{key: strings.TrimSpace(key), op: "!=", values: []string{strings.TrimSpace(value)}}, nil
+	}
+	if key, value, ok := strings.Cut(term, "=="); ok {
+		return labelRequirement{key: strings.TrimSpace(key), op: "=", values: []string{strings.TrimSpace(value)}}, nil
+	}
+	if key, value, ok := strings.Cut(term, "="); ok {
+		return labelRequirement{key: strings.TrimSpace(key), op: "=", values: []string{strings.TrimSpace(value)}}, nil
+	}
+	if key, ok := strings.CutPrefix(term, "!"); ok {
+		return labelRequirement{key: strings.TrimSpace(key), op: "!exists"}, nil
+	}
+	if strings.ContainsAny(term, " ()") {
+		return labelRequirement{}, fmt.Errorf("can't parse %q", term)
+	}
+	return labelRequirement{key: term, op: "exists"}, nil
+}
+
+// setRequirement parses the "(a,b)" value list of an in/notin term
+func setRequirement(key, op, values string) (labelRequirement, error) {
+	values = strings.TrimSpace(values)
+	if !strings.HasPrefix(values, "(") || !strings.HasSuffix(values, ")") {
+		return labelRequirement{}, fmt.Errorf("%s needs a value list like (a,b)", op)
+	}
+	req := labelRequirement{key: strings.TrimSpace(key), op: op}
+	for _, v := range strings.Split(values[1:len(values)-1], ",") {
+		req.values = append(req.values, strings.TrimSpace(v))
+	}
+	return req, nil
+}
+
+// matches reports whether labels satisfy every term of the selector
+func (s labelSelector) matches(labels map[string]string) bool {
+	for _, req := range s {
+		value, ok := labels[req.key]
+		var match bool
+		switch req.op {
+		case "=", "in":
+			match = ok && containsString(req.values, value)
+		case "!=", "notin":
+			match = !ok || !containsString(req.values, value)
+		case "exists":
+			match = ok
+		case "!exists":
+			match = !ok
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+// kubeQuantitySuffixes are the multipliers of Kubernetes quantity suffixes
+var kubeQuantitySuffixes = map[string]float64{
+	"n": 1e-9, "u": 1e-6, "m": 1e-3, "": 1,
+	"k": 1e3, "M": 1e6, "G": 1e9, "T": 1e12, "P": 1e15, "E": 1e18,
+	"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40, "Pi": 1 << 50, "Ei": 1 << 60,
+}
+
+// parseKubernetesQuantity parses a resource quantity such as "250m", "1.5",
+// "512Mi" or "1e9" into base units (cores or bytes)
+func parseKubernetesQuantity(q string) (float64, error) {
+	q = strings.TrimSpace(q)
+	i := len(q)
+	for i > 0 && !(q[i-1] >= '0' && q[i-1] <= '9') && q[i-1] != '.' {
+		i--
+	}
+	number, suffix := q[:i], q[i:]
+
+	// Exponent notation, e.g. "1e9" or "129e6"
+	if suffix == "" && strings.ContainsAny(number, "eE") {
+		return strconv.ParseFloat(number, 64)
+	}
+	multiplier, ok := kubeQuantitySuffixes[suffix]
+	if !ok {
+		return 0, fmt.Errorf("unknown suffix %q", suffix)
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, err
+	}
+	return value * multiplier, nil
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testKubePods = `{"items": [
+  {
+    "metadata": {"name": "api-1", "namespace": "default", "uid": "u1", "labels": {"app": "api", "tier": "web"}},
+    "spec": {"containers": [
+      {"name": "app", "image": "registry/api:1.2",
+       "resources": {"requests": {"cpu": "250m", "memory": "128Mi"}, "limits": {"cpu": "500m", "memory": "256Mi"}}},
+      {"name": "proxy", "image": "envoyproxy/envoy:v1.30"}
+    ]},
+    "status": {"phase": "Running", "podIP": "10.1.0.5", "containerStatuses": [
+      {"name": "app", "containerID": "containerd://aaa111", "ready": true, "restartCount": 2,
+       "state": {"running": {"startedAt": "2026-01-02T03:04:05Z"}}},
+      {"name": "proxy", "containerID": "containerd://bbb222", "ready": false,
+       "state": {"running": {"startedAt": "2026-01-02T03:04:06Z"}}}
+    ]}
+  },
+  {
+    "metadata": {"name": "api-2", "namespace": "default", "labels": {"app": "api"}},
+    "spec": {"containers": [{"name": "app", "image": "registry/api:1.2"}]},
+    "status": {"phase": "Pending", "containerStatuses": [{"name": "app", "state": {}}]}
+  },
+  {
+    "metadata": {"name": "worker-1", "namespace": "default", "labels": {"app": "worker"}},
+    "spec": {"containers": [{"name": "worker", "image": "registry/worker:1"}]},
+    "status": {"phase": "Running", "containerStatuses": [
+      {"name": "worker", "containerID": "containerd://ccc333", "state": {"running": {"startedAt": "2026-01-02T03:04:05Z"}}}
+    ]}
+  },
+  {
+    "metadata": {"name": "api-1", "namespace": "staging", "labels": {"app": "api"}},
+    "spec": {"containers": [{"name": "app", "image": "registry/api:1.3"}]},
+    "status": {"phase": "Running", "containerStatuses": [
+      {"name": "app", "containerID": "containerd://ddd444", "state": {"running": {"startedAt": "2026-01-02T03:04:05Z"}}}
+    ]}
+  }
+]}`
+
+	testKubeSummary = `{
+  "node": {"nodeName": "node-a", "memory": {"availableBytes": 3221225472, "usageBytes": 1500000000, "workingSetBytes": 1073741824}},
+  "pods": [
+    {
+      "podRef": {"name": "api-1", "namespace": "default"},
+      "containers": [
+        {"name": "app", "cpu": {"usageNanoCores": 250000000, "usageCoreNanoSeconds": 9000000000},
+         "memory": {"availableBytes": 163577856, "usageBytes": 157286400, "workingSetBytes": 104857600}},
+        {"name": "proxy", "cpu": {"usageNanoCores": 1500000000, "usageCoreNanoSeconds": 1000000},
+         "memory": {"usageBytes": 42949673, "workingSetBytes": 42949673}}
+      ],
+      "network": {"rxBytes": 1000, "txBytes": 2000},
+      "process_stats": {"process_count": 7}
+    },
+    {
+      "podRef": {"name": "api-1", "namespace": "staging"},
+      "containers": [
+        {"name": "app", "cpu": {"usageNanoCores": 900000000}, "memory": {"usageBytes": 1}}
+      ]
+    }
+  ]
+}`
+)
+
+// newTestKubelet serves testKubePods and testKubeSummary and returns a client
+// selecting "app=api" pods in the default namespace
+func newTestKubelet(t *testing.T) *KubeletClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/pods":
+			w.Write([]byte(testKubePods))
+		case "/stats/summary":
+			w.Write([]byte(testKubeSummary))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("test-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	target := KubernetesTarget{
+		Kubelet:   server.URL,
+		Namespace: "default",
+		Selector:  "app=api",
+		TokenFile: tokenFile,
+	}
+	if err := ValidateKubernetesTarget(&target); err != nil {
+		t.Fatalf("ValidateKubernetesTarget: %v", err)
+	}
+	client, err := NewKubeletClient(target)
+	if err != nil {
+		t.Fatalf("NewKubeletClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestKubeletClientContainers(t *testing.T) {
+	client := newTestKubelet(t)
+	ctx := context.Background()
+
+	refs, err := client.MatchingContainers(ctx)
+	if err != nil {
+		t.Fatalf("MatchingContainers: %v", err)
+	}
+	want := []string{"api-1.app", "api-1.proxy"}
+	if len(refs) != len(want) || refs[0] != want[0] || refs[1] != want[1] {
+		t.Errorf("MatchingContainers = %v, want %v", refs, want)
+	}
+
+	containers, err := client.ListContainers(ctx)
+	if err != nil {
+		t.Fatalf("ListContainers: %v", err)
+	}
+	if len(containers) != 2 {
+		t.Fatalf("ListContainers returned %d containers, want 2", len(containers))
+	}
+	if c := containers[0]; c.ID != "aaa111" || c.Image != "registry/api:1.2" || c.Status != "Running, 2 restarts" {
+		t.Errorf("ListContainers[0] = %+v", c)
+	}
+	if c := containers[1]; c.Status != "Running (not ready)" {
+		t.Errorf("ListContainers[1].Status = %q, want %q", c.Status, "Running (not ready)")
+	}
+
+	id, err := client.GetContainerFullID(ctx, "api-1.app")
+	if err != nil || id != "aaa111" {
+		t.Errorf("GetContainerFullID(api-1.app) = %q, %v; want aaa111", id, err)
+	}
+	if _, err := client.GetContainerFullID(ctx, "api-2.app"); err == nil {
+		t.Error("GetContainerFullID(api-2.app) succeeded for a container that hasn't started")
+	}
+	if _, err := client.GetContainerFullID(ctx, "worker-1.worker"); err == nil {
+		t.Error("GetContainerFullID(worker-1.worker) found a container the selector excludes")
+	}
+
+	limits, err := client.GetContainerLimits(ctx, "aaa111")
+	if err != nil {
+		t.Fatalf("GetContainerLimits: %v", err)
+	}
+	wantLimits := ContainerLimits{
+		CPUQuota:       50000,
+		CPUPeriod:      100000,
+		CPUShares:      256,
+		CPURequest:     250,
+		MemLimit:       256 << 20,
+		MemReservation: 128 << 20,
+	}
+	if limits != wantLimits {
+		t.Errorf("GetContainerLimits = %+v, want %+v", limits, wantLimits)
+	}
+}
+
+func TestKubeletClientCollectStats(t *testing.T) {
+	client := newTestKubelet(t)
+	ctx := context.Background()
+
+	app, err := client.CollectStats(ctx, "aaa111", nil)
+	if err != nil {
+		t.Fatalf("CollectStats(app): %v", err)
+	}
+	if app.Sample.CPUPercent != 25 {
+		t.Errorf("app CPUPercent = %v, want 25 (250m of one core)", app.Sample.CPUPercent)
+	}
+	if app.PrevCPU != 9000000000 {
+		t.Errorf("app PrevCPU = %d, want 9000000000", app.PrevCPU)
+	}
+	// Usage against working set + available, i.e. the container's 256Mi limit
+	if app.Sample.MemoryUsage != 150<<20 || app.Sample.MemoryCache != 50<<20 {
+		t.Errorf("app memory = %d (cache %d), want %d (cache %d)",
+			app.Sample.MemoryUsage, app.Sample.MemoryCache, 150<<20, 50<<20)
+	}
+	if want := 150.0 / 256.0 * 100; math.Abs(app.Sample.MemoryPercent-want) > 1e-9 {
+		t.Errorf("app MemoryPercent = %v, want %v", app.Sample.MemoryPercent, want)
+	}
+	if app.Sample.NetRxBytes != 1000 || app.Sample.NetTxBytes != 2000 || app.Sample.PidsCount != 7 {
+		t.Errorf("app pod stats = rx %d tx %d pids %d, want rx 1000 tx 2000 pids 7",
+			app.Sample.NetRxBytes, app.Sample.NetTxBytes, app.Sample.PidsCount)
+	}
+
+	// Without a memory limit the node's memory is the reference
+	proxy, err := client.CollectStats(ctx, "api-1.proxy", nil)
+	if err != nil {
+		t.Fatalf("CollectStats(proxy): %v", err)
+	}
+	if proxy.Sample.CPUPercent != 150 {
+		t.Errorf("proxy CPUPercent = %v, want 150", proxy.Sample.CPUPercent)
+	}
+	if want := 42949673.0 / (4 << 30) * 100; math.Abs(proxy.Sample.MemoryPercent-want) > 1e-9 {
+		t.Errorf("proxy MemoryPercent = %v, want %v", proxy.Sample.MemoryPercent, want)
+	}
+	if proxy.Sample.MemoryCache != 0 {
+		t.Errorf("proxy MemoryCache = %d, want 0", proxy.Sample.MemoryCache)
+	}
+
+	if _, err := client.CollectStats(ctx, "ccc333", nil); err == nil {
+		t.Error("CollectStats succeeded for a container the selector excludes")
+	}
+
+	info, err := client.GetHostInfo(ctx)
+	if err != nil {
+		t.Fatalf("GetHostInfo: %v", err)
+	}
+	if info.Hostname != "node-a" || info.Runtime != RuntimeKubernetes {
+		t.Errorf("GetHostInfo = hostname %q runtime %q, want node-a %q", info.Hostname, info.Runtime, RuntimeKubernetes)
+	}
+}
+
+func TestKubeSummaryStatsMissing(t *testing.T) {
+	var pods kubePodList
+	if err := json.Unmarshal([]byte(testKubePods), &pods); err != nil {
+		t.Fatal(err)
+	}
+	var summary kubeSummary
+	if err := json.Unmarshal([]byte(testKubeSummary), &summary); err != nil {
+		t.Fatal(err)
+	}
+
+	// api-2 is pending and has no stats yet
+	pod := &pods.Items[1]
+	ref := kubeContainerRef{pod: pod, spec: &pod.Spec.Containers[0]}
+	if _, err := kubeSummaryStats(&summary, ref, nil); err == nil {
+		t.Error("kubeSummaryStats succeeded for a pod without stats")
+	}
+}
+
+func TestParseKubernetesQuantity(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "250m", want: 0.25},
+		{in: "1.5", want: 1.5},
+		{in: "2", want: 2},
+		{in: "100u", want: 100e-6},
+		{in: "500n", want: 500e-9},
+		{in: "512Mi", want: 512 << 20},
+		{in: "1Gi", want: 1 << 30},
+		{in: "1.5Gi", want: 1.5 * (1 << 30)},
+		{in: "2Ki", want: 2048},
+		{in: "128M", want: 128e6},
+		{in: "1k", want: 1000},
+		{in: "1e9", want: 1e9},
+		{in: "129E6", want: 129e6},
+		{in: " 64Mi ", want: 64 << 20},
+		{in: "3E", want: 3e18},
+		{in: "1Xi", wantErr: true},
+		{in: "Mi", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseKubernetesQuantity(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseKubernetesQuantity(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseKubernetesQuantity(%q): %v", tt.in, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > tt.want*1e-12 {
+			t.Errorf("parseKubernetesQuantity(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseLabelSelector(t *testing.T) {
+	labels := map[string]string{"app": "api", "env": "prod", "canary": ""}
+	tests := []struct {
+		selector string
+		match    bool
+	}{
+		{"", true},
+		{"app=api", true},
+		{"app==api", true},
+		{"app = api", true},
+		{"app=web", false},
+		{"app!=web", true},
+		{"app!=api", false},
+		{"tier!=cache", true},
+		{"env in (prod,staging)", true},
+		{"env in ( staging , dev )", false},
+		{"env notin (dev)", true},
+		{"env notin (dev, prod)", false},
+		{"tier notin (cache)", true},
+		{"canary", true},
+		{"!canary", false},
+		{"!tier", true},
+		{"tier", false},
+		{"app=api,env in (prod,staging),!tier", true},
+		{"app=api, env in (dev,staging)", false},
+	}
+	for _, tt := range tests {
+		selector, err := parseLabelSelector(tt.selector)
+		if err != nil {
+			t.Errorf("parseLabelSelector(%q): %v", tt.selector, err)
+			continue
+		}
+		if got := selector.matches(labels); got != tt.match {
+			t.Errorf("parseLabelSelector(%q).matches(%v) = %v, want %v", tt.selector, labels, got, tt.match)
+		}
+	}
+
+	for _, bad := range []string{"env in prod", "env notin (dev", "app (api)", "a b"} {
+		if _, err := parseLabelSelector(bad); err == nil {
+			t.Errorf("parseLabelSelector(%q) succeeded, want an error", bad)
+		}
+	}
+}
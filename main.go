@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -27,6 +28,7 @@ func main() {
 		os.Exit(1)
 	}
 	mdokDir = filepath.Join(homeDir, ".mdok")
+	hostPaths = DetectHostPaths()
 
 	rootCmd := &cobra.Command{
 		Use:   "mdok",
@@ -34,9 +36,11 @@ func main() {
 		Long: `mdok is a CLI tool for monitoring Docker container resource utilization.
 Run without arguments to interactively create a new monitoring configuration.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			runInteractiveSetup()
+			runtimeName, _ := cmd.Flags().GetString("runtime")
+			runInteractiveSetup(runtimeName)
 		},
 	}
+	rootCmd.Flags().String("runtime", "", "Container runtime for the new configuration: docker (default), podman or containerd")
 
 	// start command
 	startCmd := &cobra.Command{
@@ -45,10 +49,14 @@ Run without arguments to interactively create a new monitoring configuration.`,
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			foreground, _ := cmd.Flags().GetBool("foreground")
-			runStart(args[0], foreground)
+			resume, _ := cmd.Flags().GetBool("resume")
+			restart, _ := cmd.Flags().GetString("restart")
+			runStart(args[0], foreground, resume, restart)
 		},
 	}
 	startCmd.Flags().BoolP("foreground", "f", false, "Run in foreground instead of as daemon")
+	startCmd.Flags().Bool("resume", false, "Continue the last unfinished session (after a crash) instead of starting a new one")
+	startCmd.Flags().String("restart", "", "Set the config's restart policy before starting: no or on-failure")
 
 	// stop command
 	stopCmd := &cobra.Command{
@@ -105,6 +113,28 @@ Run without arguments to interactively create a new monitoring configuration.`,
 	exportCmd.Flags().String("to", "", "End time (RFC3339 format)")
 	exportCmd.Flags().Bool("all", false, "Export all data")
 
+	// graph command
+	graphCmd := &cobra.Command{
+		Use:   "graph <config-name>",
+		Short: "Show the service dependency graph of a configuration",
+		Long: `Build a directed graph of the traffic from a configuration's containers to
+other containers and network zones, weighted by bytes and connections.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+			last, _ := cmd.Flags().GetString("last")
+			from, _ := cmd.Flags().GetString("from")
+			to, _ := cmd.Flags().GetString("to")
+			runGraph(args[0], format, output, last, from, to)
+		},
+	}
+	graphCmd.Flags().StringP("format", "F", "dot", "Graph format: dot, mermaid")
+	graphCmd.Flags().StringP("output", "o", "", "Output file path")
+	graphCmd.Flags().String("last", "", "Use data from last duration (e.g., 1h, 30m)")
+	graphCmd.Flags().String("from", "", "Start time (RFC3339 format)")
+	graphCmd.Flags().String("to", "", "End time (RFC3339 format)")
+
 	// configs command
 	configsCmd := &cobra.Command{
 		Use:   "configs",
@@ -120,9 +150,11 @@ Run without arguments to interactively create a new monitoring configuration.`,
 		Short: "Edit a configuration",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			runEdit(args[0])
+			runtimeName, _ := cmd.Flags().GetString("runtime")
+			runEdit(args[0], runtimeName)
 		},
 	}
+	editCmd.Flags().String("runtime", "", "Switch the configuration to another container runtime: docker, podman or containerd")
 
 	// delete command
 	deleteCmd := &cobra.Command{
@@ -160,18 +192,307 @@ Run without arguments to interactively create a new monitoring configuration.`,
 		},
 	}
 
-	rootCmd.AddCommand(startCmd, stopCmd, lsCmd, viewCmd, exportCmd, configsCmd, editCmd, deleteCmd, logsCmd, sessionsCmd)
+	// rightsize command
+	rightsizeCmd := &cobra.Command{
+		Use:   "rightsize <config-name>",
+		Short: "Recommend container limits for docker run, compose and Kubernetes",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			policy, _ := cmd.Flags().GetString("policy")
+			sessionID, _ := cmd.Flags().GetString("session")
+			cpuHeadroom, _ := cmd.Flags().GetFloat64("cpu-headroom")
+			memHeadroom, _ := cmd.Flags().GetFloat64("mem-headroom")
+			runRightsize(args[0], sessionID, policy, cpuHeadroom, memHeadroom)
+		},
+	}
+	rightsizeCmd.PersistentFlags().String("policy", defaultHeadroomPolicy, "Headroom policy: conservative, balanced, aggressive")
+	rightsizeCmd.PersistentFlags().String("session", "", "Use a specific session ID (default: most recent)")
+	rightsizeCmd.PersistentFlags().Float64("cpu-headroom", 0, "Override the policy's CPU headroom multiplier (e.g., 1.5)")
+	rightsizeCmd.PersistentFlags().Float64("mem-headroom", 0, "Override the policy's memory headroom multiplier (e.g., 1.3)")
+
+	// rightsize apply subcommand
+	rightsizeApplyCmd := &cobra.Command{
+		Use:   "apply <config-name>",
+		Short: "Write recommended limits into a docker-compose file",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			composeFile, _ := cmd.Flags().GetString("compose")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			policy, _ := cmd.Flags().GetString("policy")
+			sessionID, _ := cmd.Flags().GetString("session")
+			cpuHeadroom, _ := cmd.Flags().GetFloat64("cpu-headroom")
+			memHeadroom, _ := cmd.Flags().GetFloat64("mem-headroom")
+			runRightsizeApply(args[0], composeFile, dryRun, sessionID, policy, cpuHeadroom, memHeadroom)
+		},
+	}
+	rightsizeApplyCmd.Flags().String("compose", "docker-compose.yml", "Compose file to update")
+	rightsizeApplyCmd.Flags().Bool("dry-run", false, "Show a diff of the changes without writing the file")
+	rightsizeCmd.AddCommand(rightsizeApplyCmd)
+
+	// endpoints command
+	endpointsCmd := &cobra.Command{
+		Use:   "endpoints <config-name>",
+		Short: "List a configuration's Docker endpoints and check they're reachable",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runEndpoints(args[0])
+		},
+	}
+
+	// endpoints add subcommand
+	endpointsAddCmd := &cobra.Command{
+		Use:   "add <config-name> <endpoint-name> <host>",
+		Short: "Add a Docker endpoint (unix://, tcp:// or ssh://) to a configuration",
+		Args:  cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			tlsCA, _ := cmd.Flags().GetString("tls-ca")
+			tlsCert, _ := cmd.Flags().GetString("tls-cert")
+			tlsKey, _ := cmd.Flags().GetString("tls-key")
+			runEndpointAdd(args[0], DockerEndpoint{Name: args[1], Host: args[2], TLSCACert: tlsCA, TLSCert: tlsCert, TLSKey: tlsKey})
+		},
+	}
+	endpointsAddCmd.Flags().String("tls-ca", "", "CA certificate for a TLS tcp:// endpoint")
+	endpointsAddCmd.Flags().String("tls-cert", "", "Client certificate for a TLS tcp:// endpoint")
+	endpointsAddCmd.Flags().String("tls-key", "", "Client key for a TLS tcp:// endpoint")
+
+	// endpoints remove subcommand
+	endpointsRemoveCmd := &cobra.Command{
+		Use:   "remove <config-name> <endpoint-name>",
+		Short: "Remove a Docker endpoint and its containers from a configuration",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			runEndpointRemove(args[0], args[1])
+		},
+	}
+	endpointsCmd.AddCommand(endpointsAddCmd, endpointsRemoveCmd)
+
+	// kube command
+	kubeCmd := &cobra.Command{
+		Use:   "kube <config-name>",
+		Short: "Create or update a configuration that monitors Kubernetes pods",
+		Long: `Monitor the containers of the pods a label selector matches, through the
+kubelet of the node mdok runs on. The running daemon follows pods as they
+start, stop and get rescheduled on the node.
+
+Samples come from the kubelet's stats summary (--source kubelet) or are read
+from the node's cgroups (--source cgroups, which needs the host's /proc and
+/sys). Flags not given keep their values when updating a configuration.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var target KubernetesTarget
+			target.Kubelet, _ = cmd.Flags().GetString("kubelet")
+			target.Namespace, _ = cmd.Flags().GetString("namespace")
+			target.Selector, _ = cmd.Flags().GetString("selector")
+			target.Containers, _ = cmd.Flags().GetStringSlice("container")
+			target.Source, _ = cmd.Flags().GetString("source")
+			target.TokenFile, _ = cmd.Flags().GetString("token-file")
+			target.CACert, _ = cmd.Flags().GetString("ca-cert")
+			target.Insecure, _ = cmd.Flags().GetBool("insecure")
+			interval, _ := cmd.Flags().GetInt("interval")
+
+			var changed []string
+			for _, flag := range []string{"kubelet", "namespace", "selector", "container", "source", "token-file", "ca-cert", "insecure", "interval"} {
+				if cmd.Flags().Changed(flag) {
+					changed = append(changed, flag)
+				}
+			}
+			runKube(args[0], target, interval, changed)
+		},
+	}
+	kubeCmd.Flags().String("kubelet", defaultKubeletURL, "Kubelet API URL")
+	kubeCmd.Flags().StringP("namespace", "n", defaultKubeNamespace, "Namespace of the pods")
+	kubeCmd.Flags().StringP("selector", "l", "", "Label selector for the pods (e.g. app=api,tier!=cache)")
+	kubeCmd.Flags().StringSlice("container", nil, "Only monitor these containers of each pod (repeatable)")
+	kubeCmd.Flags().String("source", KubeSourceKubelet, "Where samples come from: kubelet or cgroups")
+	kubeCmd.Flags().String("token-file", "", "Bearer token file (default: the pod's service account token)")
+	kubeCmd.Flags().String("ca-cert", "", "CA certificate to verify the kubelet with")
+	kubeCmd.Flags().Bool("insecure", false, "Don't verify the kubelet's certificate")
+	kubeCmd.Flags().Int("interval", 5, "Sampling interval in seconds")
+
+	// agent command
+	agentCmd := &cobra.Command{
+		Use:   "agent <config-name>",
+		Short: "Monitor a configuration and push its data to an aggregator",
+		Long: `Monitor a configuration in the foreground, like "mdok start --foreground",
+and push samples, events and host info to a central "mdok aggregator" in
+compressed batches. Batches that can't be delivered are spooled to disk and
+pushed in order once the aggregator is reachable again.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var opts AgentOptions
+			opts.Aggregator, _ = cmd.Flags().GetString("aggregator")
+			opts.Token, _ = cmd.Flags().GetString("token")
+			opts.Name, _ = cmd.Flags().GetString("name")
+			opts.BatchInterval, _ = cmd.Flags().GetDuration("batch-interval")
+			opts.Resume, _ = cmd.Flags().GetBool("resume")
+			runAgent(args[0], opts)
+		},
+	}
+	agentCmd.Flags().String("aggregator", "", "Aggregator URL (e.g. http://central:7071)")
+	agentCmd.Flags().String("token", os.Getenv("MDOK_AGGREGATOR_TOKEN"), "Token expected by the aggregator (default $MDOK_AGGREGATOR_TOKEN)")
+	agentCmd.Flags().String("name", "", "Agent name used to label containers (default: Docker host name)")
+	agentCmd.Flags().Duration("batch-interval", defaultAgentBatchInterval, "How often to push batches")
+	agentCmd.Flags().Bool("resume", false, "Continue the last unfinished session instead of starting a new one")
+	agentCmd.MarkFlagRequired("aggregator")
+
+	// aggregator command
+	aggregatorCmd := &cobra.Command{
+		Use:   "aggregator",
+		Short: "Receive data pushed by mdok agents",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			listen, _ := cmd.Flags().GetString("listen")
+			socket, _ := cmd.Flags().GetString("socket")
+			token, _ := cmd.Flags().GetString("token")
+			runAggregator(listen, socket, token)
+		},
+	}
+	aggregatorCmd.Flags().String("listen", defaultAggregatorListenAddr, "TCP address to listen on")
+	aggregatorCmd.Flags().String("socket", "", "Listen on a Unix socket instead of TCP")
+	aggregatorCmd.Flags().String("token", os.Getenv("MDOK_AGGREGATOR_TOKEN"), "Token agents must send (default $MDOK_AGGREGATOR_TOKEN)")
+
+	// calibrate command
+	calibrateCmd := &cobra.Command{
+		Use:   "calibrate [config-name]",
+		Short: "Benchmark host CPU and enable vCPU normalization for a configuration",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			factor, _ := cmd.Flags().GetFloat64("factor")
+			disable, _ := cmd.Flags().GetBool("disable")
+			duration, _ := cmd.Flags().GetDuration("duration")
+			configName := ""
+			if len(args) > 0 {
+				configName = args[0]
+			}
+			runCalibrate(configName, factor, disable, duration)
+		},
+	}
+	calibrateCmd.Flags().Float64("factor", 0, "Use this host CPU score instead of running the benchmark")
+	calibrateCmd.Flags().Bool("disable", false, "Disable CPU normalization for the configuration")
+	calibrateCmd.Flags().Duration("duration", 2*time.Second, "Benchmark duration")
+
+	// status command
+	statusCmd := &cobra.Command{
+		Use:   "status <config-name>",
+		Short: "Show detailed status of a running monitoring daemon",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runStatus(args[0])
+		},
+	}
+
+	// reload command
+	reloadCmd := &cobra.Command{
+		Use:   "reload <config-name>",
+		Short: "Apply config changes to a running daemon without a new session",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runControl(args[0], ControlRequest{Command: ControlReload}, "Reloaded configuration for '%s'.\n")
+		},
+	}
+
+	// pause/resume commands
+	pauseCmd := &cobra.Command{
+		Use:   "pause <config-name>",
+		Short: "Pause collection without ending the session",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runControl(args[0], ControlRequest{Command: ControlPause}, "Paused collection for '%s'.\n")
+		},
+	}
+	resumeCmd := &cobra.Command{
+		Use:   "resume <config-name>",
+		Short: "Resume paused collection",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runControl(args[0], ControlRequest{Command: ControlResume}, "Resumed collection for '%s'.\n")
+		},
+	}
+
+	// flush command
+	flushCmd := &cobra.Command{
+		Use:   "flush <config-name>",
+		Short: "Write a running daemon's collected data to disk now",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runControl(args[0], ControlRequest{Command: ControlFlush}, "Flushed data for '%s'.\n")
+		},
+	}
+
+	// interval command
+	intervalCmd := &cobra.Command{
+		Use:   "interval <config-name> <seconds>",
+		Short: "Change a running daemon's collection interval",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			seconds, err := strconv.Atoi(args[1])
+			if err != nil || seconds < 1 {
+				fmt.Fprintf(os.Stderr, "Invalid interval: must be a positive number of seconds\n")
+				os.Exit(1)
+			}
+			runControl(args[0], ControlRequest{Command: ControlInterval, Interval: seconds},
+				fmt.Sprintf("Interval for '%%s' set to %ds.\n", seconds))
+		},
+	}
+
+	// supervise command
+	superviseCmd := &cobra.Command{
+		Use:   "supervise <config-name>",
+		Short: "Run a daemon in the foreground and restart it when it fails",
+		Long: `Run the monitoring daemon for a configuration as a child process and
+restart it with backoff when it crashes. Restarted daemons resume the same
+session and record the gap. Use --systemd or --docker to print a definition
+that keeps monitoring running across reboots instead.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			systemd, _ := cmd.Flags().GetBool("systemd")
+			docker, _ := cmd.Flags().GetBool("docker")
+			image, _ := cmd.Flags().GetString("image")
+			runSupervise(args[0], systemd, docker, image)
+		},
+	}
+	superviseCmd.Flags().Bool("systemd", false, "Print a systemd user unit instead of supervising")
+	superviseCmd.Flags().Bool("docker", false, "Print a docker run sidecar definition instead of supervising")
+	superviseCmd.Flags().String("image", defaultSidecarImage, "Image for --docker")
+
+	// serve command
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the mdok HTTP/JSON API",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			listen, _ := cmd.Flags().GetString("listen")
+			socket, _ := cmd.Flags().GetString("socket")
+			token, _ := cmd.Flags().GetString("token")
+			runServe(listen, socket, token)
+		},
+	}
+	serveCmd.Flags().String("listen", defaultAPIListenAddr, "TCP address to listen on")
+	serveCmd.Flags().String("socket", "", "Listen on a Unix socket instead of TCP")
+	serveCmd.Flags().String("token", os.Getenv("MDOK_API_TOKEN"), "Token API clients must send; required beyond 127.0.0.1 (default $MDOK_API_TOKEN)")
+
+	rootCmd.AddCommand(startCmd, stopCmd, lsCmd, viewCmd, exportCmd, graphCmd, configsCmd, editCmd, deleteCmd, logsCmd, sessionsCmd, rightsizeCmd, calibrateCmd, serveCmd,
+		superviseCmd, endpointsCmd, kubeCmd, agentCmd, aggregatorCmd, statusCmd, pauseCmd, resumeCmd, flushCmd, intervalCmd, reloadCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
 }
 
-func runInteractiveSetup() {
-	// Initialize Docker client
-	docker, err := NewDockerClient()
+func runInteractiveSetup(runtimeName string) {
+	if err := ValidateRuntime(runtimeName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if runtimeName == RuntimeKubernetes {
+		fmt.Fprintln(os.Stderr, "Kubernetes configurations select pods by label; create one with: mdok kube <config-name> --namespace <ns> --selector <labels>")
+		os.Exit(1)
+	}
+
+	// Initialize the runtime client
+	docker, err := NewRuntime(Config{Runtime: runtimeName})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error connecting to Docker: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error connecting to the container runtime: %v\n", err)
 		os.Exit(1)
 	}
 	defer docker.Close()
@@ -210,6 +531,7 @@ func runInteractiveSetup() {
 		Containers: m.selectedContainers,
 		Interval:   m.interval,
 		CreatedAt:  time.Now().Format(time.RFC3339),
+		Runtime:    runtimeName,
 	}
 
 	if err := SaveConfig(config); err != nil {
@@ -223,25 +545,37 @@ func runInteractiveSetup() {
 	fmt.Printf("\nTo start monitoring, run: mdok start %s\n", config.Name)
 }
 
-func runStart(configName string, foreground bool) {
+func runStart(configName string, foreground, resume bool, restart string) {
 	config, err := LoadConfig(configName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Check if already running
+	// Check if already running (the PID file may belong to our supervisor)
 	if IsRunning(configName) {
 		existingPid, _ := ReadPidFile(configName)
-		if existingPid != os.Getpid() {
+		if existingPid != os.Getpid() && existingPid != os.Getppid() {
 		  fmt.Fprintf(os.Stderr, "Monitoring for '%s' is already running.\n", configName)
 		  os.Exit(1)
 	        }
 	}
 
+	if restart != "" {
+		if err := ValidateRestartPolicy(restart); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		config.Restart = restart
+		if err := SaveConfig(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving configuration: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	if foreground {
 		// Run in foreground
-		if err := RunMonitor(config); err != nil {
+		if err := RunMonitor(config, resume); err != nil {
 			fmt.Fprintf(os.Stderr, "Error running monitor: %v\n", err)
 			os.Exit(1)
 		}
@@ -252,11 +586,92 @@ func runStart(configName string, foreground bool) {
 			os.Exit(1)
 		}
 		fmt.Printf("Started monitoring '%s' in background.\n", configName)
+		if config.Restart == RestartOnFailure {
+			fmt.Println("Restart policy: on-failure (crashed daemons are restarted and resume the session)")
+		}
 		fmt.Printf("View logs: mdok logs %s\n", configName)
 		fmt.Printf("View dashboard: mdok view %s\n", configName)
 	}
 }
 
+func runAgent(configName string, opts AgentOptions) {
+	config, err := LoadConfig(configName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	if IsRunning(configName) {
+		fmt.Fprintf(os.Stderr, "Monitoring for '%s' is already running.\n", configName)
+		os.Exit(1)
+	}
+
+	if err := RunAgent(config, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running agent: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runAggregator(listen, socket, token string) {
+	if token == "" && socket == "" && isLoopbackAddr(listen) {
+		fmt.Fprintf(os.Stderr, "Warning: no --token set; any local client can push data to %s.\n", listen)
+	}
+	if err := ServeAggregator(listen, socket, token); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runSupervise(configName string, systemd, docker bool, image string) {
+	config, err := LoadConfig(configName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	if systemd {
+		executable, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting executable path: %v\n", err)
+			os.Exit(1)
+		}
+		unitName := fmt.Sprintf("mdok-%s.service", sanitizeFilename(configName))
+		fmt.Print(SystemdUserUnit(config, executable))
+		fmt.Fprintf(os.Stderr, "\nSave as ~/.config/systemd/user/%s, then run:\n", unitName)
+		fmt.Fprintf(os.Stderr, "  systemctl --user daemon-reload\n")
+		fmt.Fprintf(os.Stderr, "  systemctl --user enable --now %s\n", unitName)
+		fmt.Fprintf(os.Stderr, "  loginctl enable-linger $USER   # keep running without a login session\n")
+		return
+	}
+
+	if docker {
+		fmt.Print(DockerSidecarCommand(config, image))
+		fmt.Fprintf(os.Stderr, "\nThe image must contain the mdok binary as its entrypoint.\n")
+		return
+	}
+
+	if IsRunning(configName) {
+		existingPid, _ := ReadPidFile(configName)
+		if existingPid != os.Getpid() {
+			fmt.Fprintf(os.Stderr, "Monitoring for '%s' is already running.\n", configName)
+			os.Exit(1)
+		}
+	}
+
+	// The supervisor owns the PID file so stop/ls work between restarts
+	if err := WritePidFile(configName, os.Getpid()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing PID file: %v\n", err)
+		os.Exit(1)
+	}
+	defer RemovePidFile(configName)
+
+	if err := Supervise(config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error supervising daemon: %v\n", err)
+		RemovePidFile(configName)
+		os.Exit(1)
+	}
+}
+
 func runStop(configName string) {
 	if !IsRunning(configName) {
 		fmt.Fprintf(os.Stderr, "No running instance found for '%s'.\n", configName)
@@ -286,20 +701,106 @@ func runList() {
 		return
 	}
 
-	fmt.Printf("%-20s %-10s %-25s %s\n", "CONFIG", "PID", "STARTED", "CONTAINERS")
-	fmt.Println(strings.Repeat("-", 80))
+	fmt.Printf("%-20s %-10s %-25s %-10s %s\n", "CONFIG", "PID", "STARTED", "STATE", "CONTAINERS")
+	fmt.Println(strings.Repeat("-", 90))
 	for _, s := range statuses {
 		containers := strings.Join(s.Containers, ", ")
 		if len(containers) > 30 {
 			containers = containers[:27] + "..."
 		}
-		fmt.Printf("%-20s %-10d %-25s %s\n",
+		state := "running"
+		if s.Paused {
+			state = "paused"
+		} else if s.Source == "pid" {
+			state = "running*"
+		}
+		fmt.Printf("%-20s %-10d %-25s %-10s %s\n",
 			s.ConfigName,
 			s.PID,
 			s.StartTime.Format("2006-01-02 15:04:05"),
+			state,
 			containers,
 		)
+
+		// Per-container collection state (control socket only)
+		for _, c := range s.ContainerStatus {
+			line := fmt.Sprintf("  %-28s %d samples", c.Name, c.Samples)
+			if !c.LastSample.IsZero() {
+				line += fmt.Sprintf(", last %s ago", time.Since(c.LastSample).Round(time.Second))
+			}
+			if c.LastError != "" {
+				line += fmt.Sprintf(" [error: %s]", c.LastError)
+			}
+			fmt.Println(dimStyle.Render(line))
+		}
+	}
+
+	for _, s := range statuses {
+		if s.Source == "pid" {
+			fmt.Println()
+			fmt.Println("* No control socket (started by an older mdok); status from PID file only")
+			break
+		}
+	}
+}
+
+func runStatus(configName string) {
+	status, err := QueryDaemonStatus(configName)
+	if err != nil {
+		if IsRunning(configName) {
+			fmt.Fprintf(os.Stderr, "Monitoring for '%s' is running without a control socket (started by an older mdok).\n", configName)
+		} else {
+			fmt.Fprintf(os.Stderr, "No running instance found for '%s'.\n", configName)
+		}
+		os.Exit(1)
+	}
+
+	state := "running"
+	if status.Paused {
+		state = "paused"
+	}
+	fmt.Printf("Config:   %s\n", status.ConfigName)
+	fmt.Printf("State:    %s\n", state)
+	fmt.Printf("PID:      %d\n", status.PID)
+	fmt.Printf("Session:  %s\n", status.SessionID)
+	fmt.Printf("Uptime:   %s (since %s)\n",
+		formatDuration(time.Since(status.StartTime)), status.StartTime.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Interval: %ds\n\n", status.Interval)
+
+	fmt.Printf("%-30s %-10s %-12s %s\n", "CONTAINER", "SAMPLES", "LAST SAMPLE", "LAST ERROR")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, c := range status.ContainerStatus {
+		last := "-"
+		if !c.LastSample.IsZero() {
+			last = formatDuration(time.Since(c.LastSample)) + " ago"
+		}
+		lastErr := "-"
+		if c.LastError != "" {
+			lastErr = c.LastError
+			if !c.LastErrorTime.IsZero() {
+				lastErr += fmt.Sprintf(" (%s)", c.LastErrorTime.Format("15:04:05"))
+			}
+		}
+		fmt.Printf("%-30s %-10d %-12s %s\n", c.Name, c.Samples, last, lastErr)
+	}
+}
+
+// runControl sends a command to a running daemon and prints message (with the config name)
+func runControl(configName string, req ControlRequest, message string) {
+	if !ControlAvailable(configName) {
+		if IsRunning(configName) {
+			fmt.Fprintf(os.Stderr, "Monitoring for '%s' has no control socket (started by an older mdok). Restart it to use this command.\n", configName)
+		} else {
+			fmt.Fprintf(os.Stderr, "No running instance found for '%s'.\n", configName)
+		}
+		os.Exit(1)
+	}
+
+	if _, err := SendControlCommand(configName, req); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
+	fmt.Printf(message, configName)
 }
 
 func runSessions(configName string) {
@@ -408,6 +909,7 @@ func displaySummary(configName string, sessionID string) {
 	fmt.Printf("│ %-*s │\n", 73, title+strings.Repeat(" ", padding))
 	fmt.Printf("╰─────────────────────────────────────────────────────────────────────────╯\n\n")
 
+	var shown []*ContainerData
 	for i, file := range files {
 		if i > 0 {
 			fmt.Println(strings.Repeat("─", 77))
@@ -440,7 +942,7 @@ func displaySummary(configName string, sessionID string) {
 
 		// Calculate network cost if not present
 		if data.NetworkCost == nil && data.Summary != nil {
-			data.NetworkCost = CalculateNetworkCost(data.Summary.NetTxTotal)
+			data.NetworkCost = CalculateNetworkCost(data)
 		}
 
 		// Header
@@ -456,7 +958,10 @@ func displaySummary(configName string, sessionID string) {
 		fmt.Printf("  CPU: %s (%d cores, %s)\n", data.Host.CPUModel, data.Host.CPUCores, data.Host.Architecture)
 		fmt.Printf("  Memory: %s\n", formatBytes(data.Host.MemoryTotal))
 		fmt.Printf("  OS: %s (kernel %s)\n", data.Host.OS, data.Host.KernelVer)
-		fmt.Printf("  Docker: %s\n", data.Host.DockerVer)
+		fmt.Printf("  Runtime: %s\n", runtimeVersionLabel(data.Host))
+		if data.Host.CPUScore > 0 {
+			fmt.Printf("  CPU score: %.2fx reference vCPU (%s)\n", data.Host.CPUScore, data.Host.CPUScoreSource)
+		}
 
 		// Architecture warning
 		if strings.Contains(strings.ToLower(data.Host.Architecture), "arm") ||
@@ -474,12 +979,18 @@ func displaySummary(configName string, sessionID string) {
 		} else {
 			fmt.Printf("  CPU Limit: unlimited\n")
 		}
+		if data.Limits.CPURequest > 0 {
+			fmt.Printf("  CPU Request: %.2f cores\n", float64(data.Limits.CPURequest)/1000)
+		}
 
 		if data.Limits.MemLimit > 0 {
 			fmt.Printf("  Memory Limit: %s\n", formatBytes(data.Limits.MemLimit))
 		} else {
 			fmt.Printf("  Memory Limit: unlimited\n")
 		}
+		if data.Limits.MemReservation > 0 && data.Host.Runtime == RuntimeKubernetes {
+			fmt.Printf("  Memory Request: %s\n", formatBytes(data.Limits.MemReservation))
+		}
 
 		if data.Limits.PidsLimit > 0 {
 			fmt.Printf("  PIDs Limit: %d\n", data.Limits.PidsLimit)
@@ -562,20 +1073,36 @@ func displaySummary(configName string, sessionID string) {
 			fmt.Printf("  Net I/O:  rx=%s tx=%s\n",
 				formatBytes(s.NetRxTotal),
 				formatBytes(s.NetTxTotal))
+			if data.NetworkNamespace != nil {
+				fmt.Printf("  Net namespace: %s\n", formatNetworkNamespace(data.NetworkNamespace))
+			}
 
 			// Network breakdown (if available)
 			if s.NetworkBreakdown != nil {
-				fmt.Printf("  Traffic:  ")
-				if s.NetworkBreakdown.InterContainerPct > 0 {
-					fmt.Printf("inter-container=%.1f%% ", s.NetworkBreakdown.InterContainerPct)
+				fmt.Printf("  Traffic:  %s\n", formatNetworkBreakdown(s.NetworkBreakdown))
+			}
+			if len(s.ZoneBandwidth) > 0 {
+				fmt.Printf("  Bandwidth by zone:\n")
+				for _, line := range formatZoneBandwidth(s.ZoneBandwidth) {
+					fmt.Printf("    %s\n", line)
 				}
-				if s.NetworkBreakdown.InternalPct > 0 {
-					fmt.Printf("internal=%.1f%% ", s.NetworkBreakdown.InternalPct)
+			}
+			if len(s.TopDestinations) > 0 {
+				fmt.Printf("  Top destinations:\n")
+				for _, line := range formatTopDestinations(s.TopDestinations) {
+					fmt.Printf("    %s\n", line)
 				}
-				if s.NetworkBreakdown.InternetPct > 0 {
-					fmt.Printf("internet=%.1f%%", s.NetworkBreakdown.InternetPct)
+			}
+			if s.Sockets != nil {
+				for _, line := range formatSocketSummary(s.Sockets) {
+					fmt.Printf("  %s\n", line)
+				}
+			}
+			if len(data.Proxies) > 0 {
+				fmt.Printf("  Proxies:\n")
+				for _, line := range formatProxies(data.Proxies) {
+					fmt.Printf("    %s\n", line)
 				}
-				fmt.Println()
 			}
 
 			fmt.Printf("  Block I/O: read=%s write=%s\n",
@@ -594,6 +1121,15 @@ func displaySummary(configName string, sessionID string) {
 			}
 		}
 
+		// Reconfigurations during the session
+		if len(data.Events) > 0 {
+			fmt.Printf("Session Events:\n")
+			for _, e := range data.Events {
+				fmt.Printf("  %s  %s: %s\n", e.Time.Format("15:04:05"), e.Type, e.Message)
+			}
+			fmt.Println()
+		}
+
 		// Network Cost with Monthly Projection
 		if data.NetworkCost != nil {
 			fmt.Printf("AWS Network Cost Estimate (%s):\n", data.NetworkCost.Region)
@@ -601,6 +1137,9 @@ func displaySummary(configName string, sessionID string) {
 				data.NetworkCost.EgressGB,
 				data.NetworkCost.PricePerGB,
 				data.NetworkCost.EstimatedCostUSD)
+			for _, z := range data.NetworkCost.Zones {
+				fmt.Printf("    %-20s %.2f GB @ $%.3f/GB = $%.2f\n", z.Zone+":", z.EgressGB, z.PricePerGB, z.CostUSD)
+			}
 
 			// Calculate monthly projection
 			if duration.Hours() > 0 {
@@ -617,23 +1156,34 @@ func displaySummary(configName string, sessionID string) {
 			fmt.Println()
 		}
 
+		// Pod resources to replace the observed requests and limits
+		if data.Summary != nil && data.Host.Runtime == RuntimeKubernetes {
+			policy, _ := GetHeadroomPolicy(defaultHeadroomPolicy)
+			if rec := RecommendLimits(data.Summary, data.Limits, policy); rec != nil {
+				fmt.Printf("Suggested Kubernetes Resources (%s policy):\n\n", policy.Name)
+				fmt.Print(indentLines(FormatKubernetesResources(kubeContainerName(data), rec), "  "))
+				fmt.Printf("  ℹ️  Other policies and the reasoning: mdok rightsize %s\n\n", configName)
+			}
+		}
+
 		// AWS Instance Recommendations (both x86 and ARM)
 		if data.Summary != nil {
-			x86Rec, armRec := RecommendBothArchitectures(data.Summary)
+			x86Rec, armRec := RecommendBothArchitectures(data.Summary, data.Host)
 
 			fmt.Printf("AWS Instance Recommendations:\n\n")
 
 			if x86Rec != nil {
-				monthlyPrice := x86Rec.HourlyPrice * 730 // hours in month
 				fmt.Printf("  x86_64 (Intel/AMD):\n")
 				fmt.Printf("    Instance: %s (%d vCPU, %.0f GB RAM)\n",
 					x86Rec.InstanceType, x86Rec.VCPU, x86Rec.MemoryGB)
-				fmt.Printf("    Cost: $%.4f/hour (~$%.2f/month)\n", x86Rec.HourlyPrice, monthlyPrice)
+				fmt.Printf("    Cost: $%.4f/hour (~$%.2f/month)\n", x86Rec.HourlyPrice, x86Rec.MonthlyPrice)
+				for _, line := range formatPurchaseOptions(x86Rec) {
+					fmt.Printf("    %s\n", line)
+				}
 				fmt.Printf("    Reason: %s\n\n", x86Rec.Reason)
 			}
 
 			if armRec != nil {
-				monthlyPrice := armRec.HourlyPrice * 730
 				savings := 0.0
 				if x86Rec != nil {
 					savings = ((x86Rec.HourlyPrice - armRec.HourlyPrice) / x86Rec.HourlyPrice) * 100
@@ -641,16 +1191,50 @@ func displaySummary(configName string, sessionID string) {
 				fmt.Printf("  ARM64 (Graviton):\n")
 				fmt.Printf("    Instance: %s (%d vCPU, %.0f GB RAM)\n",
 					armRec.InstanceType, armRec.VCPU, armRec.MemoryGB)
-				fmt.Printf("    Cost: $%.4f/hour (~$%.2f/month)", armRec.HourlyPrice, monthlyPrice)
+				fmt.Printf("    Cost: $%.4f/hour (~$%.2f/month)", armRec.HourlyPrice, armRec.MonthlyPrice)
 				if savings > 0 {
 					fmt.Printf(" [%.0f%% cheaper than x86]", savings)
 				}
-				fmt.Printf("\n    Reason: %s\n\n", armRec.Reason)
+				fmt.Println()
+				for _, line := range formatPurchaseOptions(armRec) {
+					fmt.Printf("    %s\n", line)
+				}
+				fmt.Printf("    Reason: %s\n\n", armRec.Reason)
+			}
+
+			// Fargate task sizes (alternative to EC2)
+			fargateRecs := data.Fargate
+			if len(fargateRecs) == 0 {
+				fargateRecs = RecommendFargateBothArchitectures(data.Summary, data.Host)
+			}
+			if len(fargateRecs) > 0 {
+				fmt.Printf("  AWS Fargate (ECS task size):\n")
+				for _, f := range fargateRecs {
+					label := "x86_64"
+					ec2Rec := x86Rec
+					if f.Architecture == "arm" {
+						label = "ARM64 "
+						ec2Rec = armRec
+					}
+					fmt.Printf("    %s: %s - $%.4f/hour (~$%.2f/month)",
+						label, formatFargateTaskSize(f), f.HourlyPrice, f.MonthlyPrice)
+					if f.SpotHourlyPrice > 0 {
+						fmt.Printf(", Spot ~$%.2f/month", f.SpotMonthlyPrice)
+					}
+					if ec2Rec != nil {
+						fmt.Printf(" [EC2 %s: ~$%.2f/month]", ec2Rec.InstanceType, ec2Rec.MonthlyPrice)
+					}
+					fmt.Println()
+				}
+				fmt.Println()
 			}
 
 			// Architecture note
 			hostArch := strings.ToLower(data.Host.Architecture)
-			if strings.Contains(hostArch, "arm") || strings.Contains(hostArch, "aarch") {
+			if data.Host.CPUScore > 0 {
+				fmt.Printf("  ℹ️  vCPU requirements normalized using host CPU score %.2f (%s).\n",
+					data.Host.CPUScore, data.Host.CPUScoreSource)
+			} else if strings.Contains(hostArch, "arm") || strings.Contains(hostArch, "aarch") {
 				fmt.Printf("  ℹ️  Measured on ARM hardware. Performance may differ on x86 instances.\n")
 			} else if strings.Contains(hostArch, "x86") || strings.Contains(hostArch, "amd64") {
 				fmt.Printf("  ℹ️  Measured on x86 hardware. ARM instances may perform differently.\n")
@@ -659,6 +1243,29 @@ func displaySummary(configName string, sessionID string) {
 		}
 
 		fmt.Println()
+		shown = append(shown, data)
+	}
+
+	// Network figures of shared namespaces, counted once
+	if namespaces := SharedNamespaces(shown); len(namespaces) > 0 {
+		fmt.Println(strings.Repeat("─", 77))
+		fmt.Println()
+		fmt.Printf("Shared Network Namespaces:\n")
+		for _, line := range formatSharedNamespaces(namespaces) {
+			fmt.Printf("  %s\n", line)
+		}
+		fmt.Println()
+	}
+
+	// Proxy egress split back to the containers calling the proxies
+	if services := AttributeEgress(shown); len(services) > 0 {
+		fmt.Println(strings.Repeat("─", 77))
+		fmt.Println()
+		fmt.Printf("Internet Egress by Service (proxy egress attributed to callers):\n")
+		for _, line := range formatServiceEgress(services) {
+			fmt.Printf("  %s\n", line)
+		}
+		fmt.Println()
 	}
 }
 
@@ -694,6 +1301,278 @@ func runExport(configName, format, output, last, from, to string, all bool) {
 	}
 }
 
+func runGraph(configName, format, output, last, from, to string) {
+	opts := ExportOptions{
+		Format: format,
+		Last:   last,
+		Output: output,
+	}
+
+	if from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid from time: %v\n", err)
+			os.Exit(1)
+		}
+		opts.From = t
+	}
+
+	if to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid to time: %v\n", err)
+			os.Exit(1)
+		}
+		opts.To = t
+	}
+
+	if err := Graph(configName, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error building graph: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// resolveHeadroomPolicy looks up a policy and applies headroom overrides, exiting on error
+func resolveHeadroomPolicy(policyName string, cpuHeadroom, memHeadroom float64) HeadroomPolicy {
+	policy, err := GetHeadroomPolicy(policyName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if cpuHeadroom > 0 {
+		policy.CPUHeadroom = cpuHeadroom
+	}
+	if memHeadroom > 0 {
+		policy.MemHeadroom = memHeadroom
+	}
+	return policy
+}
+
+func runRightsize(configName, sessionID, policyName string, cpuHeadroom, memHeadroom float64) {
+	policy := resolveHeadroomPolicy(policyName, cpuHeadroom, memHeadroom)
+
+	allData, err := LoadSessionContainerData(configName, sessionID)
+	if err != nil || len(allData) == 0 {
+		fmt.Println("No monitoring data found.")
+		return
+	}
+
+	fmt.Printf("Container limit recommendations for '%s' (policy: %s)\n\n", configName, policy.Name)
+
+	for _, data := range allData {
+		if data.Summary == nil {
+			continue
+		}
+
+		rec := RecommendLimits(data.Summary, data.Limits, policy)
+		if rec == nil {
+			continue
+		}
+
+		fmt.Printf("%s\n", selectedStyle.Render(data.ContainerName))
+		fmt.Printf("  Based on %d samples (%s)\n\n", data.Summary.SampleCount, data.Summary.Duration)
+
+		fmt.Printf("  Why:\n")
+		for _, e := range rec.Explanations {
+			fmt.Printf("    • %s\n", e)
+		}
+		fmt.Println()
+
+		if data.Host.Runtime != RuntimeKubernetes {
+			fmt.Printf("  docker run:\n")
+			fmt.Printf("    %s\n\n", FormatDockerRunFlags(rec))
+
+			fmt.Printf("  docker compose:\n")
+			fmt.Print(indentLines(FormatComposeSnippet(data.ContainerName, rec), "    "))
+			fmt.Println()
+		}
+
+		fmt.Printf("  Kubernetes:\n")
+		fmt.Print(indentLines(FormatKubernetesResources(kubeContainerName(data), rec), "    "))
+		fmt.Println()
+	}
+
+	fmt.Printf("ℹ️  Limits reflect the observed workload only. Re-run after load tests that cover peak traffic.\n")
+}
+
+func runRightsizeApply(configName, composeFile string, dryRun bool, sessionID, policyName string, cpuHeadroom, memHeadroom float64) {
+	policy := resolveHeadroomPolicy(policyName, cpuHeadroom, memHeadroom)
+
+	original, err := os.ReadFile(composeFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading compose file: %v\n", err)
+		os.Exit(1)
+	}
+
+	services, err := ComposeServiceNames(original)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	allData, err := LoadSessionContainerData(configName, sessionID)
+	if err != nil || len(allData) == 0 {
+		fmt.Println("No monitoring data found.")
+		return
+	}
+
+	// Older data files don't record the compose service, so fall back to asking
+	// Docker for the label and finally to matching the container name
+	var docker *RuntimePool
+	defer func() {
+		if docker != nil {
+			docker.Close()
+		}
+	}()
+
+	changes := make(map[string]*ComposeChange)
+	var unmatched []string
+	for _, data := range allData {
+		if data.Summary == nil {
+			continue
+		}
+
+		service := data.ComposeService
+		if service == "" {
+			if docker == nil {
+				config, _ := LoadConfig(configName)
+				docker = NewRuntimePool(config)
+			}
+			if cli, err := docker.Client(data.Endpoint); err == nil {
+				if labels, err := cli.GetContainerLabels(context.Background(), data.ContainerID); err == nil {
+					service = labels[composeServiceLabel]
+				}
+			}
+		}
+		if service == "" {
+			service = guessComposeService(data.ContainerName, services)
+		}
+		if service == "" {
+			unmatched = append(unmatched, data.ContainerName)
+			continue
+		}
+
+		rec := RecommendLimits(data.Summary, data.Limits, policy)
+		change, ok := changes[service]
+		if !ok {
+			change = &ComposeChange{Service: service}
+			changes[service] = change
+		}
+		change.Containers = append(change.Containers, data.ContainerName)
+		change.Limits = MergeLimitRecommendations(change.Limits, rec)
+	}
+
+	updatedFile, updated, err := ApplyComposeLimits(original, changes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	changed := make([]string, 0, len(changes))
+	for service := range changes {
+		changed = append(changed, service)
+	}
+	sort.Strings(changed)
+	for _, service := range changed {
+		found := false
+		for _, name := range updated {
+			if name == service {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, strings.Join(changes[service].Containers, ", ")+" (service '"+service+"' not in compose file)")
+		}
+	}
+
+	if len(updated) == 0 {
+		fmt.Println("No monitored containers matched a service in the compose file.")
+	} else if dryRun {
+		diff := UnifiedDiff(composeFile, composeFile+" (rightsized)", original, updatedFile)
+		if diff == "" {
+			fmt.Println("Compose file already matches the recommended limits.")
+		} else {
+			fmt.Print(diff)
+		}
+	} else {
+		if err := WriteFilePreservingMode(composeFile, updatedFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing compose file: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Updated %s (policy: %s)\n", composeFile, policy.Name)
+		for _, service := range updated {
+			rec := changes[service].Limits
+			fmt.Printf("  %s: %s\n", service, FormatDockerRunFlags(rec))
+		}
+	}
+
+	if len(unmatched) > 0 {
+		fmt.Println()
+		fmt.Println("Skipped (no matching compose service):")
+		for _, name := range unmatched {
+			fmt.Printf("  • %s\n", name)
+		}
+	}
+}
+
+func runCalibrate(configName string, factor float64, disable bool, duration time.Duration) {
+	if configName == "" {
+		if factor > 0 || disable {
+			fmt.Fprintf(os.Stderr, "--factor and --disable require a configuration name\n")
+			os.Exit(1)
+		}
+		fmt.Printf("Running CPU benchmark (%s)...\n", duration)
+		score := RunCPUBenchmark(duration)
+		fmt.Printf("Host CPU score: %.2f (1.00 = one m5 vCPU)\n", score)
+		fmt.Println("Enable normalization for a configuration with: mdok calibrate <config-name>")
+		return
+	}
+
+	config, err := LoadConfig(configName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case disable:
+		config.NormalizeCPU = false
+		config.HostCPUFactor = 0
+		config.HostCPUFactorSource = ""
+		fmt.Printf("CPU normalization disabled for '%s'.\n", configName)
+	case factor > 0:
+		config.NormalizeCPU = true
+		config.HostCPUFactor = factor
+		config.HostCPUFactorSource = "user"
+		fmt.Printf("Using host CPU score %.2f for '%s'.\n", factor, configName)
+	default:
+		fmt.Printf("Running CPU benchmark (%s)...\n", duration)
+		score := RunCPUBenchmark(duration)
+		if score <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: the benchmark did not produce a score\n")
+			os.Exit(1)
+		}
+		config.NormalizeCPU = true
+		config.HostCPUFactor = score
+		config.HostCPUFactorSource = "benchmark"
+		fmt.Printf("Host CPU score: %.2f (1.00 = one m5 vCPU)\n", score)
+		fmt.Printf("CPU normalization enabled for '%s' with this score. Run it again when the host is idle to refresh it.\n", configName)
+	}
+
+	if err := SaveConfig(config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving configuration: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runServe(listen, socket, token string) {
+	if err := ServeAPI(listen, socket, token); err != nil {
+		fmt.Fprintf(os.Stderr, "Error serving API: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 func runConfigs() {
 	configs, err := ListConfigs()
 	if err != nil {
@@ -707,7 +1586,7 @@ func runConfigs() {
 		return
 	}
 
-	fmt.Printf("%-20s %-12s %-25s %s\n", "NAME", "INTERVAL", "CREATED", "CONTAINERS")
+	fmt.Printf("%-20s %-12s %-12s %-25s %s\n", "NAME", "INTERVAL", "RUNTIME", "CREATED", "CONTAINERS")
 	fmt.Println(strings.Repeat("-", 80))
 	for _, c := range configs {
 		containers := strings.Join(c.Containers, ", ")
@@ -718,37 +1597,54 @@ func runConfigs() {
 		if t, err := time.Parse(time.RFC3339, c.CreatedAt); err == nil {
 			created = t.Format("2006-01-02 15:04:05")
 		}
-		fmt.Printf("%-20s %-12s %-25s %s\n", c.Name, fmt.Sprintf("%ds", c.Interval), created, containers)
+		runtimeName := c.Runtime
+		if runtimeName == "" {
+			runtimeName = RuntimeDocker
+		}
+		fmt.Printf("%-20s %-12s %-12s %-25s %s\n", c.Name, fmt.Sprintf("%ds", c.Interval), runtimeName, created, containers)
 	}
 }
 
-func runEdit(configName string) {
+func runEdit(configName, runtimeName string) {
 	config, err := LoadConfig(configName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
 		os.Exit(1)
 	}
-
-	if IsRunning(configName) {
-		fmt.Fprintf(os.Stderr, "Cannot edit while monitoring is running. Stop it first with: mdok stop %s\n", configName)
+	if config.Aggregated {
+		fmt.Fprintf(os.Stderr, "Configuration '%s' holds data pushed by agents; edit it on the agent hosts.\n", configName)
 		os.Exit(1)
 	}
-
-	// Initialize Docker client
-	docker, err := NewDockerClient()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error connecting to Docker: %v\n", err)
+	if config.Runtime == RuntimeKubernetes || runtimeName == RuntimeKubernetes {
+		fmt.Fprintf(os.Stderr, "Kubernetes configurations select pods by label; change them with: mdok kube %s\n", configName)
 		os.Exit(1)
 	}
+
+	// A new runtime lists its own containers to choose from
+	if runtimeName != "" {
+		config.Runtime = runtimeName
+		if err := ValidateEndpoints(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Running containers on the local daemon and the config's endpoints
+	docker := NewRuntimePool(config)
 	defer docker.Close()
 
-	// Get list of running containers
 	ctx := context.Background()
-	containers, err := docker.ListContainers(ctx)
-	if err != nil {
+	containers, failed := docker.ListAllContainers(ctx)
+	if err, ok := failed[""]; ok && len(config.Endpoints) == 0 {
 		fmt.Fprintf(os.Stderr, "Error listing containers: %v\n", err)
 		os.Exit(1)
 	}
+	for endpoint, err := range failed {
+		if endpoint == "" {
+			endpoint = "local"
+		}
+		fmt.Fprintf(os.Stderr, "Warning: can't list containers on %s: %v\n", endpoint, err)
+	}
 
 	// Run TUI for editing
 	model := NewEditModel(containers, config)
@@ -765,8 +1661,16 @@ func runEdit(configName string) {
 		os.Exit(0)
 	}
 
-	// Update configuration
-	config.Containers = m.selectedContainers
+	// Update configuration, keeping containers on endpoints that couldn't be listed
+	var kept []string
+	for _, ref := range config.Containers {
+		if endpoint, _ := SplitContainerRef(ref); endpoint != "" {
+			if _, ok := failed[endpoint]; ok {
+				kept = append(kept, ref)
+			}
+		}
+	}
+	config.Containers = append(m.selectedContainers, kept...)
 	config.Interval = m.interval
 
 	if err := SaveConfig(config); err != nil {
@@ -775,6 +1679,248 @@ func runEdit(configName string) {
 	}
 
 	fmt.Printf("Configuration '%s' updated.\n", config.Name)
+	notifyConfigReload(config.Name)
+}
+
+func runEndpoints(configName string) {
+	config, err := LoadConfig(configName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	docker := NewRuntimePool(config)
+	defer docker.Close()
+
+	fmt.Printf("%-15s %-40s %s\n", "ENDPOINT", "HOST", "STATUS")
+	fmt.Println(strings.Repeat("-", 80))
+
+	local := "(local, from environment)"
+	if config.Runtime != "" && config.Runtime != RuntimeDocker {
+		local = fmt.Sprintf("(local %s)", config.Runtime)
+	}
+	endpoints := append([]DockerEndpoint{{Name: "", Host: local}}, config.Endpoints...)
+	for _, ep := range endpoints {
+		status := "ok"
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		cli, err := docker.Client(ep.Name)
+		if err == nil {
+			var info HostInfo
+			if info, err = cli.GetHostInfo(ctx); err == nil {
+				status = fmt.Sprintf("ok (%s, %s)", info.Hostname, runtimeVersionLabel(info))
+			}
+		}
+		cancel()
+		if err != nil {
+			status = "error: " + err.Error()
+		}
+
+		name := ep.Name
+		if name == "" {
+			name = "local"
+		}
+		fmt.Printf("%-15s %-40s %s\n", name, ep.Host, status)
+	}
+}
+
+func runEndpointAdd(configName string, endpoint DockerEndpoint) {
+	config, err := LoadConfig(configName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The daemon doesn't run from this directory
+	for _, path := range []*string{&endpoint.TLSCACert, &endpoint.TLSCert, &endpoint.TLSKey} {
+		if *path != "" {
+			if abs, err := filepath.Abs(*path); err == nil {
+				*path = abs
+			}
+		}
+	}
+
+	config.Endpoints = append(config.Endpoints, endpoint)
+	if err := ValidateEndpoints(config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := SaveConfig(config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Added endpoint '%s' to '%s'. Select its containers with: mdok edit %s\n", endpoint.Name, configName, configName)
+	notifyConfigReload(configName)
+}
+
+func runEndpointRemove(configName, endpointName string) {
+	config, err := LoadConfig(configName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	var endpoints []DockerEndpoint
+	for _, ep := range config.Endpoints {
+		if ep.Name != endpointName {
+			endpoints = append(endpoints, ep)
+		}
+	}
+	if len(endpoints) == len(config.Endpoints) {
+		fmt.Fprintf(os.Stderr, "Endpoint '%s' not found in '%s'.\n", endpointName, configName)
+		os.Exit(1)
+	}
+	config.Endpoints = endpoints
+
+	// Containers on the endpoint go with it
+	var containers []string
+	for _, ref := range config.Containers {
+		if endpoint, _ := SplitContainerRef(ref); endpoint != endpointName {
+			containers = append(containers, ref)
+		}
+	}
+	config.Containers = containers
+
+	if err := SaveConfig(config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Removed endpoint '%s' from '%s'.\n", endpointName, configName)
+	notifyConfigReload(configName)
+}
+
+func runKube(configName string, target KubernetesTarget, interval int, changed []string) {
+	// The daemon doesn't run from this directory
+	for _, path := range []*string{&target.TokenFile, &target.CACert} {
+		if *path != "" {
+			if abs, err := filepath.Abs(*path); err == nil {
+				*path = abs
+			}
+		}
+	}
+
+	config := Config{
+		Name:      configName,
+		Interval:  interval,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Runtime:   RuntimeKubernetes,
+	}
+	created := !ConfigExists(configName)
+	if !created {
+		existing, err := LoadConfig(configName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+			os.Exit(1)
+		}
+		if existing.Runtime != RuntimeKubernetes || existing.Kubernetes == nil {
+			fmt.Fprintf(os.Stderr, "Configuration '%s' doesn't monitor Kubernetes pods; choose another name.\n", configName)
+			os.Exit(1)
+		}
+
+		// Flags not given keep their values
+		config = existing
+		current := *existing.Kubernetes
+		for _, flag := range changed {
+			switch flag {
+			case "kubelet":
+				current.Kubelet = target.Kubelet
+			case "namespace":
+				current.Namespace = target.Namespace
+			case "selector":
+				current.Selector = target.Selector
+			case "container":
+				current.Containers = target.Containers
+			case "source":
+				current.Source = target.Source
+			case "token-file":
+				current.TokenFile = target.TokenFile
+			case "ca-cert":
+				current.CACert = target.CACert
+			case "insecure":
+				current.Insecure = target.Insecure
+			case "interval":
+				config.Interval = interval
+			}
+		}
+		target = current
+	}
+	config.Kubernetes = &target
+
+	if config.Interval < 1 {
+		fmt.Fprintf(os.Stderr, "Error: invalid interval: %d\n", config.Interval)
+		os.Exit(1)
+	}
+	if err := ValidateEndpoints(config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Check the kubelet is reachable and show what the selector matches
+	kube, err := NewKubeletClient(target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error connecting to the kubelet: %v\n", err)
+		os.Exit(1)
+	}
+	defer kube.Close()
+	containers, err := kube.MatchingContainers(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing pods: %v\n", err)
+		os.Exit(1)
+	}
+	config.Containers = containers
+
+	if err := SaveConfig(config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	if created {
+		fmt.Printf("Configuration '%s' saved.\n", configName)
+	} else {
+		fmt.Printf("Configuration '%s' updated.\n", configName)
+	}
+	selector := target.Selector
+	if selector == "" {
+		selector = "all pods"
+	}
+	fmt.Printf("  Pods: %s in namespace %s (samples from %s)\n", selector, target.Namespace, kubeSourceLabel(target.Source))
+	if len(containers) == 0 {
+		fmt.Printf("  Containers: none running yet; they're picked up when they start\n")
+	} else {
+		fmt.Printf("  Containers: %s\n", strings.Join(containers, ", "))
+	}
+	fmt.Printf("  Interval: %ds\n", config.Interval)
+	if created {
+		fmt.Printf("\nTo start monitoring, run: mdok start %s\n", configName)
+	} else {
+		notifyConfigReload(configName)
+	}
+}
+
+// kubeSourceLabel names a sample source for display
+func kubeSourceLabel(source string) string {
+	if source == KubeSourceCgroups {
+		return "node cgroups"
+	}
+	return "the kubelet"
+}
+
+// notifyConfigReload applies a saved config change to a running daemon
+func notifyConfigReload(configName string) {
+	if !IsRunning(configName) {
+		return
+	}
+	if !ControlAvailable(configName) {
+		fmt.Printf("Monitoring for '%s' was started by an older mdok; restart it to apply the changes.\n", configName)
+		return
+	}
+	if _, err := SendControlCommand(configName, ControlRequest{Command: ControlReload}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reloading running daemon: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Applied changes to the running session.\n")
 }
 
 func runDelete(configName string, force bool) {
@@ -6,51 +6,119 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 )
 
+// configWatchInterval is how often the daemon checks its config file for changes
+const configWatchInterval = 2 * time.Second
+
+// eventsRetryInterval is how long the daemon waits before reopening a failed
+// runtime event stream
+const eventsRetryInterval = 30 * time.Second
+
 // Monitor handles the monitoring loop for containers
 type Monitor struct {
 	config        Config
-	docker        *DockerClient
+	docker        *RuntimePool
 	containerData map[string]*ContainerData
 	prevStats     map[string]*StatsResult
 	sessionID     string
 	mu            sync.Mutex
 	stopChan      chan struct{}
+	stopOnce      sync.Once
 	logger        *log.Logger
+	resumed       map[string]*ContainerData // Unfinished session data picked up after a restart
+	departed      map[string]*ContainerData // Session data of containers a reload removed
+	namer         *DestinationNamer         // Names top destinations
+	zones         *NetworkZones             // Classifies traffic
+	proxies       *ProxyDetector            // Decides which containers are egress proxies
+	watching      map[string]bool           // Endpoints whose container events are followed
+	eventsCtx     context.Context
+	stopEvents    context.CancelFunc
+
+	// Runtime state exposed over the control socket
+	hostInfo      map[string]HostInfo // Per Docker endpoint
+	reloadChan    chan chan error
+	configModTime time.Time
+	startTime     time.Time
+	paused        bool
+	intervalChan  chan int
+	lastErrors    map[string]containerError
+	subscribers   map[chan ControlSample]struct{}
+}
+
+// containerError is the most recent collection error for a container
+type containerError struct {
+	message string
+	at      time.Time
 }
 
 // NewMonitor creates a new monitor instance
 func NewMonitor(config Config, logger *log.Logger) (*Monitor, error) {
-	docker, err := NewDockerClient()
+	if config.Aggregated {
+		return nil, fmt.Errorf("configuration '%s' holds data pushed by agents; monitor it on the agent hosts", config.Name)
+	}
+	if err := ValidateEndpoints(config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	namer, err := NewDestinationNamer(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	zones, err := NewNetworkZones(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
+	proxies, err := NewProxyDetector(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	docker := NewRuntimePool(config)
 
 	// Generate unique session ID (timestamp-based)
 	sessionID := fmt.Sprintf("%d", time.Now().Unix())
+	eventsCtx, stopEvents := context.WithCancel(context.Background())
 
 	return &Monitor{
 		config:        config,
 		docker:        docker,
+		watching:      make(map[string]bool),
+		departed:      make(map[string]*ContainerData),
+		namer:         namer,
+		zones:         zones,
+		proxies:       proxies,
+		eventsCtx:     eventsCtx,
+		stopEvents:    stopEvents,
 		containerData: make(map[string]*ContainerData),
 		prevStats:     make(map[string]*StatsResult),
 		sessionID:     sessionID,
 		stopChan:      make(chan struct{}),
 		logger:        logger,
+		startTime:     time.Now(),
+		hostInfo:      make(map[string]HostInfo),
+		intervalChan:  make(chan int, 1),
+		reloadChan:    make(chan chan error),
+		lastErrors:    make(map[string]containerError),
+		subscribers:   make(map[chan ControlSample]struct{}),
 	}, nil
 }
 
-// RunMonitor runs the monitor in foreground mode
-func RunMonitor(config Config) error {
+// RunMonitor runs the monitor in foreground mode. With resume, an unfinished
+// session left by a crashed daemon is continued instead of starting a new one.
+func RunMonitor(config Config, resume bool) error {
 	logger := log.New(os.Stdout, "", log.LstdFlags)
 	monitor, err := NewMonitor(config, logger)
 	if err != nil {
 		return err
 	}
+	if resume {
+		monitor.resumeSession()
+	}
 
 	return monitor.Run()
 }
@@ -68,12 +136,38 @@ func (m *Monitor) Run() error {
 		return err
 	}
 
+	// Control socket for status, streaming and runtime changes
+	listener, err := ListenControl(m.config.Name)
+	if err != nil {
+		m.logger.Printf("Warning: control socket unavailable: %v\n", err)
+	} else {
+		defer listener.Close()
+		go ServeControl(listener, m)
+	}
+
 	m.logger.Printf("Starting monitoring for %d containers (interval: %ds)\n",
 		len(m.config.Containers), m.config.Interval)
 
 	ticker := time.NewTicker(time.Duration(m.config.Interval) * time.Second)
 	defer ticker.Stop()
 
+	// Config hot reload: SIGHUP, control command or config file changes
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	if info, err := os.Stat(GetConfigFile(m.config.Name)); err == nil {
+		m.configModTime = info.ModTime()
+	}
+	watchTicker := time.NewTicker(configWatchInterval)
+	defer watchTicker.Stop()
+
+	// Kubernetes: follow pods the selector starts or stops matching
+	var kubeSync <-chan time.Time
+	if m.config.Runtime == RuntimeKubernetes {
+		kubeTicker := time.NewTicker(kubeSyncInterval)
+		defer kubeTicker.Stop()
+		kubeSync = kubeTicker.C
+	}
+
 	// Initial collection
 	m.collectAllStats(ctx)
 
@@ -81,6 +175,21 @@ func (m *Monitor) Run() error {
 		select {
 		case <-ticker.C:
 			m.collectAllStats(ctx)
+		case interval := <-m.intervalChan:
+			ticker.Reset(time.Duration(interval) * time.Second)
+			m.logger.Printf("Interval changed to %ds\n", interval)
+		case <-hupChan:
+			m.reload(ctx, "SIGHUP")
+		case reply := <-m.reloadChan:
+			reply <- m.reload(ctx, "control command")
+		case <-watchTicker.C:
+			if info, err := os.Stat(GetConfigFile(m.config.Name)); err == nil && !info.ModTime().Equal(m.configModTime) {
+				m.reload(ctx, "config file changed")
+			}
+		case <-kubeSync:
+			if m.kubernetesChanged(ctx) {
+				m.reload(ctx, "matching pods changed")
+			}
 		case <-sigChan:
 			m.logger.Println("Received shutdown signal")
 			m.shutdown()
@@ -95,57 +204,391 @@ func (m *Monitor) Run() error {
 
 // Stop signals the monitor to stop
 func (m *Monitor) Stop() {
-	close(m.stopChan)
+	m.stopOnce.Do(func() { close(m.stopChan) })
 }
 
-// initializeContainers sets up initial container data structures
-func (m *Monitor) initializeContainers(ctx context.Context) error {
-	hostInfo, err := m.docker.GetHostInfo(ctx)
+// SetPaused pauses or resumes collection without ending the session
+func (m *Monitor) SetPaused(paused bool) {
+	m.mu.Lock()
+	changed := m.paused != paused
+	m.paused = paused
+	m.mu.Unlock()
+
+	if changed && paused {
+		m.logger.Println("Collection paused")
+	} else if changed {
+		m.logger.Println("Collection resumed")
+	}
+}
+
+// SetInterval changes the collection interval of the running session
+func (m *Monitor) SetInterval(seconds int) {
+	m.mu.Lock()
+	m.config.Interval = seconds
+	for _, data := range m.containerData {
+		if data != nil {
+			data.Interval = seconds
+		}
+	}
+	m.mu.Unlock()
+
+	// Replace any pending change that the loop hasn't picked up yet
+	select {
+	case <-m.intervalChan:
+	default:
+	}
+	m.intervalChan <- seconds
+}
+
+// Subscribe returns a channel receiving every new sample and a function to
+// unsubscribe. Slow subscribers miss samples rather than block collection.
+func (m *Monitor) Subscribe() (<-chan ControlSample, func()) {
+	ch := make(chan ControlSample, 64)
+	m.mu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.mu.Unlock()
+
+	return ch, func() {
+		m.mu.Lock()
+		if _, ok := m.subscribers[ch]; ok {
+			delete(m.subscribers, ch)
+			close(ch)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// Status returns the daemon's runtime status for the control socket
+func (m *Monitor) Status() *DaemonStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := &DaemonStatus{
+		ConfigName: m.config.Name,
+		PID:        os.Getpid(),
+		StartTime:  m.startTime,
+		Running:    true,
+		Containers: m.config.Containers,
+		SessionID:  m.sessionID,
+		Interval:   m.config.Interval,
+		Paused:     m.paused,
+		Source:     "socket",
+	}
+	for _, name := range m.config.Containers {
+		cs := ContainerStatus{Name: name}
+		if data := m.containerData[name]; data != nil {
+			cs.ContainerID = data.ContainerID
+			cs.Samples = len(data.Samples)
+			if n := len(data.Samples); n > 0 {
+				cs.LastSample = data.Samples[n-1].Timestamp
+			}
+		} else {
+			cs.LastError = "container not found at startup"
+		}
+		if e, ok := m.lastErrors[name]; ok {
+			cs.LastError = e.message
+			cs.LastErrorTime = e.at
+		}
+		status.ContainerStatus = append(status.ContainerStatus, cs)
+	}
+	return status
+}
+
+// recordError remembers the latest collection error for a container
+func (m *Monitor) recordError(containerName, message string) {
+	m.mu.Lock()
+	m.lastErrors[containerName] = containerError{message: message, at: time.Now()}
+	m.mu.Unlock()
+}
+
+// resumeSession picks up the most recent unfinished session (samples but no
+// final summary) so that initContainer continues it
+func (m *Monitor) resumeSession() {
+	allData, err := LoadAllContainerData(m.config.Name)
 	if err != nil {
-		m.logger.Printf("Warning: failed to get host info: %v\n", err)
-		hostInfo = HostInfo{}
+		return
 	}
 
-	for _, containerName := range m.config.Containers {
-		// Get full container ID
-		fullID, err := m.docker.GetContainerFullID(ctx, containerName)
-		if err != nil {
-			m.logger.Printf("Warning: container %s not found: %v\n", containerName, err)
+	var sessionID string
+	for _, data := range allData {
+		if data.SessionID == "" || data.Summary != nil || len(data.Samples) == 0 {
 			continue
 		}
+		if data.SessionID > sessionID {
+			sessionID = data.SessionID
+		}
+	}
+	if sessionID == "" {
+		return
+	}
+
+	m.sessionID = sessionID
+	m.resumed = make(map[string]*ContainerData)
+	for _, data := range allData {
+		if data.SessionID == sessionID && data.Summary == nil {
+			m.resumed[data.ContainerName] = data
+		}
+	}
+	m.logger.Printf("Resuming session %s\n", sessionID)
+}
 
-		// Get container limits
-		limits, err := m.docker.GetContainerLimits(ctx, fullID)
-		if err != nil {
-			m.logger.Printf("Warning: failed to get limits for %s: %v\n", containerName, err)
+// initializeContainers sets up initial container data structures
+func (m *Monitor) initializeContainers(ctx context.Context) error {
+	if hostPaths.InContainer {
+		m.logger.Printf("Running in a container (host /proc: %s, host /sys: %s)\n", hostPaths.Proc, hostPaths.Sys)
+		for _, w := range hostPaths.Warnings {
+			m.logger.Printf("Warning: %s\n", w)
 		}
+	}
+
+	if _, err := m.syncKubernetesContainers(ctx, &m.config); err != nil {
+		m.logger.Printf("Warning: failed to match pods: %v\n", err)
+	}
 
-		// Get image name
-		imageName, err := m.docker.GetContainerImage(ctx, fullID)
-		if err != nil {
-			imageName = "unknown"
+	// Host info of every endpoint in use, queried concurrently
+	endpoints := []string{""}
+	for _, ref := range m.config.Containers {
+		if endpoint, _ := SplitContainerRef(ref); !containsString(endpoints, endpoint) {
+			endpoints = append(endpoints, endpoint)
 		}
+	}
+	var wg sync.WaitGroup
+	var infoMu sync.Mutex
+	for _, endpoint := range endpoints {
+		wg.Add(1)
+		go func(endpoint string) {
+			defer wg.Done()
+			info := m.fetchHostInfo(ctx, endpoint)
+			infoMu.Lock()
+			m.hostInfo[endpoint] = info
+			infoMu.Unlock()
+		}(endpoint)
+	}
+	wg.Wait()
 
-		m.containerData[containerName] = &ContainerData{
-			ContainerID:   fullID,
-			ContainerName: containerName,
-			ImageName:     imageName,
-			Host:          hostInfo,
-			Limits:        limits,
-			SessionID:     m.sessionID,
-			StartTime:     time.Now(),
-			Interval:      m.config.Interval,
-			Samples:       make([]Sample, 0),
+	// CPU calibration for normalized instance recommendations. A score stored
+	// by `mdok calibrate` is used as is: benchmarking here would compete with
+	// the monitored workload and understate the host. Without one, the
+	// benchmark runs here, so it only describes the local daemon's host.
+	hostInfo := m.hostInfo[""]
+	if m.config.HostCPUFactor > 0 {
+		hostInfo.CPUScore = m.config.HostCPUFactor
+		hostInfo.CPUScoreSource = m.config.HostCPUFactorSource
+		if hostInfo.CPUScoreSource == "" {
+			hostInfo.CPUScoreSource = "user"
 		}
+	} else if m.config.NormalizeCPU {
+		hostInfo.CPUScore = RunCPUBenchmark(defaultBenchmarkDuration)
+		hostInfo.CPUScoreSource = "benchmark"
+		m.logger.Printf("Host CPU score: %.2f (relative to reference vCPU)\n", hostInfo.CPUScore)
+	}
+	m.hostInfo[""] = hostInfo
 
-		m.logger.Printf("Initialized monitoring for container: %s (%s)\n", containerName, fullID[:12])
+	for _, containerName := range m.config.Containers {
+		m.initContainer(ctx, containerName)
 	}
 
 	return nil
 }
 
+// fetchHostInfo asks an endpoint's runtime about its host. For the local
+// runtime, the host's /proc fills in when it can't answer.
+func (m *Monitor) fetchHostInfo(ctx context.Context, endpoint string) HostInfo {
+	cli, err := m.docker.Client(endpoint)
+	if err == nil {
+		var info HostInfo
+		if info, err = cli.GetHostInfo(ctx); err == nil {
+			return info
+		}
+	}
+
+	if endpoint == "" {
+		m.logger.Printf("Warning: failed to get host info: %v\n", err)
+		info := hostInfoFromProc()
+		info.Runtime = m.config.Runtime
+		return info
+	}
+	m.logger.Printf("Warning: failed to get host info for endpoint %s: %v\n", endpoint, err)
+	return HostInfo{Hostname: endpoint}
+}
+
+// initContainer looks up a container and starts its data for this session
+func (m *Monitor) initContainer(ctx context.Context, containerName string) {
+	endpoint, name := SplitContainerRef(containerName)
+	docker, err := m.docker.Client(endpoint)
+	if err != nil {
+		m.logger.Printf("Warning: container %s not found: %v\n", containerName, err)
+		return
+	}
+
+	// Endpoints added by a reload
+	if _, ok := m.hostInfo[endpoint]; !ok {
+		m.hostInfo[endpoint] = m.fetchHostInfo(ctx, endpoint)
+	}
+	m.watchEvents(endpoint)
+
+	// Get full container ID
+	fullID, err := docker.GetContainerFullID(ctx, name)
+	if err != nil {
+		m.logger.Printf("Warning: container %s not found: %v\n", containerName, err)
+		return
+	}
+
+	// Get container limits
+	limits, err := docker.GetContainerLimits(ctx, fullID)
+	if err != nil {
+		m.logger.Printf("Warning: failed to get limits for %s: %v\n", containerName, err)
+	}
+
+	// Get image name
+	imageName, err := docker.GetContainerImage(ctx, fullID)
+	if err != nil {
+		imageName = "unknown"
+	}
+
+	// Compose service (used by rightsize apply)
+	composeService := ""
+	if labels, err := docker.GetContainerLabels(ctx, fullID); err == nil {
+		composeService = labels[composeServiceLabel]
+	}
+
+	data := &ContainerData{
+		ContainerID:    fullID,
+		ContainerName:  containerName,
+		ImageName:      imageName,
+		ComposeService: composeService,
+		Endpoint:       endpoint,
+		Host:           m.hostInfo[endpoint],
+		Limits:         limits,
+		SessionID:      m.sessionID,
+		StartTime:      time.Now(),
+		Interval:       m.config.Interval,
+		Samples:        make([]Sample, 0),
+		ZoneRates:      m.zones.Rates(),
+	}
+
+	// Continue the samples of a resumed session and record the gap
+	if prev := m.resumed[containerName]; prev != nil && len(prev.Samples) > 0 {
+		lastSample := prev.Samples[len(prev.Samples)-1].Timestamp
+		data.StartTime = prev.StartTime
+		data.Samples = prev.Samples
+		data.Events = append(prev.Events, SessionEvent{
+			Time:    time.Now(),
+			Type:    SessionEventResumed,
+			Message: fmt.Sprintf("daemon restarted, no samples for %s", formatDuration(time.Since(lastSample))),
+		})
+		delete(m.resumed, containerName)
+	} else if prev := m.departed[containerName]; prev != nil {
+		// Removed earlier in this session (e.g. a pod that stopped matching)
+		data.StartTime = prev.StartTime
+		data.Samples = prev.Samples
+		data.Events = append(prev.Events, SessionEvent{
+			Time:    time.Now(),
+			Type:    SessionEventReconfigured,
+			Message: "added back to configuration",
+		})
+	}
+	delete(m.departed, containerName)
+
+	m.mu.Lock()
+	m.containerData[containerName] = data
+	m.mu.Unlock()
+
+	m.logger.Printf("Initialized monitoring for container: %s (%s)\n", containerName, fullID[:12])
+}
+
+// watchEvents follows container starts and stops on an endpoint and records
+// them in the session. A container recreated under its name (e.g. by compose)
+// is followed to its new ID. Failed event streams are reopened.
+func (m *Monitor) watchEvents(endpoint string) {
+	if m.watching[endpoint] {
+		return
+	}
+	m.watching[endpoint] = true
+
+	go func() {
+		for {
+			cli, err := m.docker.Client(endpoint)
+			if err == nil {
+				events, errs := cli.Events(m.eventsCtx)
+				for event := range events {
+					m.handleRuntimeEvent(endpoint, event)
+				}
+				select {
+				case err = <-errs:
+				default:
+				}
+			}
+			if err != nil && m.eventsCtx.Err() == nil {
+				m.logger.Printf("Warning: container events unavailable (%v), retrying in %s\n", err, eventsRetryInterval)
+			}
+
+			select {
+			case <-m.eventsCtx.Done():
+				return
+			case <-time.After(eventsRetryInterval):
+			}
+		}
+	}()
+}
+
+// handleRuntimeEvent records a monitored container starting or stopping
+func (m *Monitor) handleRuntimeEvent(endpoint string, event RuntimeEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for containerName, data := range m.containerData {
+		if data == nil || data.Endpoint != endpoint {
+			continue
+		}
+		_, name := SplitContainerRef(containerName)
+
+		var message string
+		switch {
+		case event.ContainerID == data.ContainerID && event.Action == RuntimeEventStop:
+			message = "container stopped"
+		case event.ContainerID == data.ContainerID:
+			message = "container started"
+		case event.Action == RuntimeEventStart && event.Name == name:
+			message = fmt.Sprintf("container recreated as %s", shortID(event.ContainerID))
+			data.ContainerID = event.ContainerID
+		default:
+			continue
+		}
+
+		sessionEvent := SessionEvent{Time: event.Time, Type: SessionEventStarted, Message: message}
+		if event.Action == RuntimeEventStop {
+			sessionEvent.Type = SessionEventStopped
+		} else {
+			// Counters start over with the new process
+			delete(m.prevStats, containerName)
+		}
+		data.Events = append(data.Events, sessionEvent)
+		m.logger.Printf("[%s] %s\n", containerName, message)
+	}
+}
+
+// shortID returns the 12-character form of a container ID
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
 // collectAllStats collects stats from all containers
 func (m *Monitor) collectAllStats(ctx context.Context) {
+	m.mu.Lock()
+	paused := m.paused
+	m.mu.Unlock()
+	if paused {
+		return
+	}
+
 	var wg sync.WaitGroup
 
 	for _, containerName := range m.config.Containers {
@@ -168,32 +611,76 @@ func (m *Monitor) collectAllStats(ctx context.Context) {
 
 // collectContainerStats collects stats for a single container
 func (m *Monitor) collectContainerStats(ctx context.Context, containerName string) {
+	// The container ID changes when a runtime event reports the container was
+	// recreated, so it is read with prev and checked again before storing
 	m.mu.Lock()
 	data := m.containerData[containerName]
 	prev := m.prevStats[containerName]
+	var containerID, endpoint string
+	if data != nil {
+		containerID, endpoint = data.ContainerID, data.Endpoint
+	}
+	namer := m.namer
+	zones := m.zones
+	proxies := m.proxies
 	m.mu.Unlock()
 
 	if data == nil {
 		return
 	}
 
+	docker, err := m.docker.Client(endpoint)
+	if err != nil {
+		m.recordError(containerName, err.Error())
+		return
+	}
+
 	// Check if container is still running
-	running, err := m.docker.IsContainerRunning(ctx, data.ContainerID)
+	running, err := docker.IsContainerRunning(ctx, containerID)
 	if err != nil || !running {
 		m.logger.Printf("Container %s is no longer running\n", containerName)
+		m.recordError(containerName, "container is not running")
 		return
 	}
 
 	// Collect stats
-	stats, err := m.docker.CollectStats(ctx, data.ContainerID, prev)
+	stats, err := docker.CollectStats(ctx, containerID, prev)
 	if err != nil {
 		m.logger.Printf("Error collecting stats for %s: %v\n", containerName, err)
+		m.recordError(containerName, err.Error())
 		return
 	}
 
+	// Network breakdown (classify active connections and bytes)
+	// This is best-effort and may fail silently
+	var prevFlows map[string]conntrackFlow
+	if prev != nil {
+		prevFlows = prev.PrevFlows
+	}
+	applyNetworkStats(stats, getNetworkStats(ctx, docker, containerID, prevFlows, zones, proxies, namer))
+	applyZoneBandwidth(stats, prev)
+
 	m.mu.Lock()
+	if current := m.containerData[containerName]; current != data || current.ContainerID != containerID {
+		// Recreated or removed while collecting: the sample is the old
+		// container's and must not become the new one's baseline
+		m.mu.Unlock()
+		m.logger.Printf("[%s] dropped sample of replaced container %s\n", containerName, shortID(containerID))
+		return
+	}
 	m.prevStats[containerName] = stats
 	m.containerData[containerName].Samples = append(m.containerData[containerName].Samples, stats.Sample)
+	recordProxies(m.containerData[containerName], stats.Proxies)
+	if stats.Namespace != nil {
+		m.containerData[containerName].NetworkNamespace = stats.Namespace
+	}
+	delete(m.lastErrors, containerName)
+	for ch := range m.subscribers {
+		select {
+		case ch <- ControlSample{ContainerName: containerName, Sample: stats.Sample}:
+		default:
+		}
+	}
 	m.mu.Unlock()
 
 	m.logger.Printf("[%s] CPU: %.1f%% | Mem: %s (%.1f%%) | Net rx/tx: %s/%s\n",
@@ -232,34 +719,272 @@ func (m *Monitor) shutdown() {
 		if data == nil || len(data.Samples) == 0 {
 			continue
 		}
+		m.finalizeContainerData(data)
+	}
 
-		data.EndTime = time.Now()
+	// End live streams
+	for ch := range m.subscribers {
+		delete(m.subscribers, ch)
+		close(ch)
+	}
+	m.mu.Unlock()
 
-		// Calculate summary statistics
-		data.Summary = CalculateSummary(data.Samples)
+	m.stopEvents()
+	m.docker.Close()
+	m.logger.Println("Monitoring stopped")
+}
 
-		// Calculate network cost estimates
-		data.NetworkCost = CalculateNetworkCost(data.Summary.NetTxTotal)
+// finalizeContainerData calculates the final summary and recommendations for
+// a container and saves it
+func (m *Monitor) finalizeContainerData(data *ContainerData) {
+	data.EndTime = time.Now()
+	summarizeContainerData(data)
 
-		// Generate instance recommendation (default to x86 for backward compatibility)
-		data.Recommendation = RecommendInstance(data.Summary, "x86")
+	if err := SaveContainerData(m.config.Name, data); err != nil {
+		m.logger.Printf("Error saving final data for %s: %v\n", data.ContainerName, err)
+	}
 
-		// Detect warnings
-		data.Summary.Warnings = DetectWarnings(data)
+	m.logger.Printf("Saved summary for %s (%d samples)\n", data.ContainerName, len(data.Samples))
+}
 
-		// Set duration
-		data.Summary.Duration = data.EndTime.Sub(data.StartTime).Round(time.Second).String()
+// summarizeContainerData fills in the summary, cost estimate, recommendations
+// and warnings of a finished session
+func summarizeContainerData(data *ContainerData) {
+	// Calculate summary statistics
+	data.Summary = CalculateSummary(data.Samples)
 
-		if err := SaveContainerData(m.config.Name, data); err != nil {
-			m.logger.Printf("Error saving final data for %s: %v\n", data.ContainerName, err)
+	// Calculate network cost estimates
+	data.NetworkCost = CalculateNetworkCost(data)
+
+	// Generate instance recommendation (default to x86 for backward compatibility)
+	data.Recommendation = RecommendInstanceForHost(data.Summary, "x86", data.Host)
+	data.Fargate = RecommendFargateBothArchitectures(data.Summary, data.Host)
+
+	// Detect warnings
+	data.Summary.Warnings = DetectWarnings(data)
+
+	// Set duration
+	data.Summary.Duration = data.EndTime.Sub(data.StartTime).Round(time.Second).String()
+}
+
+// Reload asks the monitoring loop to re-read the config and waits for the result
+func (m *Monitor) Reload() error {
+	reply := make(chan error, 1)
+	select {
+	case m.reloadChan <- reply:
+		return <-reply
+	case <-m.stopChan:
+		return fmt.Errorf("monitor is stopping")
+	}
+}
+
+// reload re-reads the config file and applies container and interval changes
+// to the running session. Collected samples are kept, removed containers are
+// finalized, and a "reconfigured" event is recorded in each container's data.
+// mdok has no alert rules, so reloading them is out of scope until alerting
+// exists. It must only be called from the monitoring loop.
+func (m *Monitor) reload(ctx context.Context, trigger string) error {
+	configFile := GetConfigFile(m.config.Name)
+	if info, err := os.Stat(configFile); err == nil {
+		m.configModTime = info.ModTime()
+	}
+
+	config, err := LoadConfig(m.config.Name)
+	if err != nil {
+		m.logger.Printf("Reload (%s) failed: %v\n", trigger, err)
+		return err
+	}
+	if config.Interval < 1 {
+		err := fmt.Errorf("invalid interval: %d", config.Interval)
+		m.logger.Printf("Reload (%s) failed: %v\n", trigger, err)
+		return err
+	}
+	if err := ValidateEndpoints(config); err != nil {
+		m.logger.Printf("Reload (%s) failed: %v\n", trigger, err)
+		return err
+	}
+	namer, err := NewDestinationNamer(config)
+	if err != nil {
+		m.logger.Printf("Reload (%s) failed: %v\n", trigger, err)
+		return err
+	}
+	zones, err := NewNetworkZones(config)
+	if err != nil {
+		m.logger.Printf("Reload (%s) failed: %v\n", trigger, err)
+		return err
+	}
+	proxies, err := NewProxyDetector(config)
+	if err != nil {
+		m.logger.Printf("Reload (%s) failed: %v\n", trigger, err)
+		return err
+	}
+	if config.Runtime != m.config.Runtime {
+		// Container IDs and clients belong to the runtime the session started with
+		m.logger.Printf("Reload (%s): runtime change to %q takes effect after a restart\n", trigger, config.Runtime)
+		config.Runtime = m.config.Runtime
+	}
+	if config.Runtime == RuntimeKubernetes {
+		m.docker.SetKubernetes(config.Kubernetes)
+		if _, err := m.syncKubernetesContainers(ctx, &config); err != nil {
+			m.logger.Printf("Reload (%s): failed to match pods, keeping containers: %v\n", trigger, err)
+			config.Containers = m.config.Containers
+		}
+	}
+
+	var changes []string
+	now := time.Now()
+
+	// Removed containers: finalize what was collected so far
+	for _, name := range m.config.Containers {
+		if containsString(config.Containers, name) {
+			continue
+		}
+		m.mu.Lock()
+		data := m.containerData[name]
+		delete(m.containerData, name)
+		delete(m.prevStats, name)
+		delete(m.lastErrors, name)
+		if data != nil && len(data.Samples) > 0 {
+			data.Events = append(data.Events, SessionEvent{
+				Time:    now,
+				Type:    SessionEventReconfigured,
+				Message: "removed from configuration",
+			})
+			m.finalizeContainerData(data)
+			m.departed[name] = data
 		}
+		m.mu.Unlock()
+		changes = append(changes, "removed "+name)
+	}
 
-		m.logger.Printf("Saved summary for %s (%d samples)\n", data.ContainerName, len(data.Samples))
+	// Endpoint changes take effect before new containers are looked up
+	if !reflect.DeepEqual(config.Endpoints, m.config.Endpoints) {
+		m.docker.SetEndpoints(config.Endpoints)
+		m.mu.Lock()
+		m.config.Endpoints = config.Endpoints
+		m.mu.Unlock()
+		changes = append(changes, "endpoints updated")
+	}
+
+	// Added containers join the current session
+	for _, name := range config.Containers {
+		if containsString(m.config.Containers, name) {
+			continue
+		}
+		m.initContainer(ctx, name)
+		changes = append(changes, "added "+name)
+	}
+
+	intervalChanged := config.Interval != m.config.Interval
+	if intervalChanged {
+		changes = append(changes, fmt.Sprintf("interval %ds -> %ds", m.config.Interval, config.Interval))
+	}
+
+	if !reflect.DeepEqual(config.NetworkLabels, m.config.NetworkLabels) || config.NoReverseDNS != m.config.NoReverseDNS {
+		changes = append(changes, "destination naming updated")
+	}
+	zonesChanged := !reflect.DeepEqual(config.NetworkZones, m.config.NetworkZones)
+	if zonesChanged {
+		changes = append(changes, "network zones updated")
+	}
+	if !reflect.DeepEqual(config.ProxyRules, m.config.ProxyRules) || config.NoDefaultProxyPatterns != m.config.NoDefaultProxyPatterns {
+		changes = append(changes, "proxy rules updated")
+	}
+
+	m.mu.Lock()
+	interval := m.config.Interval
+	m.config = config
+	m.config.Interval = interval
+	m.namer = namer
+	m.zones = zones
+	m.proxies = proxies
+	if zonesChanged {
+		for _, data := range m.containerData {
+			if data != nil {
+				data.ZoneRates = zones.Rates()
+			}
+		}
 	}
 	m.mu.Unlock()
+	if intervalChanged {
+		m.SetInterval(config.Interval)
+	}
 
-	m.docker.Close()
-	m.logger.Println("Monitoring stopped")
+	if len(changes) == 0 {
+		m.logger.Printf("Reload (%s): no changes\n", trigger)
+		return nil
+	}
+
+	message := strings.Join(changes, "; ")
+	m.logger.Printf("Reload (%s): %s\n", trigger, message)
+
+	m.mu.Lock()
+	for _, data := range m.containerData {
+		if data != nil {
+			data.Events = append(data.Events, SessionEvent{
+				Time:    now,
+				Type:    SessionEventReconfigured,
+				Message: message,
+			})
+		}
+	}
+	m.mu.Unlock()
+
+	m.saveData()
+	return nil
+}
+
+// syncKubernetesContainers sets a kubernetes configuration's containers to
+// the running containers its target selects, saving the config when they
+// changed. It reports whether they changed.
+func (m *Monitor) syncKubernetesContainers(ctx context.Context, config *Config) (bool, error) {
+	if config.Runtime != RuntimeKubernetes {
+		return false, nil
+	}
+	cli, err := m.docker.Client("")
+	if err != nil {
+		return false, err
+	}
+	kube, ok := cli.(*KubeletClient)
+	if !ok {
+		return false, nil
+	}
+
+	refs, err := kube.MatchingContainers(ctx)
+	if err != nil {
+		return false, err
+	}
+	if slices.Equal(refs, config.Containers) {
+		return false, nil
+	}
+
+	config.Containers = refs
+	if err := SaveConfig(*config); err != nil {
+		return false, err
+	}
+	if info, err := os.Stat(GetConfigFile(config.Name)); err == nil {
+		m.configModTime = info.ModTime()
+	}
+	return true, nil
+}
+
+// kubernetesChanged reports whether the running containers the target
+// selects differ from the monitored ones
+func (m *Monitor) kubernetesChanged(ctx context.Context) bool {
+	cli, err := m.docker.Client("")
+	if err != nil {
+		return false
+	}
+	kube, ok := cli.(*KubeletClient)
+	if !ok {
+		return false
+	}
+	refs, err := kube.MatchingContainers(ctx)
+	if err != nil {
+		m.logger.Printf("Warning: failed to match pods: %v\n", err)
+		return false
+	}
+	return !slices.Equal(refs, m.config.Containers)
 }
 
 // GetContainerData returns the current container data (for dashboard)
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// Network modes of containers without a network namespace of their own
+const (
+	NetworkModeHost      = "host"
+	networkModeContainer = "container:" // Followed by the name or ID of the container whose namespace it joins
+)
+
+// namespaceHost is the key of the host's network namespace in
+// networkNamespaces
+const namespaceHost = "host"
+
+// networkNamespaces groups containers by the network namespace they use. It
+// maps each container's ID to its namespace: namespaceHost, the ID of the
+// container owning it, or the network mode when that container isn't
+// running. Containers joining one that joined another end up in the first
+// one's namespace.
+func networkNamespaces(containers []NetworkContainer) map[string]string {
+	namespaces := make(map[string]string, len(containers))
+	for _, c := range containers {
+		current := c
+		for range containers {
+			if current.NetworkMode == NetworkModeHost {
+				namespaces[c.ID] = namespaceHost
+				break
+			}
+			ref, joins := strings.CutPrefix(current.NetworkMode, networkModeContainer)
+			if !joins {
+				namespaces[c.ID] = current.ID
+				break
+			}
+			owner := findNetworkContainer(containers, ref)
+			if owner == nil {
+				namespaces[c.ID] = current.NetworkMode
+				break
+			}
+			current = *owner
+		}
+		if _, ok := namespaces[c.ID]; !ok {
+			namespaces[c.ID] = c.NetworkMode // Containers joining each other in a loop
+		}
+	}
+	return namespaces
+}
+
+// findNetworkContainer finds a container by name, ID or ID prefix
+func findNetworkContainer(containers []NetworkContainer, ref string) *NetworkContainer {
+	ref = strings.TrimPrefix(ref, "/")
+	for i, c := range containers {
+		if c.ID == ref {
+			return &containers[i]
+		}
+		for _, name := range c.Names {
+			if strings.TrimPrefix(name, "/") == ref {
+				return &containers[i]
+			}
+		}
+	}
+	for i, c := range containers {
+		if ref != "" && strings.HasPrefix(c.ID, ref) {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// networkContainerName is the name of a listed container
+func networkContainerName(c NetworkContainer) string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return shortID(c.ID)
+}
+
+// describeNamespace returns the network namespace a container shares, or nil
+// if it has one of its own
+func describeNamespace(containers []NetworkContainer, namespaces map[string]string, target NetworkContainer) *NetworkNamespace {
+	key := namespaces[target.ID]
+	ns := &NetworkNamespace{}
+	switch {
+	case key == namespaceHost:
+		ns.Mode = NetworkModeHost
+	case key == target.ID:
+		ns.Owner = networkContainerName(target)
+	default:
+		ns.Owner = strings.TrimPrefix(key, networkModeContainer)
+		if owner := findNetworkContainer(containers, key); owner != nil {
+			ns.Owner = networkContainerName(*owner)
+		}
+		ns.Mode = networkModeContainer + ns.Owner
+	}
+
+	for _, c := range containers {
+		if c.ID != target.ID && namespaces[c.ID] == key {
+			ns.Shared = append(ns.Shared, networkContainerName(c))
+		}
+	}
+	slices.Sort(ns.Shared)
+	if ns.Mode == "" && len(ns.Shared) == 0 {
+		return nil
+	}
+	return ns
+}
+
+// namespaceAddrs returns the addresses of a container's network namespace,
+// from its routing tables and IPv6 interfaces. Unlike the runtime's network
+// listing, this also knows the addresses of the host's namespace and of
+// namespaces joined from another container. Files that can't be read are
+// skipped.
+func namespaceAddrs(ctx context.Context, files *containerNetFiles) []string {
+	var addrs []string
+
+	// Local addresses show up in fib_trie as
+	//   |-- 172.18.0.3
+	//      /32 host LOCAL
+	if data, err := files.read(ctx, "fib_trie"); err == nil {
+		var last string
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if ip, ok := strings.CutPrefix(line, "|-- "); ok {
+				last = ip
+				continue
+			}
+			if strings.HasPrefix(line, "/32 host LOCAL") && last != "" {
+				addrs = append(addrs, last)
+			}
+		}
+	}
+
+	// if_inet6 lines start with the address in hex:
+	// fd00000000000000000000000000000a 02 40 00 80 eth0
+	if data, err := files.read(ctx, "if_inet6"); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 {
+				continue
+			}
+			if raw, err := hex.DecodeString(fields[0]); err == nil && len(raw) == net.IPv6len {
+				addrs = append(addrs, net.IP(raw).String())
+			}
+		}
+	}
+
+	slices.Sort(addrs)
+	return slices.Compact(addrs)
+}
+
+// SharedNamespace is a network namespace monitored containers share with
+// each other or the host. Their network figures are the namespace's, so
+// config-wide totals count it once, through its first container.
+type SharedNamespace struct {
+	Host       bool     `json:"host"`             // The host's namespace
+	Owner      string   `json:"owner"`            // Container owning it; "" for the host's
+	Containers []string `json:"containers"`       // Monitored containers in it; the first one's figures count
+	Others     []string `json:"others,omitempty"` // Running containers in it that aren't monitored
+	RxBytes    uint64   `json:"rx_bytes"`
+	TxBytes    uint64   `json:"tx_bytes"`
+}
+
+// SharedNamespaces groups a configuration's containers by the network
+// namespaces they share. The owner counts for its namespace if monitored,
+// else the first container by name.
+func SharedNamespaces(allData []*ContainerData) []SharedNamespace {
+	byKey := make(map[string]*SharedNamespace)
+	monitored := make(map[string]bool)
+	for _, data := range allData {
+		monitored[data.ContainerName] = true
+	}
+
+	for _, data := range allData {
+		ns := data.NetworkNamespace
+		if ns == nil {
+			continue
+		}
+		key := data.Endpoint + "\x00" + namespaceHost
+		if ns.Mode != NetworkModeHost {
+			key = peerRef(data, ns.Owner)
+		}
+		shared, ok := byKey[key]
+		if !ok {
+			shared = &SharedNamespace{Host: ns.Mode == NetworkModeHost}
+			if !shared.Host {
+				shared.Owner = key
+			}
+			byKey[key] = shared
+		}
+		shared.Containers = append(shared.Containers, data.ContainerName)
+		for _, name := range ns.Shared {
+			if ref := peerRef(data, name); !monitored[ref] && !slices.Contains(shared.Others, ref) {
+				shared.Others = append(shared.Others, ref)
+			}
+		}
+	}
+
+	byName := make(map[string]*ContainerData)
+	for _, data := range allData {
+		byName[data.ContainerName] = data
+	}
+	var namespaces []SharedNamespace
+	for _, shared := range byKey {
+		slices.SortFunc(shared.Containers, func(a, b string) int {
+			switch {
+			case a == shared.Owner:
+				return -1
+			case b == shared.Owner:
+				return 1
+			}
+			return strings.Compare(a, b)
+		})
+		slices.Sort(shared.Others)
+		if summary := byName[shared.Containers[0]].Summary; summary != nil {
+			shared.RxBytes, shared.TxBytes = summary.NetRxTotal, summary.NetTxTotal
+		}
+		namespaces = append(namespaces, *shared)
+	}
+	slices.SortFunc(namespaces, func(a, b SharedNamespace) int {
+		return strings.Compare(a.Containers[0], b.Containers[0])
+	})
+	return namespaces
+}
+
+// namespaceCounted maps each container sharing a network namespace to the
+// one whose figures count for it
+func namespaceCounted(namespaces []SharedNamespace) map[string]string {
+	counted := make(map[string]string)
+	for _, ns := range namespaces {
+		for _, name := range ns.Containers {
+			counted[name] = ns.Containers[0]
+		}
+	}
+	return counted
+}
+
+// Name describes a shared namespace
+func (ns SharedNamespace) Name() string {
+	if ns.Host {
+		return "host network"
+	}
+	return ns.Owner + " network namespace"
+}
+
+// formatNetworkNamespace describes how a container shares its network
+// namespace, with the caveat that applies to its network figures
+func formatNetworkNamespace(ns *NetworkNamespace) string {
+	var line string
+	switch {
+	case ns.Mode == NetworkModeHost:
+		line = "the host's"
+	case ns.Mode != "":
+		line = ns.Owner + "'s"
+	default:
+		line = "its own"
+	}
+	if len(ns.Shared) > 0 {
+		line += ", shared with " + strings.Join(ns.Shared, ", ")
+	}
+	return line + "; " + namespaceCaveat(ns.Mode == NetworkModeHost)
+}
+
+// namespaceCaveat explains what a shared namespace's network figures mean
+func namespaceCaveat(host bool) string {
+	if host {
+		return "network figures are the host's, including processes outside containers"
+	}
+	return "network figures are the namespace's, not this container's alone"
+}
+
+// formatSharedNamespaces lays out shared namespaces as text lines
+func formatSharedNamespaces(namespaces []SharedNamespace) []string {
+	var lines []string
+	for _, ns := range namespaces {
+		lines = append(lines, fmt.Sprintf("%s: %s  rx %s  tx %s (counted once, via %s)",
+			ns.Name(), strings.Join(sharedNamespaceMembers(ns), ", "),
+			formatBytes(ns.RxBytes), formatBytes(ns.TxBytes), ns.Containers[0]))
+	}
+	return lines
+}
+
+// sharedNamespaceMembers lists the containers in a shared namespace, noting
+// the ones that aren't monitored
+func sharedNamespaceMembers(ns SharedNamespace) []string {
+	members := slices.Clone(ns.Containers)
+	for _, name := range ns.Others {
+		members = append(members, name+" (not monitored)")
+	}
+	return members
+}
@@ -2,14 +2,16 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
+	"io/fs"
 	"net"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
-
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
 )
 
 // isPrivateIP checks if an IP is in private ranges (RFC1918 + others)
@@ -37,108 +39,105 @@ func isPrivateIP(ip net.IP) bool {
 	return false
 }
 
-const proxyLabelKey = "mdok.proxy"
-
-// proxyImagePatterns are image substrings that indicate a proxy container
-// These are reverse proxies / API gateways that route traffic to the internet
-var proxyImagePatterns = []string{
-	"traefik",
-	"nginx",
-	"caddy",
-	"haproxy",
-	"envoy",
-	"litellm", // LLM API proxy (OpenAI, Anthropic, etc.)
+// networkPeers is what a container's traffic is classified against
+type networkPeers struct {
+	containers map[string]bool              // IPs of containers on the same networks, proxies included
+	proxies    map[string]float64           // Internet fraction of proxy containers on any network, by IP
+	classified []ProxyClassification        // Containers proxy rules, labels or patterns matched
+	self       map[string]bool              // IPs of the container's network namespace
+	namespace  *NetworkNamespace            // The network namespace, if shared
+	names      map[string]string            // Names of all containers by IP
+	labels     map[string]map[string]string // Labels of all containers by IP
 }
 
-func isProxyContainer(c types.Container) bool {
-	// Explicit label takes precedence (can also be used to exclude with "false")
-	if val, ok := c.Labels[proxyLabelKey]; ok {
-		// Return false for explicit "false"/"no"/"0" to allow excluding containers
-		if strings.EqualFold(val, "false") || strings.EqualFold(val, "no") || strings.EqualFold(val, "0") {
-			return false
-		}
-		return strings.EqualFold(val, "true") || strings.EqualFold(val, "1") || strings.EqualFold(val, "yes")
+// getNetworkPeers gets all IPs of containers in the same networks.
+// It also returns ALL proxy container IPs (regardless of network) so that
+// traffic through proxies on different networks is correctly classified,
+// the target container's own IPs, and the names and labels of all
+// containers by IP. Containers sharing the target's network namespace count
+// as the target: their IPs and networks are its own. A container on the
+// host's network reaches every other container directly.
+func getNetworkPeers(ctx context.Context, rt Runtime, targetContainerID string, proxies *ProxyDetector) (*networkPeers, error) {
+	peers := &networkPeers{
+		containers: make(map[string]bool),
+		proxies:    make(map[string]float64),
+		self:       make(map[string]bool),
+		names:      make(map[string]string),
+		labels:     make(map[string]map[string]string),
 	}
 
-	image := strings.ToLower(c.Image)
-	for _, pattern := range proxyImagePatterns {
-		if strings.Contains(image, pattern) {
-			return true
-		}
+	// List all containers
+	containers, err := rt.ListNetworkContainers(ctx)
+	if err != nil {
+		return peers, err
 	}
 
-	for _, name := range c.Names {
-		lower := strings.ToLower(name)
-		for _, pattern := range proxyImagePatterns {
-			if strings.Contains(lower, pattern) {
-				return true
+	// Addresses of a shared namespace are named after its owner
+	namespaces := networkNamespaces(containers)
+	for _, c := range containers {
+		for _, addrs := range c.Networks {
+			for _, addr := range addrs {
+				if _, named := peers.names[addr]; len(c.Names) > 0 && (!named || namespaces[c.ID] == c.ID) {
+					peers.names[addr] = strings.TrimPrefix(c.Names[0], "/")
+				}
+				if c.Labels != nil {
+					peers.labels[addr] = c.Labels
+				}
 			}
 		}
 	}
 
-	return false
-}
-
-// getContainerIPs gets all IPs of containers in the same Docker networks.
-// It also returns ALL proxy container IPs (regardless of network) so that
-// traffic through proxies on different networks is correctly classified.
-func (d *DockerClient) getContainerIPs(ctx context.Context, targetContainerID string) (map[string]bool, map[string]bool, error) {
-	containerIPs := make(map[string]bool)
-	proxyIPs := make(map[string]bool)
-
-	// Get target container's networks
-	targetInfo, err := d.cli.ContainerInspect(ctx, targetContainerID)
-	if err != nil {
-		return containerIPs, proxyIPs, err
-	}
-
-	// Get all network IDs the target is connected to
+	// Get all networks the target's namespace is connected to
+	targetNamespace, ok := namespaces[targetContainerID]
 	targetNetworks := make(map[string]bool)
-	if targetInfo.NetworkSettings != nil {
-		for netName := range targetInfo.NetworkSettings.Networks {
+	for _, c := range containers {
+		if c.ID == targetContainerID {
+			peers.namespace = describeNamespace(containers, namespaces, c)
+		}
+		if !ok || namespaces[c.ID] != targetNamespace {
+			continue
+		}
+		for netName, addrs := range c.Networks {
 			targetNetworks[netName] = true
+			for _, addr := range addrs {
+				peers.self[addr] = true
+			}
 		}
 	}
-
-	// List all containers
-	containers, err := d.cli.ContainerList(ctx, container.ListOptions{})
-	if err != nil {
-		return containerIPs, proxyIPs, err
+	inNamespace := func(c NetworkContainer) bool {
+		return c.ID == targetContainerID || (ok && namespaces[c.ID] == targetNamespace)
 	}
 
 	// First pass: collect ALL proxy IPs (regardless of network)
 	// This ensures traffic to proxies on different networks is classified as internet
 	for _, c := range containers {
-		if c.ID == targetContainerID {
+		if inNamespace(c) {
 			continue
 		}
 
-		if isProxyContainer(c) {
-			for _, network := range c.NetworkSettings.Networks {
-				if network.IPAddress != "" {
-					proxyIPs[network.IPAddress] = true
-				}
-				if network.GlobalIPv6Address != "" {
-					proxyIPs[network.GlobalIPv6Address] = true
+		class := proxies.detect(c)
+		if class.Reason != "" {
+			peers.classified = append(peers.classified, class)
+		}
+		if class.Proxy {
+			for _, addrs := range c.Networks {
+				for _, addr := range addrs {
+					peers.proxies[addr] = class.InternetFraction
 				}
 			}
 		}
 	}
 
-	// Second pass: collect IPs of non-proxy containers on same networks
+	// Second pass: collect IPs of containers on same networks. Proxies are
+	// kept too, for the share of their traffic that isn't internet.
 	for _, c := range containers {
-		if c.ID == targetContainerID {
-			continue
-		}
-
-		// Skip proxies - they're already handled and shouldn't count as inter-container
-		if isProxyContainer(c) {
+		if inNamespace(c) {
 			continue
 		}
 
 		// Check if this container shares any networks
-		sharesNetwork := false
-		for netName := range c.NetworkSettings.Networks {
+		sharesNetwork := targetNamespace == namespaceHost
+		for netName := range c.Networks {
 			if targetNetworks[netName] {
 				sharesNetwork = true
 				break
@@ -146,18 +145,15 @@ func (d *DockerClient) getContainerIPs(ctx context.Context, targetContainerID st
 		}
 
 		if sharesNetwork {
-			for _, network := range c.NetworkSettings.Networks {
-				if network.IPAddress != "" {
-					containerIPs[network.IPAddress] = true
-				}
-				if network.GlobalIPv6Address != "" {
-					containerIPs[network.GlobalIPv6Address] = true
+			for _, addrs := range c.Networks {
+				for _, addr := range addrs {
+					peers.containers[addr] = true
 				}
 			}
 		}
 	}
 
-	return containerIPs, proxyIPs, nil
+	return peers, nil
 }
 
 // parseHexIP parses a hex IP address from /proc/net/tcp format
@@ -181,262 +177,400 @@ func parseHexIP(hexIP string) net.IP {
 	return nil
 }
 
-// classifyConnections reads /proc/net/tcp and /proc/net/tcp6 from a container
-// and classifies connections by destination
-func (d *DockerClient) classifyConnections(ctx context.Context, containerID string, containerIPs map[string]bool, proxyIPs map[string]bool) (interContainer, internal, internet int, err error) {
-	// Read both IPv4 and IPv6 connection tables
-	for _, file := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
-		counts, err := d.readProcNetFile(ctx, containerID, file, containerIPs, proxyIPs)
-		if err != nil {
-			continue // Silently skip if file not readable
+// containerNetFiles reads the /proc/net files (tcp, tcp6, udp, udp6,
+// nf_conntrack, ...) of a container's network namespace. They are read from
+// the host through the container's init process, so nothing runs inside the
+// container; exec'ing cat in it is the fallback when the host's /proc can't
+// be used (remote endpoints, no host /proc mount, missing permissions).
+type containerNetFiles struct {
+	rt  Runtime
+	id  string
+	pid int // Host PID of the container's init process; 0 to use exec
+}
+
+// openContainerNetFiles resolves a container's init PID. The PID is only used
+// if the host's /proc shows it in the container's cgroup, which rules out
+// PIDs from a remote daemon or another PID namespace.
+func openContainerNetFiles(ctx context.Context, rt Runtime, containerID string) *containerNetFiles {
+	files := &containerNetFiles{rt: rt, id: containerID}
+	if pid, err := rt.ContainerPID(ctx, containerID); err == nil && pid > 0 {
+		cgroup, err := os.ReadFile(hostPaths.ProcPath(strconv.Itoa(pid), "cgroup"))
+		if err == nil && strings.Contains(string(cgroup), containerID) {
+			files.pid = pid
 		}
-		interContainer += counts[0]
-		internal += counts[1]
-		internet += counts[2]
 	}
-
-	return interContainer, internal, internet, nil
+	return files
 }
 
-// readProcNetFile reads a /proc/net/tcp* file and classifies connections
-func (d *DockerClient) readProcNetFile(ctx context.Context, containerID string, procFile string, containerIPs map[string]bool, proxyIPs map[string]bool) ([3]int, error) {
-	var counts [3]int // [interContainer, internal, internet]
-
-	// Execute cat to read the proc file using Docker exec
-	execConfig := types.ExecConfig{
-		Cmd:          []string{"cat", procFile},
-		AttachStdout: true,
-		AttachStderr: true,
+// read returns the contents of /proc/net/<name> in the container
+func (f *containerNetFiles) read(ctx context.Context, name string) ([]byte, error) {
+	if f.pid > 0 {
+		data, err := os.ReadFile(hostPaths.ProcPath(strconv.Itoa(f.pid), "net", name))
+		if err == nil || errors.Is(err, fs.ErrNotExist) {
+			// A file missing on the host (e.g. nf_conntrack without the
+			// module loaded) is missing in the container too
+			return data, err
+		}
 	}
+	return f.rt.Exec(ctx, f.id, []string{"cat", "/proc/net/" + name})
+}
 
-	execID, err := d.cli.ContainerExecCreate(ctx, containerID, execConfig)
-	if err != nil {
-		return counts, err
-	}
+// destinationTally collects a sample's traffic per remote "ip:port"
+type destinationTally map[string]*DestinationSample
 
-	resp, err := d.cli.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
-	if err != nil {
-		return counts, err
+// add returns the entry for a destination, creating it on first use
+func (t destinationTally) add(ip net.IP, port uint64, zone string) *DestinationSample {
+	addr := net.JoinHostPort(ip.String(), strconv.FormatUint(port, 10))
+	d, ok := t[addr]
+	if !ok {
+		d = &DestinationSample{Addr: addr, Class: zone}
+		t[addr] = d
 	}
-	defer resp.Close()
-
-	scanner := bufio.NewScanner(resp.Reader)
-	lineNum := 0
-
-	for scanner.Scan() {
-		lineNum++
-		if lineNum == 1 {
-			continue // Skip header
-		}
-
-		line := scanner.Text()
-		fields := strings.Fields(line)
-		if len(fields) < 3 {
-			continue
-		}
+	return d
+}
 
-		// Field 2 is remote_address in format "IP:PORT"
-		remoteAddr := fields[2]
-		parts := strings.Split(remoteAddr, ":")
-		if len(parts) != 2 {
-			continue
-		}
+// peerTally collects a sample's traffic per peer container or zone and
+// direction
+type peerTally map[peerKey]*PeerTraffic
 
-		hexIP := parts[0]
-		ip := parseHexIP(hexIP)
-		if ip == nil {
-			continue
-		}
+type peerKey struct {
+	container, zone string
+	incoming        bool
+}
 
-		// Skip if destination is 0.0.0.0 (unconnected socket)
-		if ip.IsUnspecified() {
-			continue
-		}
+// add returns the entry for traffic with an address, creating it on first
+// use. Containers are tallied by name and other addresses by zone.
+func (t peerTally) add(ip string, zone string, incoming bool, peers *networkPeers) *PeerTraffic {
+	key := peerKey{container: peers.names[ip], incoming: incoming}
+	if key.container == "" {
+		key.zone = zone
+	}
+	p, ok := t[key]
+	if !ok {
+		p = &PeerTraffic{Container: key.container, Zone: key.zone, Incoming: incoming}
+		t[key] = p
+	}
+	return p
+}
 
-		// Classify the destination
-		ipStr := ip.String()
-		if proxyIPs[ipStr] {
-			counts[2]++ // Internet via proxy
-		} else if containerIPs[ipStr] {
-			counts[0]++ // Inter-container
-		} else if isPrivateIP(ip) {
-			counts[1]++ // Internal/private
-		} else {
-			counts[2]++ // Internet
-		}
+// list returns the tallied traffic ordered by container, zone and direction
+func (t peerTally) list() []PeerTraffic {
+	var list []PeerTraffic
+	for _, p := range t {
+		list = append(list, *p)
 	}
+	slices.SortFunc(list, comparePeerTraffic)
+	return list
+}
 
-	return counts, nil
+// comparePeerTraffic orders peer traffic by container, zone and direction
+func comparePeerTraffic(a, b PeerTraffic) int {
+	if c := strings.Compare(a.Container, b.Container); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.Zone, b.Zone); c != 0 {
+		return c
+	}
+	switch {
+	case a.Incoming == b.Incoming:
+		return 0
+	case b.Incoming:
+		return -1
+	}
+	return 1
 }
 
 // NetworkStats contains both connection counts and byte counts
 type NetworkStats struct {
-	// Connection counts (from /proc/net/tcp)
-	ConnInterContainer int
-	ConnInternal       int
-	ConnInternet       int
-
-	// Byte counts (from conntrack, if available)
-	BytesInterContainer uint64
-	BytesInternal       uint64
-	BytesInternet       uint64
-	BytesSource         string // "conntrack" or "estimated"
+	// Connection counts (from /proc/net/tcp and udp) and byte counts (from
+	// conntrack, if available) by zone
+	Zones       map[string]ZoneTraffic
+	BytesSource string // "conntrack" or "estimated"
+
+	// Socket inventory
+	Listening      []string       // e.g. "8080/tcp", "127.0.0.1:9090/tcp"
+	TCPStates      map[string]int // Non-listening TCP sockets by state
+	UnixSockets    int
+	UnixListening  int
+	EphemeralPorts int // Outgoing TCP sockets to the busiest remote ip:port
+	EphemeralRange int // Size of the ephemeral port range
+
+	// Conntrack counters by flow, for the next sample's deltas
+	Flows map[string]conntrackFlow
+
+	// Busiest outgoing destinations
+	Destinations []DestinationSample
+
+	// Traffic by peer container or zone and direction
+	Peers []PeerTraffic
+
+	// How containers on the host were classified as proxies
+	Proxies []ProxyClassification
+
+	// The network namespace, if shared with the host or other containers
+	Namespace *NetworkNamespace
+
+	// Bytes received from other containers and sent out via proxies, by
+	// container name (conntrack only)
+	Callers  map[string]uint64
+	ViaProxy map[string]uint64
 }
 
-// getNetworkBreakdown collects connection info and returns classified counts
-func (d *DockerClient) getNetworkBreakdown(ctx context.Context, containerID string) (interContainer, internal, internet int) {
-	stats := d.getNetworkStats(ctx, containerID)
-	return stats.ConnInterContainer, stats.ConnInternal, stats.ConnInternet
+// proxyLinks collects a container's traffic with other containers for proxy
+// egress attribution, in bytes by container name
+type proxyLinks struct {
+	callers  map[string]uint64 // Received on connections they opened
+	viaProxy map[string]uint64 // Sent to proxies and counted as internet
+}
+
+// getNetworkBreakdown collects connection info and returns connection
+// counts by built-in zone
+func getNetworkBreakdown(ctx context.Context, rt Runtime, containerID string) map[string]int {
+	counts := make(map[string]int)
+	for zone, traffic := range getNetworkStats(ctx, rt, containerID, nil, nil, nil, nil).Zones {
+		counts[zone] = traffic.Conns
+	}
+	return counts
 }
 
-// getNetworkStats collects both connection counts and byte counts
-func (d *DockerClient) getNetworkStats(ctx context.Context, containerID string) NetworkStats {
+// getNetworkStats collects both connection counts and byte counts by zone
+// (nil zones uses the built-in ones, nil proxies the built-in proxy
+// detection), and the busiest destinations named by namer (nil leaves them
+// out). Bytes are counted since the previous sample, whose conntrack counters
+// are in prevFlows.
+func getNetworkStats(ctx context.Context, rt Runtime, containerID string, prevFlows map[string]conntrackFlow, zones *NetworkZones, proxies *ProxyDetector, namer *DestinationNamer) NetworkStats {
 	var stats NetworkStats
 
-	// Get container IPs on same networks
-	containerIPs, proxyIPs, err := d.getContainerIPs(ctx, containerID)
+	// Get container IPs on same networks, and the IPs of this container's
+	// network namespace for conntrack filtering
+	peers, err := getNetworkPeers(ctx, rt, containerID, proxies)
 	if err != nil {
 		return stats
 	}
-
-	// Get this container's own IPs for conntrack filtering
-	selfIPs, err := d.getContainerSelfIPs(ctx, containerID)
-	if err != nil {
-		selfIPs = make(map[string]bool)
+	stats.Proxies = peers.classified
+	stats.Namespace = peers.namespace
+
+	// The namespace's own addresses, which the runtime doesn't list for
+	// host networking or namespaces joined from another container
+	files := openContainerNetFiles(ctx, rt, containerID)
+	for _, addr := range namespaceAddrs(ctx, files) {
+		if ip := net.ParseIP(addr); ip != nil && !ip.IsLoopback() {
+			peers.self[addr] = true
+		}
 	}
-
-	// Try conntrack first for byte counts
-	byteStats, conntrackErr := d.readConntrackBytes(ctx, containerID, containerIPs, proxyIPs, selfIPs)
-	if conntrackErr == nil && (byteStats[0]+byteStats[1]+byteStats[2]) > 0 {
-		stats.BytesInterContainer = byteStats[0]
-		stats.BytesInternal = byteStats[1]
-		stats.BytesInternet = byteStats[2]
+	var tally destinationTally
+	if namer != nil {
+		tally = make(destinationTally)
+	}
+	edges := make(peerTally)
+
+	stats.Zones = make(map[string]ZoneTraffic)
+
+	// Try conntrack first for byte counts. A read without flows still counts:
+	// its empty counters are the baseline for the next read, so a burst
+	// starting after it isn't mistaken for traffic that predates monitoring.
+	links := proxyLinks{callers: make(map[string]uint64), viaProxy: make(map[string]uint64)}
+	zoneBytes, flows, conntrackErr := readConntrackFlows(ctx, files, peers, zones, prevFlows, tally, edges, links)
+	if conntrackErr == nil {
+		for zone, traffic := range zoneBytes {
+			stats.Zones[zone] = traffic
+		}
+		stats.Flows = flows
 		stats.BytesSource = "conntrack"
+		stats.Callers = nonEmpty(links.callers)
+		stats.ViaProxy = nonEmpty(links.viaProxy)
 	}
 
 	// Always get connection counts (faster, always available)
-	stats.ConnInterContainer, stats.ConnInternal, stats.ConnInternet, _ = d.classifyConnections(ctx, containerID, containerIPs, proxyIPs)
+	readSockets(ctx, files, peers, zones, tally, edges, &stats)
+	stats.Peers = edges.list()
 
 	// If conntrack failed, estimate bytes from connection ratios
-	if stats.BytesSource == "" && (stats.ConnInterContainer+stats.ConnInternal+stats.ConnInternet) > 0 {
+	if stats.BytesSource == "" && len(stats.Zones) > 0 {
 		stats.BytesSource = "estimated"
 		// Byte estimation will be done in the caller using total network bytes
 	}
 
+	if namer != nil {
+		stats.Destinations = namer.nameAll(topDestinations(tally, maxSampleDestinations), peers.names)
+	}
+
 	return stats
 }
 
-// getContainerSelfIPs returns all IPs assigned to a container
-func (d *DockerClient) getContainerSelfIPs(ctx context.Context, containerID string) (map[string]bool, error) {
-	selfIPs := make(map[string]bool)
+// conntrackFlow holds the byte counters of a conntrack entry
+type conntrackFlow struct {
+	Orig  uint64 // Sent by the side that opened the connection
+	Reply uint64 // Sent back to it
+}
 
-	info, err := d.cli.ContainerInspect(ctx, containerID)
-	if err != nil {
-		return selfIPs, err
+// conntrackEntry is a parsed /proc/net/nf_conntrack line
+type conntrackEntry struct {
+	key      string // Protocol, original tuple and conntrack zone
+	origSrc  string
+	origDst  string
+	replySrc string // The original destination after DNAT
+	dport    uint64
+	counters conntrackFlow
+}
+
+// parseConntrackEntry parses a conntrack line like:
+// ipv4 2 tcp 6 431999 ESTABLISHED src=172.18.0.5 dst=172.18.0.3 sport=45678 dport=5432 packets=100 bytes=12345 src=172.18.0.3 dst=172.18.0.5 sport=5432 dport=45678 packets=90 bytes=67890 [ASSURED] mark=0 use=1
+// The first tuple is the original direction, the second the reply. Entries
+// without byte counters (nf_conntrack_acct off) are rejected.
+func parseConntrackEntry(line string) (conntrackEntry, bool) {
+	var entry conntrackEntry
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return entry, false
 	}
 
-	if info.NetworkSettings != nil {
-		for _, network := range info.NetworkSettings.Networks {
-			if network.IPAddress != "" {
-				selfIPs[network.IPAddress] = true
+	var sport, dport, zone string
+	seen := make(map[string]int) // Occurrences per key: 1 original, 2 reply
+	hasBytes := false
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		seen[key]++
+		reply := seen[key] == 2
+		switch key {
+		case "src":
+			if reply {
+				entry.replySrc = value
+			} else {
+				entry.origSrc = value
 			}
-			if network.GlobalIPv6Address != "" {
-				selfIPs[network.GlobalIPv6Address] = true
+		case "dst":
+			if !reply {
+				entry.origDst = value
 			}
+		case "sport":
+			if !reply {
+				sport = value
+			}
+		case "dport":
+			if !reply {
+				dport = value
+			}
+		case "bytes":
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return entry, false
+			}
+			if reply {
+				entry.counters.Reply = n
+			} else {
+				entry.counters.Orig = n
+				hasBytes = true
+			}
+		case "zone":
+			zone = value
 		}
 	}
-
-	return selfIPs, nil
-}
-
-// readConntrackBytes reads /proc/net/nf_conntrack and sums bytes by destination class
-func (d *DockerClient) readConntrackBytes(ctx context.Context, containerID string, containerIPs, proxyIPs, selfIPs map[string]bool) ([3]uint64, error) {
-	var bytes [3]uint64 // [interContainer, internal, internet]
-
-	// Try reading conntrack from container
-	// Note: This requires the container to have access to conntrack (CAP_NET_ADMIN or host netns)
-	execConfig := types.ExecConfig{
-		Cmd:          []string{"cat", "/proc/net/nf_conntrack"},
-		AttachStdout: true,
-		AttachStderr: true,
+	if entry.origSrc == "" || entry.origDst == "" || !hasBytes {
+		return entry, false
 	}
 
-	execID, err := d.cli.ContainerExecCreate(ctx, containerID, execConfig)
-	if err != nil {
-		return bytes, err
-	}
+	entry.dport, _ = strconv.ParseUint(dport, 10, 16)
+	entry.key = strings.Join([]string{fields[2], entry.origSrc, sport, entry.origDst, dport, zone}, " ")
+	return entry, true
+}
 
-	resp, err := d.cli.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+// readConntrackFlows reads /proc/net/nf_conntrack and sums the bytes sent and
+// received by zone since the previous read, whose counters are in prev (nil
+// for the first read, which only records them). Flows first seen now count
+// in full, and counters that went down belong to a new flow reusing the
+// tuple. Flows that ended before this read keep the bytes they had at the
+// last one; closed TCP flows linger in conntrack (TIME_WAIT), so usually
+// little is lost. The bytes go into edges by peer, and those exchanged with
+// proxies and callers into links for egress attribution. It returns the
+// counters for the next read.
+func readConntrackFlows(ctx context.Context, files *containerNetFiles, peers *networkPeers, zones *NetworkZones, prev map[string]conntrackFlow, tally destinationTally, edges peerTally, links proxyLinks) (map[string]ZoneTraffic, map[string]conntrackFlow, error) {
+	traffic := make(map[string]ZoneTraffic)
+	flows := make(map[string]conntrackFlow)
+
+	// Note: Reading conntrack needs root on the host, or CAP_NET_ADMIN in the
+	// container for the exec fallback
+	output, err := files.read(ctx, "nf_conntrack")
 	if err != nil {
-		return bytes, err
+		return traffic, nil, err
 	}
-	defer resp.Close()
 
-	scanner := bufio.NewScanner(resp.Reader)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
 
 	for scanner.Scan() {
-		line := scanner.Text()
-
-		// Parse conntrack line format:
-		// ipv4 2 tcp 6 431999 ESTABLISHED src=172.18.0.5 dst=172.18.0.3 sport=45678 dport=5432 packets=100 bytes=12345 ...
-		if !strings.Contains(line, "src=") || !strings.Contains(line, "bytes=") {
+		entry, ok := parseConntrackEntry(scanner.Text())
+		if !ok {
 			continue
 		}
 
-		// Extract source IP (to filter to this container's connections)
-		srcIP := extractConntrackField(line, "src=")
-		if srcIP == "" || !selfIPs[srcIP] {
-			continue // Not from this container
+		// Outgoing flows start at the container. Incoming ones end at it,
+		// possibly through DNAT from a published port, so the reply side is
+		// what shows the container's IP.
+		var peer string
+		var sent, received uint64
+		outgoing := peers.self[entry.origSrc]
+		switch {
+		case outgoing:
+			peer = entry.origDst
+		case peers.self[entry.replySrc]:
+			peer = entry.origSrc
+		default:
+			continue // Not this container's
 		}
-
-		// Extract destination IP
-		dstIP := extractConntrackField(line, "dst=")
-		if dstIP == "" {
+		ip := net.ParseIP(peer)
+		if ip == nil || ip.IsLoopback() || peers.self[peer] {
 			continue
 		}
 
-		// Extract bytes (first occurrence is outbound bytes)
-		bytesStr := extractConntrackField(line, "bytes=")
-		if bytesStr == "" {
-			continue
+		flows[entry.key] = entry.counters
+		if prev == nil {
+			continue // First read: the counters so far predate monitoring
 		}
-		byteCount, err := strconv.ParseUint(bytesStr, 10, 64)
-		if err != nil {
-			continue
+		delta := entry.counters
+		if last, ok := prev[entry.key]; ok && last.Orig <= delta.Orig && last.Reply <= delta.Reply {
+			delta.Orig -= last.Orig
+			delta.Reply -= last.Reply
 		}
-
-		// Classify destination
-		ip := net.ParseIP(dstIP)
-		if ip == nil {
+		if outgoing {
+			sent, received = delta.Orig, delta.Reply
+		} else {
+			sent, received = delta.Reply, delta.Orig
+		}
+		if sent == 0 && received == 0 {
 			continue
 		}
 
-		if proxyIPs[dstIP] {
-			bytes[2] += byteCount // Internet via proxy
-		} else if containerIPs[dstIP] {
-			bytes[0] += byteCount // Inter-container
-		} else if isPrivateIP(ip) {
-			bytes[1] += byteCount // Internal/private
-		} else {
-			bytes[2] += byteCount // Internet
+		parts := zones.split(ip, peers, outgoing)
+		sentParts, receivedParts := splitBytes(sent, parts), splitBytes(received, parts)
+		for i, part := range parts {
+			t := traffic[part.zone]
+			t.TxBytes += sentParts[i]
+			t.RxBytes += receivedParts[i]
+			traffic[part.zone] = t
 		}
-	}
-
-	return bytes, nil
-}
-
-// extractConntrackField extracts a field value from conntrack line (e.g., "src=" -> IP)
-func extractConntrackField(line, prefix string) string {
-	idx := strings.Index(line, prefix)
-	if idx == -1 {
-		return ""
-	}
 
-	start := idx + len(prefix)
-	end := start
-	for end < len(line) && line[end] != ' ' && line[end] != '\t' {
-		end++
+		if tally != nil && outgoing {
+			tally.add(ip, entry.dport, parts[0].zone).Bytes += sent + received
+		}
+		edges.add(peer, parts[0].zone, !outgoing, peers).Bytes += sent + received
+
+		// Requests from other containers, and what went out via proxies
+		name, container := peers.names[peer]
+		_, proxy := peers.proxies[peer]
+		switch {
+		case container && !outgoing:
+			links.callers[name] += received
+		case container && proxy:
+			for i, part := range parts {
+				if part.zone == ZoneInternet {
+					links.viaProxy[name] += sentParts[i]
+				}
+			}
+		}
 	}
 
-	return line[start:end]
+	return traffic, flows, nil
 }
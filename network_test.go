@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseConntrackEntry(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want conntrackEntry
+		ok   bool
+	}{
+		{
+			name: "tcp",
+			line: "ipv4 2 tcp 6 431999 ESTABLISHED src=172.18.0.5 dst=172.18.0.3 sport=45678 dport=5432 packets=100 bytes=12345 src=172.18.0.3 dst=172.18.0.5 sport=5432 dport=45678 packets=90 bytes=67890 [ASSURED] mark=0 use=1",
+			want: conntrackEntry{
+				key:      "tcp 172.18.0.5 45678 172.18.0.3 5432 ",
+				origSrc:  "172.18.0.5",
+				origDst:  "172.18.0.3",
+				replySrc: "172.18.0.3",
+				dport:    5432,
+				counters: conntrackFlow{Orig: 12345, Reply: 67890},
+			},
+			ok: true,
+		},
+		{
+			name: "dnat from published port",
+			line: "ipv4 2 tcp 6 86399 ESTABLISHED src=192.168.1.20 dst=192.168.1.10 sport=51000 dport=8080 packets=5 bytes=400 src=172.18.0.5 dst=192.168.1.20 sport=80 dport=51000 packets=4 bytes=3000 [ASSURED] mark=0 use=1",
+			want: conntrackEntry{
+				key:      "tcp 192.168.1.20 51000 192.168.1.10 8080 ",
+				origSrc:  "192.168.1.20",
+				origDst:  "192.168.1.10",
+				replySrc: "172.18.0.5",
+				dport:    8080,
+				counters: conntrackFlow{Orig: 400, Reply: 3000},
+			},
+			ok: true,
+		},
+		{
+			name: "udp in conntrack zone",
+			line: "ipv4 2 udp 17 29 src=172.18.0.5 dst=1.1.1.1 sport=40000 dport=53 packets=1 bytes=60 src=1.1.1.1 dst=172.18.0.5 sport=53 dport=40000 packets=1 bytes=120 mark=0 zone=3 use=1",
+			want: conntrackEntry{
+				key:      "udp 172.18.0.5 40000 1.1.1.1 53 3",
+				origSrc:  "172.18.0.5",
+				origDst:  "1.1.1.1",
+				replySrc: "1.1.1.1",
+				dport:    53,
+				counters: conntrackFlow{Orig: 60, Reply: 120},
+			},
+			ok: true,
+		},
+		{
+			name: "ipv6",
+			line: "ipv6 10 tcp 6 300 ESTABLISHED src=fd00::5 dst=2606:4700::1111 sport=41000 dport=443 packets=3 bytes=240 src=2606:4700::1111 dst=fd00::5 sport=443 dport=41000 packets=2 bytes=1800 [ASSURED] mark=0 use=1",
+			want: conntrackEntry{
+				key:      "tcp fd00::5 41000 2606:4700::1111 443 ",
+				origSrc:  "fd00::5",
+				origDst:  "2606:4700::1111",
+				replySrc: "2606:4700::1111",
+				dport:    443,
+				counters: conntrackFlow{Orig: 240, Reply: 1800},
+			},
+			ok: true,
+		},
+		{
+			name: "accounting off",
+			line: "ipv4 2 tcp 6 431999 ESTABLISHED src=172.18.0.5 dst=172.18.0.3 sport=45678 dport=5432 src=172.18.0.3 dst=172.18.0.5 sport=5432 dport=45678 [ASSURED] mark=0 use=1",
+		},
+		{
+			name: "bad byte count",
+			line: "ipv4 2 tcp 6 431999 ESTABLISHED src=172.18.0.5 dst=172.18.0.3 sport=45678 dport=5432 packets=1 bytes=x src=172.18.0.3 dst=172.18.0.5 sport=5432 dport=45678 packets=1 bytes=1",
+		},
+		{name: "short", line: "ipv4 2"},
+		{name: "empty", line: ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseConntrackEntry(tt.line)
+		if ok != tt.ok {
+			t.Errorf("%s: parseConntrackEntry ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && got != tt.want {
+			t.Errorf("%s: parseConntrackEntry = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadConntrackFlows(t *testing.T) {
+	const pid = "4242"
+	proc := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(proc, pid, "net"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	saved := hostPaths
+	hostPaths = HostPaths{Proc: proc}
+	t.Cleanup(func() { hostPaths = saved })
+
+	files := &containerNetFiles{pid: 4242}
+	peers := &networkPeers{
+		containers: map[string]bool{"172.18.0.3": true, "172.18.0.7": true},
+		self:       map[string]bool{"172.18.0.5": true},
+		names:      map[string]string{"172.18.0.3": "db", "172.18.0.7": "web"},
+	}
+	read := func(conntrack string, prev map[string]conntrackFlow) (map[string]ZoneTraffic, map[string]conntrackFlow, destinationTally, peerTally, proxyLinks) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(proc, pid, "net", "nf_conntrack"), []byte(conntrack), 0644); err != nil {
+			t.Fatal(err)
+		}
+		tally := make(destinationTally)
+		edges := make(peerTally)
+		links := proxyLinks{callers: make(map[string]uint64), viaProxy: make(map[string]uint64)}
+		traffic, flows, err := readConntrackFlows(context.Background(), files, peers, nil, prev, tally, edges, links)
+		if err != nil {
+			t.Fatalf("readConntrackFlows: %v", err)
+		}
+		return traffic, flows, tally, edges, links
+	}
+
+	// The first read only records the counters of this container's flows
+	traffic, flows, _, edges, _ := read(`ipv4 2 tcp 6 431999 ESTABLISHED src=172.18.0.5 dst=172.18.0.3 sport=45678 dport=5432 packets=10 bytes=1000 src=172.18.0.3 dst=172.18.0.5 sport=5432 dport=45678 packets=10 bytes=5000 [ASSURED] mark=0 use=1
+ipv4 2 tcp 6 431999 ESTABLISHED src=172.18.0.5 dst=1.1.1.1 sport=45000 dport=443 packets=2 bytes=100 src=1.1.1.1 dst=172.18.0.5 sport=443 dport=45000 packets=2 bytes=200 [ASSURED] mark=0 use=1
+ipv4 2 tcp 6 431999 ESTABLISHED src=172.18.0.9 dst=172.18.0.3 sport=46000 dport=5432 packets=2 bytes=700 src=172.18.0.3 dst=172.18.0.9 sport=5432 dport=46000 packets=2 bytes=700 [ASSURED] mark=0 use=1
+`, nil)
+	if len(traffic) != 0 {
+		t.Errorf("first read traffic = %v, want none", traffic)
+	}
+	if len(edges) != 0 {
+		t.Errorf("first read edges = %v, want none", edges.list())
+	}
+	if len(flows) != 2 {
+		t.Errorf("first read recorded %d flows, want 2: %v", len(flows), flows)
+	}
+
+	// The second counts what changed since: the database flow grew, the
+	// internet tuple was reused by a new flow with lower counters, and a
+	// client opened a connection
+	traffic, flows, tally, edges, links := read(`ipv4 2 tcp 6 431999 ESTABLISHED src=172.18.0.5 dst=172.18.0.3 sport=45678 dport=5432 packets=20 bytes=1500 src=172.18.0.3 dst=172.18.0.5 sport=5432 dport=45678 packets=20 bytes=8000 [ASSURED] mark=0 use=1
+ipv4 2 tcp 6 431999 ESTABLISHED src=172.18.0.5 dst=1.1.1.1 sport=45000 dport=443 packets=1 bytes=50 src=1.1.1.1 dst=172.18.0.5 sport=443 dport=45000 packets=1 bytes=80 [ASSURED] mark=0 use=1
+ipv4 2 tcp 6 431999 ESTABLISHED src=172.18.0.7 dst=172.18.0.5 sport=47000 dport=8080 packets=3 bytes=300 src=172.18.0.5 dst=172.18.0.7 sport=8080 dport=47000 packets=3 bytes=900 [ASSURED] mark=0 use=1
+ipv4 2 tcp 6 431999 ESTABLISHED src=172.18.0.9 dst=172.18.0.3 sport=46000 dport=5432 packets=4 bytes=900 src=172.18.0.3 dst=172.18.0.9 sport=5432 dport=46000 packets=4 bytes=900 [ASSURED] mark=0 use=1
+`, flows)
+
+	wantTraffic := map[string]ZoneTraffic{
+		ZoneInterContainer: {TxBytes: 500 + 900, RxBytes: 3000 + 300},
+		ZoneInternet:       {TxBytes: 50, RxBytes: 80},
+	}
+	if !maps.Equal(traffic, wantTraffic) {
+		t.Errorf("traffic = %v, want %v", traffic, wantTraffic)
+	}
+	if len(flows) != 3 {
+		t.Errorf("second read recorded %d flows, want 3: %v", len(flows), flows)
+	}
+	if want := map[string]uint64{"web": 300}; !maps.Equal(links.callers, want) {
+		t.Errorf("callers = %v, want %v", links.callers, want)
+	}
+	wantEdges := []PeerTraffic{
+		{Zone: ZoneInternet, Bytes: 130},
+		{Container: "db", Bytes: 3500},
+		{Container: "web", Incoming: true, Bytes: 1200},
+	}
+	if got := edges.list(); !slices.Equal(got, wantEdges) {
+		t.Errorf("edges = %+v, want %+v", got, wantEdges)
+	}
+	wantTally := map[string]uint64{"172.18.0.3:5432": 3500, "1.1.1.1:443": 130}
+	if len(tally) != len(wantTally) {
+		t.Errorf("tally = %v, want %v", tally, wantTally)
+	}
+	for addr, want := range wantTally {
+		if d := tally[addr]; d == nil || d.Bytes != want {
+			t.Errorf("tally[%q] = %+v, want %d bytes", addr, d, want)
+		}
+	}
+}
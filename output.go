@@ -20,6 +20,7 @@ import (
 //   data/<config>/ - monitoring data files
 //   pids/          - PID files for running daemons
 //   logs/          - log files
+//   sockets/       - control sockets for running daemons
 
 // EnsureDirs creates the required directory structure
 func EnsureDirs() error {
@@ -28,6 +29,7 @@ func EnsureDirs() error {
 		filepath.Join(mdokDir, "data"),
 		filepath.Join(mdokDir, "pids"),
 		filepath.Join(mdokDir, "logs"),
+		filepath.Join(mdokDir, "sockets"),
 	}
 	for _, dir := range dirs {
 		if err := os.MkdirAll(dir, 0755); err != nil {
@@ -52,6 +54,11 @@ func GetPidFile(configName string) string {
 	return filepath.Join(mdokDir, "pids", configName+".pid")
 }
 
+// GetSocketFile returns the control socket path for a config
+func GetSocketFile(configName string) string {
+	return filepath.Join(mdokDir, "sockets", configName+".sock")
+}
+
 // GetLogFile returns the log file path for a config
 func GetLogFile(configName string) string {
 	return filepath.Join(mdokDir, "logs", configName+".log")
@@ -247,6 +254,11 @@ func RemovePidFile(configName string) error {
 
 // IsRunning checks if a daemon is running for the given config
 func IsRunning(configName string) bool {
+	if ControlAvailable(configName) {
+		return true
+	}
+
+	// Daemons without a control socket (or still starting up)
 	pid, err := ReadPidFile(configName)
 	if err != nil {
 		return false
@@ -355,7 +367,7 @@ func filterToCurrentSession(data *ContainerData) *ContainerData {
 	sessionStartIdx := 0
 	for i := len(data.Samples) - 1; i > 0; i-- {
 		gap := data.Samples[i].Timestamp.Sub(data.Samples[i-1].Timestamp)
-		if gap > maxGap {
+		if gap > maxGap && !resumedBetween(data.Events, data.Samples[i-1].Timestamp, data.Samples[i].Timestamp) {
 			// Found a gap, so the current session starts at index i
 			sessionStartIdx = i
 			break
@@ -365,15 +377,17 @@ func filterToCurrentSession(data *ContainerData) *ContainerData {
 	// If we found a session boundary, filter the samples
 	if sessionStartIdx > 0 {
 		filtered := &ContainerData{
-			ContainerID:   data.ContainerID,
-			ContainerName: data.ContainerName,
-			ImageName:     data.ImageName,
-			Host:          data.Host,
-			Limits:        data.Limits,
-			StartTime:     data.Samples[sessionStartIdx].Timestamp,
-			EndTime:       data.EndTime,
-			Interval:      data.Interval,
-			Samples:       data.Samples[sessionStartIdx:],
+			ContainerID:    data.ContainerID,
+			ContainerName:  data.ContainerName,
+			ImageName:      data.ImageName,
+			ComposeService: data.ComposeService,
+			Host:           data.Host,
+			Limits:         data.Limits,
+			StartTime:      data.Samples[sessionStartIdx].Timestamp,
+			EndTime:        data.EndTime,
+			Interval:       data.Interval,
+			Samples:        data.Samples[sessionStartIdx:],
+			Events:         eventsSince(data.Events, data.Samples[sessionStartIdx].Timestamp),
 		}
 
 		// Recalculate summary for the current session only
@@ -390,7 +404,7 @@ func filterToCurrentSession(data *ContainerData) *ContainerData {
 
 		// Recalculate network cost for current session
 		if filtered.Summary != nil {
-			filtered.NetworkCost = CalculateNetworkCost(filtered.Summary.NetTxTotal)
+			filtered.NetworkCost = CalculateNetworkCost(filtered)
 		}
 
 		return filtered
@@ -532,6 +546,28 @@ func appendUnique(slice []string, item string) []string {
 	return append(slice, item)
 }
 
+// eventsSince returns the session events at or after t
+func eventsSince(events []SessionEvent, t time.Time) []SessionEvent {
+	var result []SessionEvent
+	for _, e := range events {
+		if !e.Time.Before(t) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// resumedBetween reports whether a restarted daemon recorded that it resumed
+// the session between two samples, so the gap doesn't start a new session
+func resumedBetween(events []SessionEvent, from, to time.Time) bool {
+	for _, e := range events {
+		if e.Type == SessionEventResumed && e.Time.After(from) && !e.Time.After(to) {
+			return true
+		}
+	}
+	return false
+}
+
 // filterToSession filters container data to only include samples from a specific session
 func filterToSession(data *ContainerData, sessionID string) *ContainerData {
 	if data == nil || len(data.Samples) == 0 {
@@ -545,14 +581,15 @@ func filterToSession(data *ContainerData, sessionID string) *ContainerData {
 		}
 		// No match, return empty
 		return &ContainerData{
-			ContainerID:   data.ContainerID,
-			ContainerName: data.ContainerName,
-			ImageName:     data.ImageName,
-			Host:          data.Host,
-			Limits:        data.Limits,
-			SessionID:     data.SessionID,
-			Interval:      data.Interval,
-			Samples:       []Sample{},
+			ContainerID:    data.ContainerID,
+			ContainerName:  data.ContainerName,
+			ImageName:      data.ImageName,
+			ComposeService: data.ComposeService,
+			Host:           data.Host,
+			Limits:         data.Limits,
+			SessionID:      data.SessionID,
+			Interval:       data.Interval,
+			Samples:        []Sample{},
 		}
 	}
 
@@ -569,16 +606,17 @@ func filterToSession(data *ContainerData, sessionID string) *ContainerData {
 			}
 
 			result := &ContainerData{
-				ContainerID:   data.ContainerID,
-				ContainerName: data.ContainerName,
-				ImageName:     data.ImageName,
-				Host:          data.Host,
-				Limits:        data.Limits,
-				SessionID:     sessionID,
-				StartTime:     sess.StartTime,
-				EndTime:       sess.EndTime,
-				Interval:      data.Interval,
-				Samples:       filtered,
+				ContainerID:    data.ContainerID,
+				ContainerName:  data.ContainerName,
+				ImageName:      data.ImageName,
+				ComposeService: data.ComposeService,
+				Host:           data.Host,
+				Limits:         data.Limits,
+				SessionID:      sessionID,
+				StartTime:      sess.StartTime,
+				EndTime:        sess.EndTime,
+				Interval:       data.Interval,
+				Samples:        filtered,
 			}
 
 			// Recalculate summary for the session
@@ -591,7 +629,7 @@ func filterToSession(data *ContainerData, sessionID string) *ContainerData {
 
 				// Recalculate network cost
 				if result.Summary != nil {
-					result.NetworkCost = CalculateNetworkCost(result.Summary.NetTxTotal)
+					result.NetworkCost = CalculateNetworkCost(result)
 				}
 			}
 
@@ -601,12 +639,47 @@ func filterToSession(data *ContainerData, sessionID string) *ContainerData {
 
 	// Session not found
 	return &ContainerData{
-		ContainerID:   data.ContainerID,
-		ContainerName: data.ContainerName,
-		ImageName:     data.ImageName,
-		Host:          data.Host,
-		Limits:        data.Limits,
-		Interval:      data.Interval,
-		Samples:       []Sample{},
+		ContainerID:    data.ContainerID,
+		ContainerName:  data.ContainerName,
+		ImageName:      data.ImageName,
+		ComposeService: data.ComposeService,
+		Host:           data.Host,
+		Limits:         data.Limits,
+		Interval:       data.Interval,
+		Samples:        []Sample{},
+	}
+}
+
+// LoadSessionContainerData loads all container data for a config, filtered to a
+// specific session (or the most recent one when sessionID is empty), with
+// summaries calculated for data that doesn't have one yet
+func LoadSessionContainerData(configName string, sessionID string) ([]*ContainerData, error) {
+	allData, err := LoadAllContainerData(configName)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*ContainerData
+	for _, data := range allData {
+		if sessionID != "" {
+			data = filterToSession(data, sessionID)
+		} else {
+			data = filterToCurrentSession(data)
+		}
+
+		if data.Summary == nil && len(data.Samples) > 0 {
+			data.Summary = CalculateSummary(data.Samples)
+			data.Summary.Warnings = DetectWarnings(data)
+			if data.EndTime.IsZero() {
+				data.EndTime = time.Now()
+			}
+			if !data.StartTime.IsZero() {
+				data.Summary.Duration = data.EndTime.Sub(data.StartTime).Round(time.Second).String()
+			}
+		}
+
+		result = append(result, data)
 	}
+
+	return result, nil
 }
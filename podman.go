@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+// envPodmanHost is how Podman's own tools are pointed at a socket
+const envPodmanHost = "CONTAINER_HOST"
+
+// rootfulPodmanSocket is where `podman system service` listens when run as root
+const rootfulPodmanSocket = "/run/podman/podman.sock"
+
+// NewPodmanClient connects to Podman's Docker-compatible API socket: the one
+// in $CONTAINER_HOST, the rootless user socket, or the rootful one
+func NewPodmanClient() (*DockerClient, error) {
+	host, err := podmanHost()
+	if err != nil {
+		return nil, err
+	}
+
+	cli, err := client.NewClientWithOpts(client.WithHost(host), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Podman client: %w", err)
+	}
+	return &DockerClient{cli: cli, podman: true}, nil
+}
+
+// podmanHost finds the Podman API socket
+func podmanHost() (string, error) {
+	if host := os.Getenv(envPodmanHost); host != "" {
+		if !strings.HasPrefix(host, "unix://") && !strings.HasPrefix(host, "tcp://") {
+			return "", fmt.Errorf("unsupported %s %q (expected unix:// or tcp://; use an endpoint for remote hosts)", envPodmanHost, host)
+		}
+		return host, nil
+	}
+
+	var candidates []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, filepath.Join("/run/user", fmt.Sprint(os.Getuid()), "podman", "podman.sock"), rootfulPodmanSocket)
+
+	for _, socket := range candidates {
+		if _, err := os.Stat(socket); err == nil {
+			return "unix://" + socket, nil
+		}
+	}
+	return "", fmt.Errorf("no Podman socket found (tried %s); start it with `systemctl --user enable --now podman.socket`",
+		strings.Join(candidates, ", "))
+}
+
+// isPodmanInfraContainer reports whether an image is the pause image Podman
+// runs as every pod's infra container
+func isPodmanInfraContainer(image string) bool {
+	return strings.Contains(image, "podman-pause") || strings.Contains(image, "/pause:")
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// hoursPerMonth is the number of hours AWS uses for monthly pricing
+const hoursPerMonth = 730.0
+
+// FargatePricing contains per-resource Fargate rates for one architecture
+// (us-east-1, Linux, approximate as of 2024)
+type FargatePricing struct {
+	VCPUHour     float64 // per vCPU-hour
+	GBHour       float64 // per GB-hour
+	SpotVCPUHour float64 // 0 when Fargate Spot isn't available
+	SpotGBHour   float64
+	PerfFactor   float64 // per-vCPU performance relative to the reference vCPU
+}
+
+var fargatePricing = map[string]FargatePricing{
+	"x86": {
+		VCPUHour:     0.04048,
+		GBHour:       0.004445,
+		SpotVCPUHour: 0.01334058,
+		SpotGBHour:   0.00146489,
+		PerfFactor:   1.0,
+	},
+	"arm": {
+		VCPUHour:   0.03238,
+		GBHour:     0.00356,
+		PerfFactor: 1.15,
+		// Fargate Spot is not offered for ARM64 tasks
+	},
+}
+
+// FargateTaskSize is a valid Fargate CPU/memory combination
+type FargateTaskSize struct {
+	VCPU     float64
+	MemoryGB float64
+}
+
+// fargateTaskSizes returns all valid Fargate task CPU/memory combinations
+func fargateTaskSizes() []FargateTaskSize {
+	var sizes []FargateTaskSize
+	add := func(vcpu, minGB, maxGB, stepGB float64) {
+		for mem := minGB; mem <= maxGB+1e-9; mem += stepGB {
+			sizes = append(sizes, FargateTaskSize{VCPU: vcpu, MemoryGB: mem})
+		}
+	}
+
+	sizes = append(sizes,
+		FargateTaskSize{0.25, 0.5},
+		FargateTaskSize{0.25, 1},
+		FargateTaskSize{0.25, 2})
+	add(0.5, 1, 4, 1)
+	add(1, 2, 8, 1)
+	add(2, 4, 16, 1)
+	add(4, 8, 30, 1)
+	add(8, 16, 60, 4)
+	add(16, 32, 120, 8)
+	return sizes
+}
+
+// RecommendFargate maps a summary to the cheapest valid Fargate task size for an architecture
+func RecommendFargate(summary *ContainerSummary, arch string, host HostInfo) *FargateRecommendation {
+	if summary == nil {
+		return nil
+	}
+	pricing, ok := fargatePricing[arch]
+	if !ok {
+		return nil
+	}
+
+	// Same sizing rule as EC2 recommendations: P95 plus 20% headroom
+	requiredCPU := summary.CPUPercent.P95 / 100 * 1.2
+	if host.CPUScore > 0 {
+		requiredCPU = requiredCPU * host.CPUScore / pricing.PerfFactor
+	}
+	requiredMemGB := summary.MemoryUsage.P95 / (1024 * 1024 * 1024) * 1.2
+
+	var best *FargateTaskSize
+	var bestPrice float64
+	sizes := fargateTaskSizes()
+	for i, size := range sizes {
+		if size.VCPU < requiredCPU || size.MemoryGB < requiredMemGB {
+			continue
+		}
+		price := size.VCPU*pricing.VCPUHour + size.MemoryGB*pricing.GBHour
+		if best == nil || price < bestPrice {
+			best = &sizes[i]
+			bestPrice = price
+		}
+	}
+
+	reason := fmt.Sprintf("P95 CPU %.2f vCPU, P95 memory %.2f GB (+20%% headroom)",
+		summary.CPUPercent.P95/100, summary.MemoryUsage.P95/(1024*1024*1024))
+	if best == nil {
+		// Requirements exceed the largest task size
+		best = &sizes[len(sizes)-1]
+		bestPrice = best.VCPU*pricing.VCPUHour + best.MemoryGB*pricing.GBHour
+		reason = "Resource requirements exceed the largest Fargate task size"
+	} else if host.CPUScore > 0 {
+		reason += fmt.Sprintf("; normalized to %.2f vCPU (host score %.2f)", requiredCPU, host.CPUScore)
+	}
+
+	rec := &FargateRecommendation{
+		Architecture: arch,
+		VCPU:         best.VCPU,
+		MemoryGB:     best.MemoryGB,
+		HourlyPrice:  roundPrice(bestPrice),
+		MonthlyPrice: roundPrice(bestPrice * hoursPerMonth),
+		Reason:       reason,
+	}
+
+	if pricing.SpotVCPUHour > 0 {
+		spot := best.VCPU*pricing.SpotVCPUHour + best.MemoryGB*pricing.SpotGBHour
+		rec.SpotHourlyPrice = roundPrice(spot)
+		rec.SpotMonthlyPrice = roundPrice(spot * hoursPerMonth)
+	}
+
+	return rec
+}
+
+// RecommendFargateBothArchitectures returns Fargate task sizes for x86 and ARM
+func RecommendFargateBothArchitectures(summary *ContainerSummary, host HostInfo) []*FargateRecommendation {
+	var recs []*FargateRecommendation
+	for _, arch := range []string{"x86", "arm"} {
+		if rec := RecommendFargate(summary, arch, host); rec != nil {
+			recs = append(recs, rec)
+		}
+	}
+	return recs
+}
+
+// roundPrice rounds a price to 5 decimal places
+func roundPrice(p float64) float64 {
+	return math.Round(p*100000) / 100000
+}
+
+// formatFargateTaskSize formats a task size the way the ECS console shows it
+func formatFargateTaskSize(rec *FargateRecommendation) string {
+	return fmt.Sprintf("%s vCPU / %s GB", formatCores(rec.VCPU), formatCores(rec.MemoryGB))
+}
+
+// awsInstanceFamilyPerf is the relative per-vCPU performance of each instance
+// family compared to the reference vCPU used by the CPU benchmark (m5 = 1.0).
+// Graviton vCPUs are full physical cores, so they score higher than
+// hyper-threaded x86 vCPUs.
+var awsInstanceFamilyPerf = map[string]float64{
+	"t3":  0.95,
+	"m5":  1.0,
+	"c5":  1.1,
+	"r5":  1.0,
+	"t4g": 0.95,
+	"m7g": 1.3,
+	"c7g": 1.35,
+	"r7g": 1.3,
+}
+
+// instanceFamily returns the family part of an instance type (e.g., "m5" for "m5.large")
+func instanceFamily(instanceType string) string {
+	if idx := strings.Index(instanceType, "."); idx > 0 {
+		return instanceType[:idx]
+	}
+	return instanceType
+}
+
+// instanceFamilyPerf returns the relative per-vCPU performance of an instance type
+func instanceFamilyPerf(instanceType string) float64 {
+	if perf, ok := awsInstanceFamilyPerf[instanceFamily(instanceType)]; ok {
+		return perf
+	}
+	return 1.0
+}
+
+// PurchaseOptionRates are effective hourly rates as a fraction of the
+// on-demand price (Linux, no upfront, approximate as of 2024)
+type PurchaseOptionRates struct {
+	Reserved1yr    float64 // Standard reserved instance, 1 year
+	Reserved3yr    float64 // Standard reserved instance, 3 years
+	SavingsPlan1yr float64 // Compute Savings Plan, 1 year
+	SavingsPlan3yr float64 // Compute Savings Plan, 3 years
+	Spot           float64 // Typical spot price
+}
+
+var awsPurchaseOptionRates = map[string]PurchaseOptionRates{
+	"t3":  {Reserved1yr: 0.63, Reserved3yr: 0.43, SavingsPlan1yr: 0.72, SavingsPlan3yr: 0.52, Spot: 0.32},
+	"m5":  {Reserved1yr: 0.62, Reserved3yr: 0.42, SavingsPlan1yr: 0.72, SavingsPlan3yr: 0.50, Spot: 0.38},
+	"c5":  {Reserved1yr: 0.63, Reserved3yr: 0.43, SavingsPlan1yr: 0.72, SavingsPlan3yr: 0.51, Spot: 0.40},
+	"r5":  {Reserved1yr: 0.62, Reserved3yr: 0.42, SavingsPlan1yr: 0.72, SavingsPlan3yr: 0.50, Spot: 0.33},
+	"t4g": {Reserved1yr: 0.63, Reserved3yr: 0.43, SavingsPlan1yr: 0.72, SavingsPlan3yr: 0.52, Spot: 0.32},
+	"m7g": {Reserved1yr: 0.64, Reserved3yr: 0.44, SavingsPlan1yr: 0.73, SavingsPlan3yr: 0.52, Spot: 0.45},
+	"c7g": {Reserved1yr: 0.64, Reserved3yr: 0.44, SavingsPlan1yr: 0.73, SavingsPlan3yr: 0.52, Spot: 0.45},
+	"r7g": {Reserved1yr: 0.64, Reserved3yr: 0.44, SavingsPlan1yr: 0.73, SavingsPlan3yr: 0.52, Spot: 0.42},
+}
+
+// awsBurstableBaseline is the per-vCPU baseline CPU utilization of burstable
+// (t-family) instances, as a fraction of one vCPU
+var awsBurstableBaseline = map[string]float64{
+	"t3.micro":   0.10,
+	"t3.small":   0.20,
+	"t3.medium":  0.20,
+	"t3.large":   0.30,
+	"t3.xlarge":  0.40,
+	"t4g.micro":  0.10,
+	"t4g.small":  0.20,
+	"t4g.medium": 0.20,
+	"t4g.large":  0.30,
+	"t4g.xlarge": 0.40,
+}
+
+// awsBurstableSurplusRate is the unlimited-mode charge per vCPU-hour of surplus credits
+var awsBurstableSurplusRate = map[string]float64{
+	"t3":  0.05,
+	"t4g": 0.04,
+}
+
+// purchaseOptionsFor returns the effective hourly rates for an instance type
+func purchaseOptionsFor(inst InstanceType) *PurchaseOptions {
+	rates, ok := awsPurchaseOptionRates[instanceFamily(inst.Type)]
+	if !ok {
+		return nil
+	}
+	return &PurchaseOptions{
+		OnDemandHourly:       inst.Hourly,
+		Reserved1yrHourly:    roundPrice(inst.Hourly * rates.Reserved1yr),
+		Reserved3yrHourly:    roundPrice(inst.Hourly * rates.Reserved3yr),
+		SavingsPlan1yrHourly: roundPrice(inst.Hourly * rates.SavingsPlan1yr),
+		SavingsPlan3yrHourly: roundPrice(inst.Hourly * rates.SavingsPlan3yr),
+		SpotHourly:           roundPrice(inst.Hourly * rates.Spot),
+	}
+}
+
+// checkBurstableCredits determines whether a t-family instance's CPU credit
+// earn rate covers the observed average CPU. Returns nil for non-burstable types.
+func checkBurstableCredits(inst InstanceType, summary *ContainerSummary, host HostInfo) *BurstableCreditCheck {
+	baseline, ok := awsBurstableBaseline[inst.Type]
+	if !ok || summary == nil {
+		return nil
+	}
+
+	// Average usage in target vCPUs (normalized when the host is calibrated)
+	avgVCPU := summary.CPUPercent.Avg / 100
+	if host.CPUScore > 0 {
+		avgVCPU = avgVCPU * host.CPUScore / instanceFamilyPerf(inst.Type)
+	}
+	baselineVCPU := baseline * float64(inst.VCPU)
+
+	// One CPU credit = one vCPU at 100% for one minute
+	check := &BurstableCreditCheck{
+		BaselinePct:    baselineVCPU * 100,
+		AvgCPUPct:      math.Round(avgVCPU*1000) / 10,
+		CreditsPerHour: math.Round((baselineVCPU-avgVCPU)*60*10) / 10,
+		Sustainable:    avgVCPU <= baselineVCPU,
+	}
+
+	if check.Sustainable {
+		check.Note = fmt.Sprintf("average CPU %.1f%% is within the %.0f%% baseline; credits accrue at %.1f/hour",
+			check.AvgCPUPct, check.BaselinePct, check.CreditsPerHour)
+	} else {
+		surplusVCPUHours := (avgVCPU - baselineVCPU) * hoursPerMonth
+		check.SurplusMonthlyUSD = math.Round(surplusVCPUHours*awsBurstableSurplusRate[instanceFamily(inst.Type)]*100) / 100
+		check.Note = fmt.Sprintf("average CPU %.1f%% exceeds the %.0f%% baseline; credits drain at %.1f/hour (unlimited mode adds ~$%.2f/month)",
+			check.AvgCPUPct, check.BaselinePct, -check.CreditsPerHour, check.SurplusMonthlyUSD)
+	}
+
+	return check
+}
+
+// formatPurchaseOptions returns display lines comparing monthly cost under
+// each purchase option, followed by the burstable credit note if any
+func formatPurchaseOptions(rec *InstanceRecommendation) []string {
+	var lines []string
+	if po := rec.PurchaseOptions; po != nil {
+		monthly := func(hourly float64) float64 { return hourly * hoursPerMonth }
+		lines = append(lines,
+			fmt.Sprintf("Reserved: 1yr ~$%.2f/month, 3yr ~$%.2f/month",
+				monthly(po.Reserved1yrHourly), monthly(po.Reserved3yrHourly)),
+			fmt.Sprintf("Savings Plan: 1yr ~$%.2f/month, 3yr ~$%.2f/month",
+				monthly(po.SavingsPlan1yrHourly), monthly(po.SavingsPlan3yrHourly)),
+			fmt.Sprintf("Spot: ~$%.2f/month (interruptible)", monthly(po.SpotHourly)))
+	}
+	if b := rec.Burstable; b != nil {
+		prefix := "✓ CPU credits"
+		if !b.Sustainable {
+			prefix = "⚠️  CPU credits"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", prefix, b.Note))
+	}
+	return lines
+}
+
+// purchaseOptionRow is one labeled hourly rate from PurchaseOptions
+type purchaseOptionRow struct {
+	label  string
+	hourly float64
+}
+
+// purchaseOptionRows lists the purchase options in display order
+func purchaseOptionRows(po *PurchaseOptions) []purchaseOptionRow {
+	return []purchaseOptionRow{
+		{"On-Demand", po.OnDemandHourly},
+		{"Reserved 1yr", po.Reserved1yrHourly},
+		{"Reserved 3yr", po.Reserved3yrHourly},
+		{"Savings Plan 1yr", po.SavingsPlan1yrHourly},
+		{"Savings Plan 3yr", po.SavingsPlan3yrHourly},
+		{"Spot", po.SpotHourly},
+	}
+}
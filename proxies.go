@@ -0,0 +1,233 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const proxyLabelKey = "mdok.proxy"
+
+// proxyImagePatterns are image substrings that indicate a proxy container
+// These are reverse proxies / API gateways that route traffic to the internet
+var proxyImagePatterns = []string{
+	"traefik",
+	"nginx",
+	"caddy",
+	"haproxy",
+	"envoy",
+	"litellm", // LLM API proxy (OpenAI, Anthropic, etc.)
+}
+
+// ProxyDetector decides which containers are egress proxies: by the
+// configuration's proxy rules in order, then the mdok.proxy label, then the
+// built-in image and name patterns
+type ProxyDetector struct {
+	rules    []proxyRule
+	defaults bool // Use proxyImagePatterns
+}
+
+// proxyRule is a compiled ProxyRule
+type proxyRule struct {
+	name       string // For reasons and errors
+	images     []*regexp.Regexp
+	names      []*regexp.Regexp
+	labels     map[string]string
+	containers map[string]bool
+	proxy      bool
+	fraction   float64
+}
+
+// NewProxyDetector builds the proxy detection of a configuration
+func NewProxyDetector(config Config) (*ProxyDetector, error) {
+	detector := &ProxyDetector{defaults: !config.NoDefaultProxyPatterns}
+	for i, rule := range config.ProxyRules {
+		compiled := proxyRule{
+			labels:     rule.Labels,
+			containers: make(map[string]bool),
+			proxy:      !rule.NotProxy,
+			fraction:   1,
+		}
+		if rule.Name != "" {
+			compiled.name = fmt.Sprintf("rule %q", rule.Name)
+		} else {
+			compiled.name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		if rule.InternetFraction != nil {
+			if rule.NotProxy {
+				return nil, fmt.Errorf("proxy %s sets internet_fraction on containers that are not proxies", compiled.name)
+			}
+			if *rule.InternetFraction < 0 || *rule.InternetFraction > 1 {
+				return nil, fmt.Errorf("proxy %s: internet_fraction must be between 0 and 1", compiled.name)
+			}
+			compiled.fraction = *rule.InternetFraction
+		}
+
+		for _, pattern := range rule.Images {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("proxy %s: invalid image pattern %q: %w", compiled.name, pattern, err)
+			}
+			compiled.images = append(compiled.images, re)
+		}
+		for _, pattern := range rule.Names {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("proxy %s: invalid name pattern %q: %w", compiled.name, pattern, err)
+			}
+			compiled.names = append(compiled.names, re)
+		}
+		for _, name := range rule.Containers {
+			compiled.containers[strings.TrimPrefix(name, "/")] = true
+		}
+
+		if len(compiled.images) == 0 && len(compiled.names) == 0 && len(compiled.labels) == 0 && len(compiled.containers) == 0 {
+			return nil, fmt.Errorf("proxy %s has no images, names, labels or containers", compiled.name)
+		}
+		detector.rules = append(detector.rules, compiled)
+	}
+	return detector, nil
+}
+
+// detect classifies a container. Containers that no rule, label or pattern
+// matched come back as not proxies with no reason. A nil ProxyDetector only
+// uses the label and the built-in patterns.
+func (d *ProxyDetector) detect(c NetworkContainer) ProxyClassification {
+	class := ProxyClassification{Image: c.Image}
+	if len(c.Names) > 0 {
+		class.Container = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	if d != nil {
+		for _, rule := range d.rules {
+			if why := rule.match(c); why != "" {
+				class.Proxy = rule.proxy
+				if rule.proxy {
+					class.InternetFraction = rule.fraction
+				}
+				class.Reason = fmt.Sprintf("proxy %s: %s", rule.name, why)
+				return class
+			}
+		}
+	}
+
+	// Explicit label (can also be used to exclude with "false")
+	if val, ok := c.Labels[proxyLabelKey]; ok {
+		class.Proxy = strings.EqualFold(val, "true") || strings.EqualFold(val, "1") || strings.EqualFold(val, "yes")
+		if class.Proxy {
+			class.InternetFraction = 1
+		}
+		class.Reason = fmt.Sprintf("label %s=%s", proxyLabelKey, val)
+		return class
+	}
+
+	if d != nil && !d.defaults {
+		return class
+	}
+	image := strings.ToLower(c.Image)
+	for _, pattern := range proxyImagePatterns {
+		if strings.Contains(image, pattern) {
+			class.Proxy, class.InternetFraction = true, 1
+			class.Reason = fmt.Sprintf("built-in image pattern %q", pattern)
+			return class
+		}
+	}
+	for _, name := range c.Names {
+		lower := strings.ToLower(name)
+		for _, pattern := range proxyImagePatterns {
+			if strings.Contains(lower, pattern) {
+				class.Proxy, class.InternetFraction = true, 1
+				class.Reason = fmt.Sprintf("built-in name pattern %q", pattern)
+				return class
+			}
+		}
+	}
+	return class
+}
+
+// match returns why a rule matches a container, or "" if it doesn't
+func (r proxyRule) match(c NetworkContainer) string {
+	for _, name := range c.Names {
+		if r.containers[strings.TrimPrefix(name, "/")] {
+			return "listed in containers"
+		}
+	}
+	for _, re := range r.images {
+		if re.MatchString(c.Image) {
+			return fmt.Sprintf("image matches %q", re.String())
+		}
+	}
+	for _, re := range r.names {
+		for _, name := range c.Names {
+			if re.MatchString(strings.TrimPrefix(name, "/")) {
+				return fmt.Sprintf("name matches %q", re.String())
+			}
+		}
+	}
+	if len(r.labels) > 0 {
+		for key, want := range r.labels {
+			value, ok := c.Labels[key]
+			if !ok || (want != "" && value != want) {
+				return ""
+			}
+		}
+		return "labels match"
+	}
+	return ""
+}
+
+// recordProxies merges the proxy classifications seen in a sample into a
+// session's, keeping the latest for each container, sorted by container
+func recordProxies(data *ContainerData, seen []ProxyClassification) {
+	if len(seen) == 0 {
+		return
+	}
+	byName := make(map[string]ProxyClassification)
+	for _, class := range data.Proxies {
+		byName[class.Container] = class
+	}
+	for _, class := range seen {
+		byName[class.Container] = class
+	}
+	data.Proxies = slices.SortedFunc(maps.Values(byName), func(a, b ProxyClassification) int {
+		return cmp.Compare(a.Container, b.Container)
+	})
+}
+
+// proxyKind names how a container was classified
+func proxyKind(class ProxyClassification) string {
+	if class.Proxy {
+		return "proxy"
+	}
+	return "not a proxy"
+}
+
+// proxyInternetShare shows the share of traffic to a proxy counted as
+// internet, or "-" for containers that aren't proxies
+func proxyInternetShare(class ProxyClassification) string {
+	if !class.Proxy {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", class.InternetFraction*100)
+}
+
+// formatProxies lays out proxy classifications as aligned text lines
+func formatProxies(classes []ProxyClassification) []string {
+	width := 0
+	for _, class := range classes {
+		width = max(width, len(class.Container))
+	}
+	lines := make([]string, 0, len(classes))
+	for _, class := range classes {
+		kind := proxyKind(class)
+		if class.Proxy {
+			kind += ", " + proxyInternetShare(class) + " internet"
+		}
+		lines = append(lines, fmt.Sprintf("%-*s  %s (%s)", width, class.Container, kind, class.Reason))
+	}
+	return lines
+}
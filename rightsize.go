@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// HeadroomPolicy controls how observed usage is turned into container limits.
+// Each limit is taken from a statistic (avg, p95, p99, max) and multiplied by
+// a headroom factor.
+type HeadroomPolicy struct {
+	Name               string
+	CPUStat            string  // statistic used for the CPU limit
+	CPUHeadroom        float64 // multiplier applied to the CPU limit
+	CPURequestStat     string  // statistic used for the CPU reservation/request
+	MemStat            string  // statistic used for the memory limit
+	MemHeadroom        float64 // multiplier applied to the memory limit
+	MemReservationStat string  // statistic used for the memory reservation/request
+	PidsHeadroom       float64 // multiplier applied to the max observed PIDs
+}
+
+// headroomPolicies are the built-in policies selectable with --policy
+var headroomPolicies = map[string]HeadroomPolicy{
+	"conservative": {
+		Name:               "conservative",
+		CPUStat:            "max",
+		CPUHeadroom:        1.5,
+		CPURequestStat:     "p95",
+		MemStat:            "max",
+		MemHeadroom:        1.5,
+		MemReservationStat: "p95",
+		PidsHeadroom:       2.0,
+	},
+	"balanced": {
+		Name:               "balanced",
+		CPUStat:            "p99",
+		CPUHeadroom:        1.3,
+		CPURequestStat:     "avg",
+		MemStat:            "max",
+		MemHeadroom:        1.25,
+		MemReservationStat: "p95",
+		PidsHeadroom:       1.5,
+	},
+	"aggressive": {
+		Name:               "aggressive",
+		CPUStat:            "p95",
+		CPUHeadroom:        1.1,
+		CPURequestStat:     "avg",
+		MemStat:            "p99",
+		MemHeadroom:        1.1,
+		MemReservationStat: "avg",
+		PidsHeadroom:       1.25,
+	},
+}
+
+const (
+	defaultHeadroomPolicy = "balanced"
+
+	minCPULimit    = 0.1              // cores
+	cpuLimitStep   = 0.05             // cores (50m)
+	minMemoryLimit = 32 * 1024 * 1024 // 32 MiB
+	memoryStep     = 16 * 1024 * 1024 // 16 MiB
+	minPidsLimit   = 32
+)
+
+// GetHeadroomPolicy returns a built-in policy by name
+func GetHeadroomPolicy(name string) (HeadroomPolicy, error) {
+	if name == "" {
+		name = defaultHeadroomPolicy
+	}
+	policy, ok := headroomPolicies[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(headroomPolicies))
+		for n := range headroomPolicies {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return HeadroomPolicy{}, fmt.Errorf("unknown policy %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	return policy, nil
+}
+
+// summaryStat returns the named statistic from a summary
+func summaryStat(s Summary, stat string) float64 {
+	switch stat {
+	case "min":
+		return s.Min
+	case "avg":
+		return s.Avg
+	case "p95":
+		return s.P95
+	case "p99":
+		return s.P99
+	default:
+		return s.Max
+	}
+}
+
+// RecommendLimits derives container limits from a session summary
+func RecommendLimits(summary *ContainerSummary, current ContainerLimits, policy HeadroomPolicy) *LimitRecommendation {
+	if summary == nil {
+		return nil
+	}
+
+	rec := &LimitRecommendation{Policy: policy.Name}
+
+	// CPU (Docker reports 100% = one full core)
+	cpuObserved := summaryStat(summary.CPUPercent, policy.CPUStat) / 100
+	rec.CPUs = math.Max(roundUpTo(cpuObserved*policy.CPUHeadroom, cpuLimitStep), minCPULimit)
+	cpuReqObserved := summaryStat(summary.CPUPercent, policy.CPURequestStat) / 100
+	rec.CPURequest = math.Min(math.Max(roundUpTo(cpuReqObserved, cpuLimitStep), cpuLimitStep), rec.CPUs)
+
+	explanation := fmt.Sprintf("cpus %.2f: CPU %s %.1f%% (%.2f cores) x %.2f headroom",
+		rec.CPUs, policy.CPUStat, cpuObserved*100, cpuObserved, policy.CPUHeadroom)
+	if current.CPUQuota > 0 && current.CPUPeriod > 0 {
+		currentCPUs := float64(current.CPUQuota) / float64(current.CPUPeriod)
+		explanation += fmt.Sprintf("; current limit %.2f cores (%s)", currentCPUs, formatChange(currentCPUs, rec.CPUs))
+	} else {
+		explanation += "; currently unlimited"
+	}
+	rec.Explanations = append(rec.Explanations, explanation)
+	explanation = fmt.Sprintf("cpu request %.2f: CPU %s %.1f%%", rec.CPURequest, policy.CPURequestStat, cpuReqObserved*100)
+	if current.CPURequest > 0 {
+		currentRequest := float64(current.CPURequest) / 1000
+		explanation += fmt.Sprintf("; current request %.2f cores (%s)", currentRequest, formatChange(currentRequest, rec.CPURequest))
+	}
+	rec.Explanations = append(rec.Explanations, explanation)
+
+	// Memory
+	memObserved := summaryStat(summary.MemoryUsage, policy.MemStat)
+	rec.MemoryLimit = uint64(math.Max(roundUpTo(memObserved*policy.MemHeadroom, memoryStep), minMemoryLimit))
+	explanation = fmt.Sprintf("memory %s: memory %s %s x %.2f headroom",
+		formatBytes(rec.MemoryLimit), policy.MemStat, formatBytes(uint64(memObserved)), policy.MemHeadroom)
+	if current.MemLimit > 0 {
+		explanation += fmt.Sprintf("; current limit %s (%s)", formatBytes(current.MemLimit),
+			formatChange(float64(current.MemLimit), float64(rec.MemoryLimit)))
+	} else {
+		explanation += "; currently unlimited"
+	}
+	rec.Explanations = append(rec.Explanations, explanation)
+
+	memResObserved := summaryStat(summary.MemoryUsage, policy.MemReservationStat)
+	rec.MemoryReservation = uint64(math.Max(roundUpTo(memResObserved, memoryStep), memoryStep))
+	if rec.MemoryReservation > rec.MemoryLimit {
+		rec.MemoryReservation = rec.MemoryLimit
+	}
+	explanation = fmt.Sprintf("memory reservation %s: memory %s %s",
+		formatBytes(rec.MemoryReservation), policy.MemReservationStat, formatBytes(uint64(memResObserved)))
+	if current.MemReservation > 0 {
+		explanation += fmt.Sprintf("; current reservation %s", formatBytes(current.MemReservation))
+	}
+	rec.Explanations = append(rec.Explanations, explanation)
+
+	// PIDs
+	rec.PidsLimit = int64(math.Ceil(summary.PidsCount.Max * policy.PidsHeadroom))
+	if rec.PidsLimit < minPidsLimit {
+		rec.PidsLimit = minPidsLimit
+	}
+	explanation = fmt.Sprintf("pids-limit %d: max %.0f PIDs x %.2f headroom (minimum %d)",
+		rec.PidsLimit, summary.PidsCount.Max, policy.PidsHeadroom, minPidsLimit)
+	if current.PidsLimit > 0 {
+		explanation += fmt.Sprintf("; current limit %d", current.PidsLimit)
+	} else {
+		explanation += "; currently unlimited"
+	}
+	rec.Explanations = append(rec.Explanations, explanation)
+
+	return rec
+}
+
+// roundUpTo rounds v up to the next multiple of step
+func roundUpTo(v, step float64) float64 {
+	if step <= 0 {
+		return v
+	}
+	return math.Ceil(v/step-1e-9) * step
+}
+
+// formatChange describes the relative change from current to proposed
+func formatChange(current, proposed float64) string {
+	if current <= 0 {
+		return "new"
+	}
+	pct := (proposed - current) / current * 100
+	if math.Abs(pct) < 0.5 {
+		return "unchanged"
+	}
+	return fmt.Sprintf("%+.0f%%", pct)
+}
+
+// formatDockerMemory formats bytes for docker run flags (e.g., "512m")
+func formatDockerMemory(b uint64) string {
+	mib := b / (1024 * 1024)
+	if mib > 0 && mib%1024 == 0 {
+		return fmt.Sprintf("%dg", mib/1024)
+	}
+	return fmt.Sprintf("%dm", mib)
+}
+
+// formatComposeMemory formats bytes for compose files (e.g., "512M")
+func formatComposeMemory(b uint64) string {
+	return strings.ToUpper(formatDockerMemory(b))
+}
+
+// formatKubernetesMemory formats bytes as a Kubernetes quantity (e.g., "512Mi")
+func formatKubernetesMemory(b uint64) string {
+	mib := b / (1024 * 1024)
+	if mib > 0 && mib%1024 == 0 {
+		return fmt.Sprintf("%dGi", mib/1024)
+	}
+	return fmt.Sprintf("%dMi", mib)
+}
+
+// formatKubernetesCPU formats cores as a Kubernetes quantity (e.g., "250m")
+func formatKubernetesCPU(cores float64) string {
+	return fmt.Sprintf("%dm", int64(math.Round(cores*1000)))
+}
+
+// formatCores formats cores without trailing zeros (e.g., "0.5", "1.25")
+func formatCores(cores float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", cores), "0"), ".")
+}
+
+// FormatDockerRunFlags returns the docker run flags for a recommendation
+func FormatDockerRunFlags(rec *LimitRecommendation) string {
+	return fmt.Sprintf("--cpus=%s --memory=%s --memory-reservation=%s --pids-limit=%d",
+		formatCores(rec.CPUs),
+		formatDockerMemory(rec.MemoryLimit),
+		formatDockerMemory(rec.MemoryReservation),
+		rec.PidsLimit)
+}
+
+// FormatComposeSnippet returns a docker-compose service snippet for a recommendation
+func FormatComposeSnippet(service string, rec *LimitRecommendation) string {
+	var s strings.Builder
+	s.WriteString("services:\n")
+	s.WriteString(fmt.Sprintf("  %s:\n", service))
+	s.WriteString("    deploy:\n")
+	s.WriteString("      resources:\n")
+	s.WriteString("        limits:\n")
+	s.WriteString(fmt.Sprintf("          cpus: \"%s\"\n", formatCores(rec.CPUs)))
+	s.WriteString(fmt.Sprintf("          memory: %s\n", formatComposeMemory(rec.MemoryLimit)))
+	s.WriteString(fmt.Sprintf("          pids: %d\n", rec.PidsLimit))
+	s.WriteString("        reservations:\n")
+	s.WriteString(fmt.Sprintf("          cpus: \"%s\"\n", formatCores(rec.CPURequest)))
+	s.WriteString(fmt.Sprintf("          memory: %s\n", formatComposeMemory(rec.MemoryReservation)))
+	return s.String()
+}
+
+// FormatKubernetesResources returns a Kubernetes container resources block for a recommendation
+func FormatKubernetesResources(name string, rec *LimitRecommendation) string {
+	var s strings.Builder
+	s.WriteString("containers:\n")
+	s.WriteString(fmt.Sprintf("  - name: %s\n", name))
+	s.WriteString("    resources:\n")
+	s.WriteString("      requests:\n")
+	s.WriteString(fmt.Sprintf("        cpu: %s\n", formatKubernetesCPU(rec.CPURequest)))
+	s.WriteString(fmt.Sprintf("        memory: %s\n", formatKubernetesMemory(rec.MemoryReservation)))
+	s.WriteString("      limits:\n")
+	s.WriteString(fmt.Sprintf("        cpu: %s\n", formatKubernetesCPU(rec.CPUs)))
+	s.WriteString(fmt.Sprintf("        memory: %s\n", formatKubernetesMemory(rec.MemoryLimit)))
+	return s.String()
+}
+
+// indentLines prefixes every non-empty line of s with indent
+func indentLines(s, indent string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// MergeLimitRecommendations combines recommendations for replicas of the same
+// service by taking the larger value of each limit
+func MergeLimitRecommendations(a, b *LimitRecommendation) *LimitRecommendation {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	merged := *a
+	merged.CPUs = math.Max(a.CPUs, b.CPUs)
+	merged.CPURequest = math.Max(a.CPURequest, b.CPURequest)
+	if b.MemoryLimit > merged.MemoryLimit {
+		merged.MemoryLimit = b.MemoryLimit
+	}
+	if b.MemoryReservation > merged.MemoryReservation {
+		merged.MemoryReservation = b.MemoryReservation
+	}
+	if b.PidsLimit > merged.PidsLimit {
+		merged.PidsLimit = b.PidsLimit
+	}
+	merged.Explanations = append(append([]string{}, a.Explanations...), b.Explanations...)
+	return &merged
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Container runtimes a configuration can monitor
+const (
+	RuntimeDocker     = "docker"
+	RuntimePodman     = "podman"
+	RuntimeContainerd = "containerd"
+	RuntimeKubernetes = "kubernetes"
+)
+
+// Runtime event actions
+const (
+	RuntimeEventStart = "start"
+	RuntimeEventStop  = "stop"
+)
+
+// Runtime is a container runtime mdok collects from: the Docker daemon,
+// Podman's Docker-compatible socket, containerd or a Kubernetes kubelet
+type Runtime interface {
+	ListContainers(ctx context.Context) ([]ContainerInfo, error)
+	GetContainerFullID(ctx context.Context, nameOrID string) (string, error)
+	GetContainerLimits(ctx context.Context, containerID string) (ContainerLimits, error)
+	GetContainerImage(ctx context.Context, containerID string) (string, error)
+	GetContainerLabels(ctx context.Context, containerID string) (map[string]string, error)
+	GetHostInfo(ctx context.Context) (HostInfo, error)
+	CollectStats(ctx context.Context, containerID string, prev *StatsResult) (*StatsResult, error)
+	IsContainerRunning(ctx context.Context, containerID string) (bool, error)
+
+	// ListNetworkContainers returns running containers with their networks,
+	// for classifying connections
+	ListNetworkContainers(ctx context.Context) ([]NetworkContainer, error)
+
+	// ContainerPID returns the host PID of a running container's init process,
+	// for reading its network namespace through the host's /proc
+	ContainerPID(ctx context.Context, containerID string) (int, error)
+
+	// Exec runs a command in a container and returns its standard output
+	Exec(ctx context.Context, containerID string, cmd []string) ([]byte, error)
+
+	// Events streams container starts and stops until ctx is done or the
+	// stream fails
+	Events(ctx context.Context) (<-chan RuntimeEvent, <-chan error)
+
+	Close() error
+}
+
+// RuntimeEvent is a container starting or stopping
+type RuntimeEvent struct {
+	ContainerID string
+	Name        string
+	Action      string // RuntimeEventStart or RuntimeEventStop
+	Time        time.Time
+}
+
+// NetworkContainer is a running container and the IPs it has on each network
+type NetworkContainer struct {
+	ID       string
+	Names    []string
+	Image    string
+	Labels   map[string]string
+	Networks map[string][]string // Network name -> IPv4 and IPv6 addresses
+
+	// NetworkMode is "host" or "container:<name or ID>" for containers
+	// without a network namespace of their own
+	NetworkMode string
+}
+
+// ValidateRuntime checks that a runtime is known
+func ValidateRuntime(name string) error {
+	switch name {
+	case "", RuntimeDocker, RuntimePodman, RuntimeContainerd, RuntimeKubernetes:
+		return nil
+	}
+	return fmt.Errorf("unknown runtime %q (expected %q, %q, %q or %q)", name, RuntimeDocker, RuntimePodman, RuntimeContainerd, RuntimeKubernetes)
+}
+
+// NewRuntime connects to the local runtime of a configuration
+func NewRuntime(config Config) (Runtime, error) {
+	switch config.Runtime {
+	case "", RuntimeDocker:
+		return NewDockerClient()
+	case RuntimePodman:
+		return NewPodmanClient()
+	case RuntimeContainerd:
+		return NewContainerdClient()
+	case RuntimeKubernetes:
+		if config.Kubernetes == nil {
+			return nil, fmt.Errorf("configuration '%s' has no Kubernetes target; set one with `mdok kube`", config.Name)
+		}
+		return NewKubeletClient(*config.Kubernetes)
+	}
+	return nil, ValidateRuntime(config.Runtime)
+}
+
+// runtimeVersionLabel describes the runtime and version recorded in host info,
+// e.g. "Docker 25.0.3" or "Podman 4.9.3"
+func runtimeVersionLabel(info HostInfo) string {
+	switch info.Runtime {
+	case RuntimePodman:
+		return "Podman " + info.DockerVer
+	case RuntimeContainerd:
+		return "containerd " + info.DockerVer
+	case RuntimeKubernetes:
+		return "Kubernetes " + info.DockerVer
+	}
+	return "Docker " + info.DockerVer
+}
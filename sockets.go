@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TCP states by their code in the "st" column of /proc/net/tcp
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+}
+
+const (
+	tcpListenState     = "0A"    // LISTEN in /proc/net/tcp
+	udpConnectedState  = "01"    // A connect()ed UDP socket in /proc/net/udp
+	unixListeningFlag  = 0x10000 // __SO_ACCEPTCON in /proc/net/unix's Flags
+	defaultPortRangeLo = 32768   // Linux default net.ipv4.ip_local_port_range
+	defaultPortRangeHi = 60999
+	portRangeTTL       = 5 * time.Minute // How long a container's port range is reused
+)
+
+// socketEntry is a row of /proc/net/tcp, tcp6, udp or udp6
+type socketEntry struct {
+	localIP    net.IP
+	localPort  uint64
+	remoteIP   net.IP
+	remotePort uint64
+	state      string
+}
+
+// parseSocketTable parses a /proc/net/tcp-style table
+func parseSocketTable(output []byte) []socketEntry {
+	var entries []socketEntry
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		if lineNum == 1 {
+			continue // Skip header
+		}
+
+		// Fields 1 and 2 are local_address and rem_address as "IP:PORT"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		localIP, localPort, ok := parseHexAddr(fields[1])
+		if !ok {
+			continue
+		}
+		remoteIP, remotePort, ok := parseHexAddr(fields[2])
+		if !ok {
+			continue
+		}
+		entries = append(entries, socketEntry{
+			localIP:    localIP,
+			localPort:  localPort,
+			remoteIP:   remoteIP,
+			remotePort: remotePort,
+			state:      fields[3],
+		})
+	}
+	return entries
+}
+
+// parseHexAddr parses an "IP:PORT" address in /proc/net hex format
+func parseHexAddr(addr string) (net.IP, uint64, bool) {
+	hexIP, hexPort, ok := strings.Cut(addr, ":")
+	if !ok {
+		return nil, 0, false
+	}
+	ip := parseHexIP(hexIP)
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if ip == nil || err != nil {
+		return nil, 0, false
+	}
+	return ip, port, true
+}
+
+// listeningPort is a port a container listens on
+type listeningPort struct {
+	port  uint64
+	label string // e.g. "8080/tcp" or "127.0.0.1:9090/tcp"
+}
+
+// readSockets takes the inventory of a container's sockets into stats:
+// TCP connections and connected UDP sockets by zone, TCP states, listening
+// ports, Unix sockets and the busiest destination's share of the ephemeral
+// port range. Connections are also tallied per peer, and outgoing ones per
+// destination.
+func readSockets(ctx context.Context, files *containerNetFiles, peers *networkPeers, zones *NetworkZones, tally destinationTally, edges peerTally, stats *NetworkStats) {
+	tables := make(map[string][]socketEntry)
+	for _, file := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		output, err := files.read(ctx, file)
+		if err != nil {
+			continue // Silently skip if file not readable
+		}
+		proto := strings.TrimSuffix(file, "6")
+		tables[proto] = append(tables[proto], parseSocketTable(output)...)
+	}
+	if len(tables) == 0 {
+		return
+	}
+
+	rangeLo, rangeHi := readPortRange(ctx, files)
+	stats.EphemeralRange = int(rangeHi - rangeLo + 1)
+
+	stats.TCPStates = make(map[string]int)
+	listening := make(map[string]listeningPort)
+	perDestination := make(map[string]int) // Outgoing TCP sockets per remote ip:port
+
+	for _, proto := range []string{"tcp", "udp"} {
+		entries := tables[proto]
+
+		// Listening sockets first: connections to their ports are incoming
+		listeningPorts := make(map[uint64]bool)
+		for _, e := range entries {
+			listens := e.state == tcpListenState
+			if proto == "udp" {
+				// Bound but not connected; ephemeral ports are clients'
+				listens = e.remoteIP.IsUnspecified() && e.localPort != 0 &&
+					(e.localPort < rangeLo || e.localPort > rangeHi)
+			}
+			if !listens {
+				continue
+			}
+			listeningPorts[e.localPort] = true
+			label := fmt.Sprintf("%d/%s", e.localPort, proto)
+			if !e.localIP.IsUnspecified() {
+				label = net.JoinHostPort(e.localIP.String(), strconv.FormatUint(e.localPort, 10)) + "/" + proto
+			}
+			listening[label] = listeningPort{port: e.localPort, label: label}
+		}
+
+		for _, e := range entries {
+			if proto == "tcp" && e.state != tcpListenState {
+				stats.TCPStates[tcpStateName(e.state)]++
+			}
+			if e.state == tcpListenState || e.remoteIP.IsUnspecified() {
+				continue // Listening or unconnected
+			}
+			if proto == "udp" && e.state != udpConnectedState {
+				continue
+			}
+
+			// Classify the destination
+			incoming := listeningPorts[e.localPort]
+			zone := zones.classify(e.remoteIP, peers, !incoming)
+			traffic := stats.Zones[zone]
+			if proto == "tcp" {
+				traffic.Conns++
+			} else {
+				traffic.UDP++
+			}
+			stats.Zones[zone] = traffic
+			edges.add(e.remoteIP.String(), zone, incoming, peers).Connections++
+
+			if incoming {
+				continue
+			}
+			if tally != nil {
+				tally.add(e.remoteIP, e.remotePort, zone).Connections++
+			}
+			if proto == "tcp" && e.localPort >= rangeLo && e.localPort <= rangeHi {
+				remote := net.JoinHostPort(e.remoteIP.String(), strconv.FormatUint(e.remotePort, 10))
+				perDestination[remote]++
+				stats.EphemeralPorts = max(stats.EphemeralPorts, perDestination[remote])
+			}
+		}
+	}
+
+	ports := make([]listeningPort, 0, len(listening))
+	for _, p := range listening {
+		ports = append(ports, p)
+	}
+	slices.SortFunc(ports, func(a, b listeningPort) int {
+		if c := cmp.Compare(a.port, b.port); c != 0 {
+			return c
+		}
+		return strings.Compare(a.label, b.label)
+	})
+	for _, p := range ports {
+		stats.Listening = append(stats.Listening, p.label)
+	}
+
+	stats.UnixSockets, stats.UnixListening = readUnixSockets(ctx, files)
+}
+
+// tcpStateName names a TCP state code
+func tcpStateName(code string) string {
+	if name, ok := tcpStates[code]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// readUnixSockets counts the Unix sockets of a container's network
+// namespace and how many of them listen
+func readUnixSockets(ctx context.Context, files *containerNetFiles) (total, listening int) {
+	output, err := files.read(ctx, "unix")
+	if err != nil {
+		return 0, 0
+	}
+
+	// Num RefCount Protocol Flags Type St Inode Path
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		if lineNum == 1 {
+			continue // Skip header
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 7 {
+			continue
+		}
+		total++
+		if flags, err := strconv.ParseUint(fields[3], 16, 32); err == nil && flags&unixListeningFlag != 0 {
+			listening++
+		}
+	}
+	return total, listening
+}
+
+// portRange is a container's cached ephemeral port range
+type portRange struct {
+	lo, hi  uint64
+	expires time.Time
+}
+
+// portRanges caches port ranges by container ID, as reading one takes an exec
+var portRanges = struct {
+	mu      sync.Mutex
+	entries map[string]portRange
+}{entries: make(map[string]portRange)}
+
+// readPortRange returns the container's ephemeral port range. net.* sysctls
+// are per network namespace, and /proc/sys shows those of the reader's
+// namespace even through /proc/<pid>/root, so the range is read by exec'ing
+// cat in the container and cached for portRangeTTL. When that fails (no cat
+// in the image) the Linux default, which a new namespace starts with, is
+// assumed.
+func readPortRange(ctx context.Context, files *containerNetFiles) (lo, hi uint64) {
+	now := time.Now()
+	portRanges.mu.Lock()
+	cached, ok := portRanges.entries[files.id]
+	portRanges.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.lo, cached.hi
+	}
+
+	lo, hi = defaultPortRangeLo, defaultPortRangeHi
+	output, err := files.rt.Exec(ctx, files.id, []string{"cat", "/proc/sys/net/ipv4/ip_local_port_range"})
+	if err == nil {
+		if fields := strings.Fields(string(output)); len(fields) == 2 {
+			readLo, errLo := strconv.ParseUint(fields[0], 10, 16)
+			readHi, errHi := strconv.ParseUint(fields[1], 10, 16)
+			if errLo == nil && errHi == nil && readLo <= readHi {
+				lo, hi = readLo, readHi
+			}
+		}
+	}
+
+	portRanges.mu.Lock()
+	for id, entry := range portRanges.entries {
+		if now.After(entry.expires) {
+			delete(portRanges.entries, id) // Removed containers
+		}
+	}
+	portRanges.entries[files.id] = portRange{lo: lo, hi: hi, expires: now.Add(portRangeTTL)}
+	portRanges.mu.Unlock()
+	return lo, hi
+}
+
+// summarizeSockets summarizes the socket inventory of a session's samples.
+// It returns nil when no sample has one.
+func summarizeSockets(samples []Sample) *SocketSummary {
+	var inventoried []Sample
+	for _, s := range samples {
+		if s.NetEphemeralRange > 0 {
+			inventoried = append(inventoried, s)
+		}
+	}
+	if len(inventoried) == 0 {
+		return nil
+	}
+
+	summary := &SocketSummary{TCPStates: make(map[string]Summary)}
+	listening := make(map[string]bool)
+	states := make(map[string]bool)
+	var unix, ephemeral []float64
+	for _, s := range inventoried {
+		for _, port := range s.NetListen {
+			if !listening[port] {
+				listening[port] = true
+				summary.ListeningPorts = append(summary.ListeningPorts, port)
+			}
+		}
+		for state := range s.NetTCPStates {
+			states[state] = true
+		}
+		unix = append(unix, float64(s.NetUnixSockets))
+		ephemeral = append(ephemeral, float64(s.NetEphemeralPorts))
+		summary.EphemeralRange = s.NetEphemeralRange
+	}
+	for state := range states {
+		summary.TCPStates[state] = calculateStats(tcpStateCounts(inventoried, state))
+	}
+	summary.UnixSockets = calculateStats(unix)
+	summary.EphemeralPorts = calculateStats(ephemeral)
+	return summary
+}
+
+// tcpStateCounts returns the number of TCP sockets in a state per sample
+func tcpStateCounts(samples []Sample, state string) []float64 {
+	counts := make([]float64, len(samples))
+	for i, s := range samples {
+		counts[i] = float64(s.NetTCPStates[state])
+	}
+	return counts
+}
+
+// closeWaitLeakMin is the CLOSE_WAIT count a growing trend must reach to be
+// reported as a leak
+const closeWaitLeakMin = 10
+
+// socketWarnings flags socket leaks: CLOSE_WAIT sockets that keep piling up
+// (the application doesn't close connections its peers closed) and outgoing
+// connections to one destination using up the ephemeral port range
+func socketWarnings(samples []Sample, sockets *SocketSummary) []string {
+	if sockets == nil {
+		return nil
+	}
+	var warnings []string
+
+	var inventoried []Sample
+	for _, s := range samples {
+		if s.NetEphemeralRange > 0 {
+			inventoried = append(inventoried, s)
+		}
+	}
+	closeWait := tcpStateCounts(inventoried, "CLOSE_WAIT")
+	if n := len(closeWait); n >= 6 {
+		first := calculateStats(closeWait[:n/3]).Avg
+		last := calculateStats(closeWait[n-n/3:]).Avg
+		if closeWait[n-1] >= closeWaitLeakMin && last >= 2*first+5 {
+			warnings = append(warnings, fmt.Sprintf("CLOSE_WAIT sockets grew from %.0f to %.0f - connections closed by peers are not being closed (socket leak)",
+				first, closeWait[n-1]))
+		}
+	}
+
+	if sockets.EphemeralRange > 0 && sockets.EphemeralPorts.Max >= float64(sockets.EphemeralRange)*0.80 {
+		warnings = append(warnings, fmt.Sprintf("Ephemeral ports near exhaustion: up to %.0f of %d in use to one destination - reuse connections (keep-alive, pooling)",
+			sockets.EphemeralPorts.Max, sockets.EphemeralRange))
+	}
+	return warnings
+}
+
+// formatSocketSummary lays out a socket summary as text lines
+func formatSocketSummary(sockets *SocketSummary) []string {
+	var lines []string
+	if len(sockets.ListeningPorts) > 0 {
+		lines = append(lines, "Listening:  "+strings.Join(sockets.ListeningPorts, ", "))
+	}
+
+	// States in the kernel's order, which roughly follows a connection's life
+	codes := make([]string, 0, len(tcpStates))
+	for code := range tcpStates {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	var states []string
+	for _, code := range codes {
+		if s, ok := sockets.TCPStates[tcpStates[code]]; ok && s.Max > 0 {
+			states = append(states, fmt.Sprintf("%s avg %.1f max %.0f", tcpStates[code], s.Avg, s.Max))
+		}
+	}
+	if len(states) > 0 {
+		lines = append(lines, "TCP states: "+strings.Join(states, ", "))
+	}
+
+	lines = append(lines, fmt.Sprintf("Sockets:    unix avg %.0f max %.0f, ephemeral ports max %.0f of %d to one destination",
+		sockets.UnixSockets.Avg, sockets.UnixSockets.Max, sockets.EphemeralPorts.Max, sockets.EphemeralRange))
+	return lines
+}
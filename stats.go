@@ -77,50 +77,27 @@ func CalculateSummary(samples []Sample) *ContainerSummary {
 		summary.BlockWriteTotal = lastSample.BlockWrite
 	}
 
-	// Calculate network breakdown percentages
+	// Calculate network breakdown by zone
 	// Prefer byte-based data (from conntrack) when available, fall back to connection counts
-	var totalBytesInterContainer, totalBytesInternal, totalBytesInternet uint64
-	var totalConnInterContainer, totalConnInternal, totalConnInternet int
-	var samplesWithBytes, samplesWithConnections int
-
+	totals := make(map[string]ZoneTraffic)
 	for _, s := range samples {
-		// Check for byte-based data (conntrack)
-		totalBytes := s.NetBytesInterContainer + s.NetBytesInternal + s.NetBytesInternet
-		if totalBytes > 0 {
-			totalBytesInterContainer += s.NetBytesInterContainer
-			totalBytesInternal += s.NetBytesInternal
-			totalBytesInternet += s.NetBytesInternet
-			samplesWithBytes++
-		}
-
-		// Also collect connection counts as fallback
-		totalConns := s.NetConnInterContainer + s.NetConnInternal + s.NetConnInternet
-		if totalConns > 0 {
-			totalConnInterContainer += s.NetConnInterContainer
-			totalConnInternal += s.NetConnInternal
-			totalConnInternet += s.NetConnInternet
-			samplesWithConnections++
+		for zone, traffic := range sampleZones(s) {
+			t := totals[zone]
+			t.Conns += traffic.Conns
+			t.UDP += traffic.UDP
+			t.TxBytes += traffic.TxBytes
+			t.RxBytes += traffic.RxBytes
+			totals[zone] = t
 		}
 	}
-
-	// Use byte-based breakdown if available (more accurate)
-	if samplesWithBytes > 0 && (totalBytesInterContainer+totalBytesInternal+totalBytesInternet) > 0 {
-		totalBytes := float64(totalBytesInterContainer + totalBytesInternal + totalBytesInternet)
-		summary.NetworkBreakdown = &NetworkBreakdown{
-			InterContainerPct: float64(totalBytesInterContainer) / totalBytes * 100,
-			InternalPct:       float64(totalBytesInternal) / totalBytes * 100,
-			InternetPct:       float64(totalBytesInternet) / totalBytes * 100,
-		}
-	} else if samplesWithConnections > 0 && (totalConnInterContainer+totalConnInternal+totalConnInternet) > 0 {
-		// Fall back to connection-based estimate
-		totalConns := float64(totalConnInterContainer + totalConnInternal + totalConnInternet)
-		summary.NetworkBreakdown = &NetworkBreakdown{
-			InterContainerPct: float64(totalConnInterContainer) / totalConns * 100,
-			InternalPct:       float64(totalConnInternal) / totalConns * 100,
-			InternetPct:       float64(totalConnInternet) / totalConns * 100,
-		}
+	if basis, shares := zoneShares(totals); shares != nil {
+		summary.NetworkBreakdown = &NetworkBreakdown{Basis: basis, Zones: shares}
 	}
 
+	summary.TopDestinations = summarizeDestinations(samples)
+	summary.Sockets = summarizeSockets(samples)
+	summary.ZoneBandwidth = summarizeZoneBandwidth(samples)
+
 	return summary
 }
 
@@ -181,6 +158,9 @@ func percentile(sorted []float64, p float64) float64 {
 	return sorted[lower]*(1-weight) + sorted[upper]*weight
 }
 
+// defaultPricingRegion is the region network costs are estimated for
+const defaultPricingRegion = "us-east-1"
+
 // AWS region pricing for data transfer (approximate, as of 2024)
 var awsDataTransferPricing = map[string]float64{
 	"us-east-1":      0.09, // per GB after first 10TB
@@ -190,19 +170,22 @@ var awsDataTransferPricing = map[string]float64{
 	"default":        0.09,
 }
 
-// CalculateNetworkCost estimates AWS data transfer costs
-func CalculateNetworkCost(egressBytes uint64) *NetworkCostEstimate {
-	region := "us-east-1" // Default region
+// CalculateNetworkCost estimates AWS data transfer costs of a summarized
+// session. With a network breakdown, egress is split by zone and each zone
+// is priced at its own rate. Containers sharing a network namespace are
+// estimated on the namespace's traffic, which the notes point out.
+func CalculateNetworkCost(data *ContainerData) *NetworkCostEstimate {
+	region := defaultPricingRegion
 	pricePerGB := awsDataTransferPricing[region]
 
-	egressGB := float64(egressBytes) / (1024 * 1024 * 1024)
+	egressGB := float64(data.Summary.NetTxTotal) / (1024 * 1024 * 1024)
 
 	// AWS pricing is tiered, but we use simplified model
 	// First 1GB/month is free, then tiered pricing
 	// We'll use average rate for simplicity
 	estimatedCost := egressGB * pricePerGB
 
-	return &NetworkCostEstimate{
+	estimate := &NetworkCostEstimate{
 		Region:           region,
 		EgressGB:         egressGB,
 		IngressGB:        0, // Ingress is typically free
@@ -210,6 +193,29 @@ func CalculateNetworkCost(egressBytes uint64) *NetworkCostEstimate {
 		PricePerGB:       pricePerGB,
 		Notes:            "Estimate based on standard data transfer rates. Actual costs may vary.",
 	}
+
+	if breakdown := data.Summary.NetworkBreakdown; breakdown != nil {
+		estimatedCost = 0
+		estimate.PricePerGB = 0
+		for _, share := range breakdown.shares() {
+			zoneGB := egressGB * share.Pct / 100
+			rate := zoneRate(share.Zone, data.ZoneRates, pricePerGB)
+			estimate.PricePerGB += rate * share.Pct / 100
+			estimate.Zones = append(estimate.Zones, ZoneCost{
+				Zone:       share.Zone,
+				EgressGB:   zoneGB,
+				PricePerGB: rate,
+				CostUSD:    zoneGB * rate,
+			})
+			estimatedCost += zoneGB * rate
+		}
+		estimate.EstimatedCostUSD = estimatedCost
+		estimate.Notes = "Egress split by network zone using the traffic breakdown, each at its own rate. Actual costs may vary."
+	}
+	if data.NetworkNamespace != nil {
+		estimate.Notes += " The network namespace is shared, so this is its traffic, not the container's alone."
+	}
+	return estimate
 }
 
 // AWS instance types (simplified subset)
@@ -255,6 +261,13 @@ var awsInstanceTypes = []InstanceType{
 
 // RecommendInstance provides a basic instance type recommendation for a specific architecture
 func RecommendInstance(summary *ContainerSummary, arch string) *InstanceRecommendation {
+	return RecommendInstanceForHost(summary, arch, HostInfo{})
+}
+
+// RecommendInstanceForHost provides an instance type recommendation for a specific
+// architecture. When the host has a CPU calibration score, the required vCPUs are
+// scaled by the host score and each instance family's relative performance.
+func RecommendInstanceForHost(summary *ContainerSummary, arch string, host HostInfo) *InstanceRecommendation {
 	if summary == nil {
 		return nil
 	}
@@ -267,6 +280,14 @@ func RecommendInstance(summary *ContainerSummary, arch string) *InstanceRecommen
 	// Determine if workload is CPU or memory bound
 	cpuBound := summary.CPUPercent.P95 > summary.MemoryPercent.P95
 
+	// Required vCPUs on a given instance type (host cores unless normalized)
+	requiredVCPU := func(inst InstanceType) float64 {
+		if host.CPUScore <= 0 {
+			return requiredCPU
+		}
+		return requiredCPU * host.CPUScore / instanceFamilyPerf(inst.Type)
+	}
+
 	// Find suitable instance for specified architecture
 	var recommendation *InstanceRecommendation
 	var lastOfArch *InstanceType
@@ -278,7 +299,7 @@ func RecommendInstance(summary *ContainerSummary, arch string) *InstanceRecommen
 		lastOfArch = &inst
 
 		// Check if instance has enough resources
-		if float64(inst.VCPU) >= requiredCPU && inst.MemoryGB >= requiredMemGB {
+		if float64(inst.VCPU) >= requiredVCPU(inst) && inst.MemoryGB >= requiredMemGB {
 			reason := ""
 			if cpuBound {
 				reason = fmt.Sprintf("CPU-bound workload (P95: %.1f%%)", summary.CPUPercent.P95)
@@ -311,12 +332,28 @@ func RecommendInstance(summary *ContainerSummary, arch string) *InstanceRecommen
 		}
 	}
 
+	if recommendation != nil {
+		inst := InstanceType{Type: recommendation.InstanceType, VCPU: recommendation.VCPU,
+			MemoryGB: recommendation.MemoryGB, Hourly: recommendation.HourlyPrice, Arch: arch}
+		recommendation.MonthlyPrice = roundPrice(inst.Hourly * hoursPerMonth)
+		recommendation.PurchaseOptions = purchaseOptionsFor(inst)
+		recommendation.Burstable = checkBurstableCredits(inst, summary, host)
+	}
+
+	if recommendation != nil && host.CPUScore > 0 {
+		perf := instanceFamilyPerf(recommendation.InstanceType)
+		recommendation.RequiredVCPU = math.Round(requiredCPU*host.CPUScore/perf*100) / 100
+		recommendation.Reason += fmt.Sprintf("; normalized to %.2f %s vCPUs (host score %.2f, %s factor %.2f)",
+			recommendation.RequiredVCPU, instanceFamily(recommendation.InstanceType),
+			host.CPUScore, instanceFamily(recommendation.InstanceType), perf)
+	}
+
 	return recommendation
 }
 
 // RecommendBothArchitectures returns recommendations for both x86 and ARM
-func RecommendBothArchitectures(summary *ContainerSummary) (x86, arm *InstanceRecommendation) {
-	return RecommendInstance(summary, "x86"), RecommendInstance(summary, "arm")
+func RecommendBothArchitectures(summary *ContainerSummary, host HostInfo) (x86, arm *InstanceRecommendation) {
+	return RecommendInstanceForHost(summary, "x86", host), RecommendInstanceForHost(summary, "arm", host)
 }
 
 // DetectWarnings identifies potential issues in the monitoring data
@@ -372,5 +409,8 @@ func DetectWarnings(data *ContainerData) []string {
 		}
 	}
 
+	// Socket leaks
+	warnings = append(warnings, socketWarnings(data.Samples, data.Summary.Sockets)...)
+
 	return warnings
 }
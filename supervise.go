@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Restart policies
+const (
+	RestartNever     = "no"
+	RestartOnFailure = "on-failure"
+)
+
+// Supervisor restart backoff: doubles after each failure up to the maximum,
+// and starts over once a daemon has stayed up for supervisorStableRun
+const (
+	supervisorMinBackoff = time.Second
+	supervisorMaxBackoff = time.Minute
+	supervisorStableRun  = time.Minute
+)
+
+// defaultSidecarImage is the image used in generated docker run definitions
+const defaultSidecarImage = "mdok:latest"
+
+// ValidateRestartPolicy checks that a restart policy is known
+func ValidateRestartPolicy(policy string) error {
+	switch policy {
+	case "", RestartNever, RestartOnFailure:
+		return nil
+	}
+	return fmt.Errorf("unknown restart policy %q (expected %q or %q)", policy, RestartNever, RestartOnFailure)
+}
+
+// Supervise runs the monitoring daemon for a configuration as a child process
+// and restarts it with backoff when it fails. Only restarted daemons resume
+// the unfinished session; the first one starts a new session. A clean exit
+// (e.g. `mdok stop`) ends supervision, and SIGINT/SIGTERM are forwarded to the
+// daemon.
+func Supervise(config Config) error {
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	backoff := supervisorMinBackoff
+	restarting := false
+	for {
+		args := []string{"start", config.Name, "--foreground"}
+		if restarting {
+			args = append(args, "--resume")
+		}
+		cmd := exec.Command(executable, args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("failed to start daemon: %w", err)
+		}
+		started := time.Now()
+		logger.Printf("Supervisor: started daemon for '%s' (PID %d)\n", config.Name, cmd.Process.Pid)
+
+		exited := make(chan error, 1)
+		go func() { exited <- cmd.Wait() }()
+
+		select {
+		case sig := <-sigChan:
+			logger.Printf("Supervisor: received %v, stopping daemon\n", sig)
+			cmd.Process.Signal(syscall.SIGTERM)
+			<-exited
+			return nil
+		case err := <-exited:
+			if err == nil {
+				logger.Println("Supervisor: daemon exited cleanly, not restarting")
+				return nil
+			}
+			if time.Since(started) >= supervisorStableRun {
+				backoff = supervisorMinBackoff
+			}
+			logger.Printf("Supervisor: daemon failed (%v), restarting in %s\n", err, backoff)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case sig := <-sigChan:
+			logger.Printf("Supervisor: received %v while waiting to restart\n", sig)
+			return nil
+		}
+		backoff = min(backoff*2, supervisorMaxBackoff)
+		restarting = true
+	}
+}
+
+// SystemdUserUnit returns a systemd user unit that runs the supervisor for a
+// configuration and appends its output to the mdok log file
+func SystemdUserUnit(config Config, executable string) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=mdok monitoring for %s\n", config.Name)
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Type=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s supervise %s\n", executable, config.Name)
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=5\n")
+	fmt.Fprintf(&b, "StandardOutput=append:%s\n", GetLogFile(config.Name))
+	fmt.Fprintf(&b, "StandardError=append:%s\n", GetLogFile(config.Name))
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=default.target\n")
+	return b.String()
+}
+
+// DockerSidecarCommand returns a docker run command that supervises a
+// configuration from a container restarted by Docker. The supervisor inside
+// restarts failed daemons, so only those resume the session; a container
+// Docker restarts (e.g. after a reboot) starts a new one.
+func DockerSidecarCommand(config Config, image string) string {
+	lines := []string{
+		"docker run -d",
+		fmt.Sprintf("--name mdok-%s", sanitizeFilename(config.Name)),
+		"--restart unless-stopped",
+		"-v /var/run/docker.sock:/var/run/docker.sock",
+		fmt.Sprintf("-v /proc:%s:ro", defaultHostProc),
+		fmt.Sprintf("-v /sys:%s:ro", defaultHostSys),
+		fmt.Sprintf("-v %s:/root/.mdok", mdokDir),
+		fmt.Sprintf("%s supervise %s", image, config.Name),
+	}
+	return strings.Join(lines, " \\\n  ") + "\n"
+}
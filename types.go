@@ -4,52 +4,135 @@ import "time"
 
 // Config represents a monitoring configuration
 type Config struct {
-	Name       string   `json:"name"`
-	Containers []string `json:"containers"`
-	Interval   int      `json:"interval"` // seconds
-	CreatedAt  string   `json:"created_at"`
+	Name                   string            `json:"name"`
+	Containers             []string          `json:"containers"`
+	Interval               int               `json:"interval"` // seconds
+	CreatedAt              string            `json:"created_at"`
+	NormalizeCPU           bool              `json:"normalize_cpu,omitempty"`             // Calibrate host CPU before sizing recommendations
+	HostCPUFactor          float64           `json:"host_cpu_factor,omitempty"`           // Stored host score (skips the benchmark at start)
+	HostCPUFactorSource    string            `json:"host_cpu_factor_source,omitempty"`    // "benchmark" (from mdok calibrate) or "user" (default)
+	Restart                string            `json:"restart,omitempty"`                   // Restart policy: "no" (default) or "on-failure"
+	Endpoints              []DockerEndpoint  `json:"endpoints,omitempty"`                 // Remote Docker daemons; containers on them are named "endpoint/name"
+	Aggregated             bool              `json:"aggregated,omitempty"`                // Data is pushed by agents to this aggregator, not monitored locally
+	Runtime                string            `json:"runtime,omitempty"`                   // Container runtime: "docker" (default), "podman", "containerd" or "kubernetes"
+	Kubernetes             *KubernetesTarget `json:"kubernetes,omitempty"`                // Pods monitored by a kubernetes configuration
+	NetworkLabels          map[string]string `json:"network_labels,omitempty"`            // CIDR -> name for top destinations, e.g. "10.8.0.0/16": "vpn"
+	NoReverseDNS           bool              `json:"no_reverse_dns,omitempty"`            // Don't name top destinations by reverse DNS
+	NetworkZones           []NetworkZone     `json:"network_zones,omitempty"`             // Named traffic zones, tried in order before the built-in ones
+	ProxyRules             []ProxyRule       `json:"proxy_rules,omitempty"`               // Which containers are egress proxies, tried in order before the mdok.proxy label
+	NoDefaultProxyPatterns bool              `json:"no_default_proxy_patterns,omitempty"` // Don't detect proxies by the built-in image and name patterns
+}
+
+// ProxyRule decides whether the containers it matches are egress proxies.
+// A container matching any of its image, name, label or container rules is
+// matched; the first matching rule applies.
+type ProxyRule struct {
+	Name             string            `json:"name,omitempty"`              // Shown in reports; "rule N" if unset
+	Images           []string          `json:"images,omitempty"`            // Regular expressions matched against the image
+	Names            []string          `json:"names,omitempty"`             // Regular expressions matched against container names
+	Labels           map[string]string `json:"labels,omitempty"`            // All must match; "" matches any value
+	Containers       []string          `json:"containers,omitempty"`        // Container names
+	NotProxy         bool              `json:"not_proxy,omitempty"`         // Matched containers are not proxies
+	InternetFraction *float64          `json:"internet_fraction,omitempty"` // Share of traffic to the proxy that counts as internet; 1 if unset
+}
+
+// NetworkZone is a named part of the network traffic is classified into,
+// such as a VPN, a peered VPC or an on-prem range. Traffic matching any of
+// its rules belongs to it.
+type NetworkZone struct {
+	Name            string            `json:"name"`
+	CIDRs           []string          `json:"cidrs,omitempty"`
+	ContainerLabels map[string]string `json:"container_labels,omitempty"` // Labels of destination containers; "" matches any value
+	DNSSuffixes     []string          `json:"dns_suffixes,omitempty"`     // Reverse DNS name suffixes, e.g. "corp.example.com"
+	CostPerGB       *float64          `json:"cost_per_gb,omitempty"`      // Egress rate in USD; 0 if unset, built-in zones keep their default
+}
+
+// KubernetesTarget selects the pods a kubernetes configuration monitors. Its
+// containers are named "pod.container" and follow the selector as pods come and go.
+type KubernetesTarget struct {
+	Kubelet    string   `json:"kubelet"` // Kubelet API, e.g. https://127.0.0.1:10250
+	Namespace  string   `json:"namespace"`
+	Selector   string   `json:"selector,omitempty"`   // Label selector, e.g. "app=api,tier!=cache"
+	Containers []string `json:"containers,omitempty"` // Container names within the pods; empty for all
+	Source     string   `json:"source,omitempty"`     // Samples from "kubelet" (/stats/summary, default) or "cgroups"
+	TokenFile  string   `json:"token_file,omitempty"` // Bearer token, e.g. a service account token
+	CACert     string   `json:"ca_cert,omitempty"`    // CA for the kubelet's serving certificate
+	Insecure   bool     `json:"insecure,omitempty"`   // Skip verifying the kubelet's certificate
+}
+
+// DockerEndpoint is a named Docker daemon a configuration collects from
+type DockerEndpoint struct {
+	Name      string `json:"name"`
+	Host      string `json:"host"`                  // unix:///path, tcp://host:port or ssh://user@host
+	TLSCACert string `json:"tls_ca_cert,omitempty"` // CA certificate for tcp:// with TLS
+	TLSCert   string `json:"tls_cert,omitempty"`    // Client certificate
+	TLSKey    string `json:"tls_key,omitempty"`     // Client key
 }
 
 // HostInfo contains information about the host system
 type HostInfo struct {
-	Hostname     string `json:"hostname"`
-	CPUModel     string `json:"cpu_model"`
-	CPUCores     int    `json:"cpu_cores"`
-	MemoryTotal  uint64 `json:"memory_total"`
-	Architecture string `json:"architecture"`
-	OS           string `json:"os"`
-	KernelVer    string `json:"kernel_version"`
-	DockerVer    string `json:"docker_version"`
+	Hostname       string  `json:"hostname"`
+	CPUModel       string  `json:"cpu_model"`
+	CPUCores       int     `json:"cpu_cores"`
+	MemoryTotal    uint64  `json:"memory_total"`
+	Architecture   string  `json:"architecture"`
+	OS             string  `json:"os"`
+	KernelVer      string  `json:"kernel_version"`
+	DockerVer      string  `json:"docker_version"`             // Version of the runtime (Docker, Podman or containerd)
+	Runtime        string  `json:"runtime,omitempty"`          // Empty for data recorded before runtimes were selectable (Docker)
+	CPUScore       float64 `json:"cpu_score,omitempty"`        // Per-core performance relative to the reference vCPU
+	CPUScoreSource string  `json:"cpu_score_source,omitempty"` // "benchmark" or "user"
 }
 
 // ContainerLimits represents resource limits for a container
 type ContainerLimits struct {
-	CPUQuota   int64  `json:"cpu_quota"`
-	CPUPeriod  int64  `json:"cpu_period"`
-	CPUShares  int64  `json:"cpu_shares"`
-	MemLimit   uint64 `json:"memory_limit"`
-	MemSwap    int64  `json:"memory_swap"`
-	PidsLimit  int64  `json:"pids_limit"`
+	CPUQuota       int64  `json:"cpu_quota"`
+	CPUPeriod      int64  `json:"cpu_period"`
+	CPUShares      int64  `json:"cpu_shares"`
+	MemLimit       uint64 `json:"memory_limit"`
+	MemReservation uint64 `json:"memory_reservation,omitempty"`
+	MemSwap        int64  `json:"memory_swap"`
+	PidsLimit      int64  `json:"pids_limit"`
+	CPURequest     int64  `json:"cpu_request_millicores,omitempty"` // Kubernetes CPU request; memory requests are MemReservation
 }
 
 // Sample represents a single metric snapshot
 type Sample struct {
-	Timestamp       time.Time `json:"timestamp"`
-	CPUPercent      float64   `json:"cpu_percent"`
-	MemoryUsage     uint64    `json:"memory_usage"`
-	MemoryPercent   float64   `json:"memory_percent"`
-	MemoryCache     uint64    `json:"memory_cache"`
-	NetRxBytes      uint64    `json:"net_rx_bytes"`
-	NetTxBytes      uint64    `json:"net_tx_bytes"`
-	NetRxRate       float64   `json:"net_rx_rate"`       // bytes/sec
-	NetTxRate       float64   `json:"net_tx_rate"`       // bytes/sec
-	BlockRead       uint64    `json:"block_read"`
-	BlockWrite      uint64    `json:"block_write"`
-	BlockReadRate   float64   `json:"block_read_rate"`   // bytes/sec
-	BlockWriteRate  float64   `json:"block_write_rate"`  // bytes/sec
-	PidsCount       uint64    `json:"pids_count"`
-
-	// Network connection breakdown (approximate, from socket counting)
+	Timestamp      time.Time `json:"timestamp"`
+	CPUPercent     float64   `json:"cpu_percent"`
+	MemoryUsage    uint64    `json:"memory_usage"`
+	MemoryPercent  float64   `json:"memory_percent"`
+	MemoryCache    uint64    `json:"memory_cache"`
+	NetRxBytes     uint64    `json:"net_rx_bytes"`
+	NetTxBytes     uint64    `json:"net_tx_bytes"`
+	NetRxRate      float64   `json:"net_rx_rate"` // bytes/sec
+	NetTxRate      float64   `json:"net_tx_rate"` // bytes/sec
+	BlockRead      uint64    `json:"block_read"`
+	BlockWrite     uint64    `json:"block_write"`
+	BlockReadRate  float64   `json:"block_read_rate"`  // bytes/sec
+	BlockWriteRate float64   `json:"block_write_rate"` // bytes/sec
+	PidsCount      uint64    `json:"pids_count"`
+
+	// Network traffic by zone: connections from socket counting, bytes from
+	// conntrack when available
+	NetZones         map[string]ZoneTraffic   `json:"net_zones,omitempty"`
+	NetBytesSource   string                   `json:"net_bytes_source,omitempty"`   // "conntrack" or "estimated"
+	NetZoneBandwidth map[string]ZoneBandwidth `json:"net_zone_bandwidth,omitempty"` // Since the previous sample; estimated from connection shares without conntrack
+
+	// Traffic with other containers for proxy egress attribution, in bytes
+	// by container name (conntrack only)
+	NetCallers  map[string]uint64 `json:"net_callers,omitempty"`   // Received on connections other containers opened
+	NetViaProxy map[string]uint64 `json:"net_via_proxy,omitempty"` // Sent to proxies and counted as internet
+
+	// Socket inventory (from /proc/net/tcp*, udp* and unix)
+	NetListen         []string       `json:"net_listen,omitempty"`     // Listening ports, e.g. "8080/tcp", "127.0.0.1:9090/tcp"
+	NetTCPStates      map[string]int `json:"net_tcp_states,omitempty"` // TCP sockets by state, e.g. "CLOSE_WAIT": 3
+	NetUnixSockets    int            `json:"net_unix_sockets,omitempty"`
+	NetUnixListening  int            `json:"net_unix_listening,omitempty"`
+	NetEphemeralPorts int            `json:"net_ephemeral_ports,omitempty"` // Outgoing TCP sockets to the busiest remote ip:port
+	NetEphemeralRange int            `json:"net_ephemeral_range,omitempty"` // Size of ip_local_port_range
+
+	// Built-in zone breakdown of samples recorded before network zones
 	NetConnInterContainer int `json:"net_conn_inter_container,omitempty"` // Connections to other containers
 	NetConnInternal       int `json:"net_conn_internal,omitempty"`        // Connections to internal/private IPs
 	NetConnInternet       int `json:"net_conn_internet,omitempty"`        // Connections to public IPs
@@ -58,7 +141,42 @@ type Sample struct {
 	NetBytesInterContainer uint64 `json:"net_bytes_inter_container,omitempty"` // Bytes to other containers
 	NetBytesInternal       uint64 `json:"net_bytes_internal,omitempty"`        // Bytes to internal/private IPs
 	NetBytesInternet       uint64 `json:"net_bytes_internet,omitempty"`        // Bytes to public IPs
-	NetBytesSource         string `json:"net_bytes_source,omitempty"`          // "conntrack" or "estimated"
+
+	// Busiest outgoing destinations of this sample
+	NetDestinations []DestinationSample `json:"net_destinations,omitempty"`
+
+	// All traffic by peer container, or by zone for other addresses, and
+	// direction: the edges of the service graph
+	NetPeers []PeerTraffic `json:"net_peers,omitempty"`
+}
+
+// PeerTraffic is the traffic with a peer container or zone in a sample
+type PeerTraffic struct {
+	Container   string `json:"container,omitempty"`   // Name of the peer container
+	Zone        string `json:"zone,omitempty"`        // Zone of addresses that aren't containers
+	Incoming    bool   `json:"incoming,omitempty"`    // On connections the peer opened
+	Bytes       uint64 `json:"bytes,omitempty"`       // Bytes sent and received, from conntrack
+	Connections int    `json:"connections,omitempty"` // Open TCP connections and connected UDP sockets
+}
+
+// DestinationSample is the traffic to one remote address in a sample
+type DestinationSample struct {
+	Addr        string `json:"addr"`                  // "ip:port"
+	Name        string `json:"name,omitempty"`        // Container, network label or reverse DNS name
+	Container   string `json:"container,omitempty"`   // Name of the container owning the address, if any
+	Class       string `json:"class"`                 // "inter-container", "internal" or "internet"
+	Bytes       uint64 `json:"bytes,omitempty"`       // Bytes sent and received, from conntrack
+	Connections int    `json:"connections,omitempty"` // Open outgoing TCP connections
+}
+
+// DestinationSummary is a remote address's traffic over a session
+type DestinationSummary struct {
+	Addr           string  `json:"addr"`
+	Name           string  `json:"name,omitempty"`
+	Class          string  `json:"class"`
+	Bytes          uint64  `json:"bytes,omitempty"`
+	AvgConnections float64 `json:"avg_connections,omitempty"`
+	MaxConnections int     `json:"max_connections,omitempty"`
 }
 
 // Summary contains calculated statistics for a metric
@@ -71,78 +189,225 @@ type Summary struct {
 	Total float64 `json:"total,omitempty"` // for cumulative metrics like network
 }
 
+// ZoneTraffic is a sample's traffic with a network zone. Bytes were
+// transferred since the previous sample, in both directions.
+type ZoneTraffic struct {
+	Conns   int    `json:"conns,omitempty"`    // TCP connections
+	UDP     int    `json:"udp,omitempty"`      // Connected UDP sockets
+	TxBytes uint64 `json:"tx_bytes,omitempty"` // Sent by the container
+	RxBytes uint64 `json:"rx_bytes,omitempty"` // Received by the container
+}
+
+// ZoneBandwidth is the rate of a zone's traffic in bytes/sec
+type ZoneBandwidth struct {
+	Tx float64 `json:"tx"`
+	Rx float64 `json:"rx"`
+}
+
+// ZoneBandwidthSummary summarizes a zone's rates over a session
+type ZoneBandwidthSummary struct {
+	Tx Summary `json:"tx"`
+	Rx Summary `json:"rx"`
+}
+
 // NetworkBreakdown contains estimated traffic distribution
 type NetworkBreakdown struct {
-	InterContainerPct float64 `json:"inter_container_pct"` // Estimated % to other containers
-	InternalPct       float64 `json:"internal_pct"`        // Estimated % to internal/private IPs
-	InternetPct       float64 `json:"internet_pct"`        // Estimated % to public internet
+	Basis string      `json:"basis,omitempty"` // "bytes" (conntrack) or "connections"
+	Zones []ZoneShare `json:"zones,omitempty"` // Largest first
+
+	// Built-in zone percentages of summaries saved before network zones
+	InterContainerPct float64 `json:"inter_container_pct,omitempty"` // Estimated % to other containers
+	InternalPct       float64 `json:"internal_pct,omitempty"`        // Estimated % to internal/private IPs
+	InternetPct       float64 `json:"internet_pct,omitempty"`        // Estimated % to public internet
+}
+
+// ZoneShare is a network zone's share of a session's traffic
+type ZoneShare struct {
+	Zone    string  `json:"zone"`
+	Pct     float64 `json:"pct"`                // Of bytes sent, or of connections
+	TxBytes uint64  `json:"tx_bytes,omitempty"` // Transferred during the session (bytes basis)
+	RxBytes uint64  `json:"rx_bytes,omitempty"`
 }
 
 // ContainerSummary contains all summaries for a container
 type ContainerSummary struct {
-	CPUPercent    Summary `json:"cpu_percent"`
-	MemoryUsage   Summary `json:"memory_usage"`
-	MemoryPercent Summary `json:"memory_percent"`
-	NetRxRate     Summary `json:"net_rx_rate"`
-	NetTxRate     Summary `json:"net_tx_rate"`
-	NetRxTotal    uint64  `json:"net_rx_total"`
-	NetTxTotal    uint64  `json:"net_tx_total"`
-	BlockRead     Summary `json:"block_read_rate"`
-	BlockWrite    Summary `json:"block_write_rate"`
-	BlockReadTotal  uint64 `json:"block_read_total"`
-	BlockWriteTotal uint64 `json:"block_write_total"`
-	PidsCount     Summary `json:"pids_count"`
-	SampleCount   int     `json:"sample_count"`
-	Duration      string  `json:"duration"`
-	Warnings      []string `json:"warnings,omitempty"`
-	NetworkBreakdown *NetworkBreakdown `json:"network_breakdown,omitempty"` // Traffic distribution estimate
+	CPUPercent       Summary                         `json:"cpu_percent"`
+	MemoryUsage      Summary                         `json:"memory_usage"`
+	MemoryPercent    Summary                         `json:"memory_percent"`
+	NetRxRate        Summary                         `json:"net_rx_rate"`
+	NetTxRate        Summary                         `json:"net_tx_rate"`
+	NetRxTotal       uint64                          `json:"net_rx_total"`
+	NetTxTotal       uint64                          `json:"net_tx_total"`
+	BlockRead        Summary                         `json:"block_read_rate"`
+	BlockWrite       Summary                         `json:"block_write_rate"`
+	BlockReadTotal   uint64                          `json:"block_read_total"`
+	BlockWriteTotal  uint64                          `json:"block_write_total"`
+	PidsCount        Summary                         `json:"pids_count"`
+	SampleCount      int                             `json:"sample_count"`
+	Duration         string                          `json:"duration"`
+	Warnings         []string                        `json:"warnings,omitempty"`
+	NetworkBreakdown *NetworkBreakdown               `json:"network_breakdown,omitempty"` // Traffic distribution estimate
+	TopDestinations  []DestinationSummary            `json:"top_destinations,omitempty"`  // Busiest destinations by bytes and connections
+	Sockets          *SocketSummary                  `json:"sockets,omitempty"`           // Listening ports and socket counts over the session
+	ZoneBandwidth    map[string]ZoneBandwidthSummary `json:"zone_bandwidth,omitempty"`    // Rates by zone, counting samples without the zone as 0
+}
+
+// SocketSummary summarizes a container's sockets over a session
+type SocketSummary struct {
+	ListeningPorts []string           `json:"listening_ports,omitempty"` // Seen at any time
+	TCPStates      map[string]Summary `json:"tcp_states,omitempty"`      // Per state, counting samples without it as 0
+	UnixSockets    Summary            `json:"unix_sockets"`
+	EphemeralPorts Summary            `json:"ephemeral_ports"` // Outgoing TCP sockets to the busiest remote ip:port
+	EphemeralRange int                `json:"ephemeral_range,omitempty"`
 }
 
 // NetworkCostEstimate contains AWS data transfer cost estimates
 type NetworkCostEstimate struct {
-	Region           string  `json:"region"`
-	EgressGB         float64 `json:"egress_gb"`
-	IngressGB        float64 `json:"ingress_gb"`
-	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
-	PricePerGB       float64 `json:"price_per_gb"`
-	Notes            string  `json:"notes,omitempty"`
+	Region           string     `json:"region"`
+	EgressGB         float64    `json:"egress_gb"`
+	IngressGB        float64    `json:"ingress_gb"`
+	EstimatedCostUSD float64    `json:"estimated_cost_usd"`
+	PricePerGB       float64    `json:"price_per_gb"` // Blended over zones when split by zone
+	Notes            string     `json:"notes,omitempty"`
+	Zones            []ZoneCost `json:"zones,omitempty"` // Egress split by the network breakdown
+}
+
+// ZoneCost is the estimated egress cost of a network zone
+type ZoneCost struct {
+	Zone       string  `json:"zone"`
+	EgressGB   float64 `json:"egress_gb"`
+	PricePerGB float64 `json:"price_per_gb"`
+	CostUSD    float64 `json:"cost_usd"`
 }
 
 // InstanceRecommendation contains AWS instance type suggestions
 type InstanceRecommendation struct {
-	InstanceType  string  `json:"instance_type"`
-	VCPU          int     `json:"vcpu"`
-	MemoryGB      float64 `json:"memory_gb"`
-	Reason        string  `json:"reason"`
-	HourlyPrice   float64 `json:"hourly_price_usd,omitempty"`
-	Architecture  string  `json:"architecture,omitempty"` // "x86" or "arm"
+	InstanceType    string                `json:"instance_type"`
+	VCPU            int                   `json:"vcpu"`
+	MemoryGB        float64               `json:"memory_gb"`
+	Reason          string                `json:"reason"`
+	HourlyPrice     float64               `json:"hourly_price_usd,omitempty"`
+	Architecture    string                `json:"architecture,omitempty"`  // "x86" or "arm"
+	RequiredVCPU    float64               `json:"required_vcpu,omitempty"` // Normalized vCPUs needed (when the host is calibrated)
+	MonthlyPrice    float64               `json:"monthly_price_usd,omitempty"`
+	PurchaseOptions *PurchaseOptions      `json:"purchase_options,omitempty"`
+	Burstable       *BurstableCreditCheck `json:"burstable,omitempty"` // Only for t-family instances
+}
+
+// PurchaseOptions contains effective hourly rates for an instance under
+// different pricing models
+type PurchaseOptions struct {
+	OnDemandHourly       float64 `json:"on_demand_hourly_usd"`
+	Reserved1yrHourly    float64 `json:"reserved_1yr_hourly_usd"`
+	Reserved3yrHourly    float64 `json:"reserved_3yr_hourly_usd"`
+	SavingsPlan1yrHourly float64 `json:"savings_plan_1yr_hourly_usd"`
+	SavingsPlan3yrHourly float64 `json:"savings_plan_3yr_hourly_usd"`
+	SpotHourly           float64 `json:"spot_hourly_usd"` // Typical, varies by AZ and time
+}
+
+// BurstableCreditCheck describes whether a burstable instance's CPU credits
+// would sustain the observed average CPU
+type BurstableCreditCheck struct {
+	BaselinePct       float64 `json:"baseline_pct"`     // Baseline across all vCPUs (100 = one full vCPU)
+	AvgCPUPct         float64 `json:"avg_cpu_pct"`      // Observed average in target vCPUs
+	CreditsPerHour    float64 `json:"credits_per_hour"` // Net credit balance change (negative = draining)
+	Sustainable       bool    `json:"sustainable"`
+	SurplusMonthlyUSD float64 `json:"surplus_monthly_usd,omitempty"` // Unlimited-mode surplus charge
+	Note              string  `json:"note"`
+}
+
+// LimitRecommendation contains suggested container resource limits derived
+// from observed usage plus a headroom policy
+type LimitRecommendation struct {
+	Policy            string   `json:"policy"`
+	CPUs              float64  `json:"cpus"`               // --cpus / limits.cpu (cores)
+	CPURequest        float64  `json:"cpu_request"`        // reservations.cpus / requests.cpu (cores)
+	MemoryLimit       uint64   `json:"memory_limit"`       // --memory / limits.memory
+	MemoryReservation uint64   `json:"memory_reservation"` // --memory-reservation / requests.memory
+	PidsLimit         int64    `json:"pids_limit"`         // --pids-limit
+	Explanations      []string `json:"explanations,omitempty"`
+}
+
+// FargateRecommendation contains the smallest valid Fargate task size for a workload
+type FargateRecommendation struct {
+	Architecture     string  `json:"architecture"` // "x86" or "arm"
+	VCPU             float64 `json:"vcpu"`
+	MemoryGB         float64 `json:"memory_gb"`
+	HourlyPrice      float64 `json:"hourly_price_usd"`
+	MonthlyPrice     float64 `json:"monthly_price_usd"`
+	SpotHourlyPrice  float64 `json:"spot_hourly_price_usd,omitempty"` // Fargate Spot (0 if unavailable)
+	SpotMonthlyPrice float64 `json:"spot_monthly_price_usd,omitempty"`
+	Reason           string  `json:"reason"`
 }
 
 // ContainerData represents the full metrics file structure for a container
 type ContainerData struct {
-	ContainerID   string              `json:"container_id"`
-	ContainerName string              `json:"container_name"`
-	ImageName     string              `json:"image_name"`
-	Host          HostInfo            `json:"host"`
-	Limits        ContainerLimits     `json:"limits"`
-	SessionID     string              `json:"session_id,omitempty"`     // Unique ID for each monitoring session
-	StartTime     time.Time           `json:"start_time"`
-	EndTime       time.Time           `json:"end_time,omitempty"`
-	Interval      int                 `json:"interval_seconds"`
-	Samples       []Sample            `json:"samples"`
-	Summary       *ContainerSummary   `json:"summary,omitempty"`
-	NetworkCost   *NetworkCostEstimate `json:"network_cost,omitempty"`
-	Recommendation *InstanceRecommendation `json:"recommendation,omitempty"`
+	ContainerID      string                   `json:"container_id"`
+	ContainerName    string                   `json:"container_name"`
+	ImageName        string                   `json:"image_name"`
+	ComposeService   string                   `json:"compose_service,omitempty"` // From the com.docker.compose.service label
+	Endpoint         string                   `json:"endpoint,omitempty"`        // Docker endpoint name ("" for the local daemon)
+	Host             HostInfo                 `json:"host"`
+	Limits           ContainerLimits          `json:"limits"`
+	SessionID        string                   `json:"session_id,omitempty"` // Unique ID for each monitoring session
+	StartTime        time.Time                `json:"start_time"`
+	EndTime          time.Time                `json:"end_time,omitempty"`
+	Interval         int                      `json:"interval_seconds"`
+	Samples          []Sample                 `json:"samples"`
+	Summary          *ContainerSummary        `json:"summary,omitempty"`
+	NetworkCost      *NetworkCostEstimate     `json:"network_cost,omitempty"`
+	ZoneRates        map[string]float64       `json:"zone_rates,omitempty"`        // Egress rates of the config's network zones
+	Proxies          []ProxyClassification    `json:"proxies,omitempty"`           // Containers detected as proxies, or excluded by a rule or label, during the session
+	NetworkNamespace *NetworkNamespace        `json:"network_namespace,omitempty"` // Set when the container shares its network namespace with the host or other containers
+	Recommendation   *InstanceRecommendation  `json:"recommendation,omitempty"`
+	Fargate          []*FargateRecommendation `json:"fargate,omitempty"` // Fargate task sizes (x86 and ARM)
+	Events           []SessionEvent           `json:"events,omitempty"`  // Reconfigurations, restarts and container stops during the session
+}
+
+// NetworkNamespace is a network namespace a container shares with the host
+// (--network host) or other containers (--network container:<name>, pods).
+// Interface counters, sockets and conntrack are the namespace's, so its
+// network figures aren't the container's alone.
+type NetworkNamespace struct {
+	Mode   string   `json:"mode,omitempty"`   // "host", "container:<name>" when it joined another container's, or "" for its own
+	Owner  string   `json:"owner,omitempty"`  // Container whose namespace it is; "" for the host's
+	Shared []string `json:"shared,omitempty"` // Other running containers in it, sorted
+}
+
+// ProxyClassification records whether a container was treated as an egress
+// proxy and why
+type ProxyClassification struct {
+	Container        string  `json:"container"`
+	Image            string  `json:"image,omitempty"`
+	Proxy            bool    `json:"proxy"`
+	InternetFraction float64 `json:"internet_fraction,omitempty"` // Share of traffic to it counted as internet
+	Reason           string  `json:"reason"`
+}
+
+// Session event types
+const (
+	SessionEventReconfigured = "reconfigured"      // Config was hot-reloaded into the running session
+	SessionEventResumed      = "resumed"           // Daemon was restarted and continued the session after a gap
+	SessionEventStopped      = "container_stopped" // The container stopped or exited
+	SessionEventStarted      = "container_started" // The container started again, possibly recreated under a new ID
+)
+
+// SessionEvent marks something that happened during a session, such as a
+// config reload
+type SessionEvent struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
 }
 
 // SessionInfo contains metadata about a monitoring session
 type SessionInfo struct {
-	SessionID  string    `json:"session_id"`
-	ConfigName string    `json:"config_name"`
-	StartTime  time.Time `json:"start_time"`
-	EndTime    time.Time `json:"end_time,omitempty"`
-	SampleCount int      `json:"sample_count"`
-	Containers []string  `json:"containers"`
+	SessionID   string    `json:"session_id"`
+	ConfigName  string    `json:"config_name"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time,omitempty"`
+	SampleCount int       `json:"sample_count"`
+	Containers  []string  `json:"containers"`
 }
 
 // MonitoringSession represents an active monitoring session
@@ -158,11 +423,26 @@ type MonitoringSession struct {
 
 // DaemonStatus represents the status of a daemon instance
 type DaemonStatus struct {
-	ConfigName string    `json:"config_name"`
-	PID        int       `json:"pid"`
-	StartTime  time.Time `json:"start_time"`
-	Running    bool      `json:"running"`
-	Containers []string  `json:"containers"`
+	ConfigName      string            `json:"config_name"`
+	PID             int               `json:"pid"`
+	StartTime       time.Time         `json:"start_time"`
+	Running         bool              `json:"running"`
+	Containers      []string          `json:"containers"`
+	SessionID       string            `json:"session_id,omitempty"`
+	Interval        int               `json:"interval,omitempty"` // Current collection interval (seconds)
+	Paused          bool              `json:"paused,omitempty"`
+	Source          string            `json:"source,omitempty"` // "socket" (control socket) or "pid" (PID file only)
+	ContainerStatus []ContainerStatus `json:"container_status,omitempty"`
+}
+
+// ContainerStatus is the collection state of one container in a running daemon
+type ContainerStatus struct {
+	Name          string    `json:"name"`
+	ContainerID   string    `json:"container_id,omitempty"`
+	Samples       int       `json:"samples"`
+	LastSample    time.Time `json:"last_sample,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time,omitempty"`
 }
 
 // ContainerInfo represents basic container information for selection
@@ -176,10 +456,10 @@ type ContainerInfo struct {
 
 // ExportOptions contains options for exporting data
 type ExportOptions struct {
-	Format   string    // json, csv, markdown, html
-	Last     string    // duration like "1h", "30m"
-	From     time.Time
-	To       time.Time
-	All      bool
-	Output   string    // output file path
+	Format string // json, csv, markdown, html
+	Last   string // duration like "1h", "30m"
+	From   time.Time
+	To     time.Time
+	All    bool
+	Output string // output file path
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -494,7 +495,7 @@ func (m SelectionModel) View() string {
 // DashboardModel is the TUI model for live monitoring dashboard
 type DashboardModel struct {
 	config        Config
-	docker        *DockerClient
+	docker        *RuntimePool
 	containerData map[string]*ContainerData
 	prevStats     map[string]*StatsResult
 	err           error
@@ -503,17 +504,35 @@ type DashboardModel struct {
 	width         int
 	height        int
 	lastUpdate    time.Time
+
+	// Samples streamed from the daemon's control socket (nil when polling Docker)
+	stream       <-chan ControlSample
+	streamConn   net.Conn
+	daemonPaused bool
 }
 
-// NewDashboardModel creates a new dashboard model
+// NewDashboardModel creates a new dashboard model. When the daemon for the
+// config has a control socket, the dashboard shows the daemon's own samples;
+// otherwise it collects stats from Docker itself.
 func NewDashboardModel(config Config) DashboardModel {
-	docker, _ := NewDockerClient()
-	return DashboardModel{
+	m := DashboardModel{
 		config:        config,
-		docker:        docker,
 		containerData: make(map[string]*ContainerData),
 		prevStats:     make(map[string]*StatsResult),
 	}
+
+	if stream, conn, err := StreamDaemonSamples(config.Name); err == nil {
+		m.stream = stream
+		m.streamConn = conn
+		if status, err := QueryDaemonStatus(config.Name); err == nil {
+			m.config.Interval = status.Interval
+			m.daemonPaused = status.Paused
+		}
+		return m
+	}
+
+	m.docker = NewRuntimePool(config)
+	return m
 }
 
 type tickMsg time.Time
@@ -522,14 +541,63 @@ type statsMsg struct {
 	stats     *StatsResult
 	err       error
 }
+type streamSampleMsg ControlSample
+type streamClosedMsg struct{}
+type controlStatusMsg struct {
+	status *DaemonStatus
+	err    error
+}
 
 func (m DashboardModel) Init() tea.Cmd {
+	if m.stream != nil {
+		return tea.Batch(
+			tea.EnterAltScreen,
+			m.waitForSample(),
+			m.pollDaemonStatus(),
+		)
+	}
 	return tea.Batch(
 		tea.EnterAltScreen,
 		m.tick(),
 	)
 }
 
+// waitForSample waits for the next sample from the daemon stream
+func (m DashboardModel) waitForSample() tea.Cmd {
+	return func() tea.Msg {
+		sample, ok := <-m.stream
+		if !ok {
+			return streamClosedMsg{}
+		}
+		return streamSampleMsg(sample)
+	}
+}
+
+// pollDaemonStatus refreshes the daemon's interval and pause state
+func (m DashboardModel) pollDaemonStatus() tea.Cmd {
+	return tea.Tick(5*time.Second, func(t time.Time) tea.Msg {
+		status, err := QueryDaemonStatus(m.config.Name)
+		return controlStatusMsg{status: status, err: err}
+	})
+}
+
+// addSample appends a sample to a container's in-memory history
+func (m *DashboardModel) addSample(container string, sample Sample) {
+	if m.containerData[container] == nil {
+		m.containerData[container] = &ContainerData{
+			ContainerName: container,
+			StartTime:     time.Now(),
+			Samples:       make([]Sample, 0),
+		}
+	}
+	m.containerData[container].Samples = append(m.containerData[container].Samples, sample)
+
+	// Keep only last 100 samples in memory for dashboard
+	if len(m.containerData[container].Samples) > 100 {
+		m.containerData[container].Samples = m.containerData[container].Samples[1:]
+	}
+}
+
 func (m DashboardModel) tick() tea.Cmd {
 	return tea.Tick(time.Duration(m.config.Interval)*time.Second, func(t time.Time) tea.Msg {
 		return tickMsg(t)
@@ -542,8 +610,13 @@ func (m DashboardModel) collectStats(container string) tea.Cmd {
 			return statsMsg{container: container, err: fmt.Errorf("docker client not initialized")}
 		}
 
+		docker, name, err := m.docker.Resolve(container)
+		if err != nil {
+			return statsMsg{container: container, err: err}
+		}
+
 		ctx := context.Background()
-		stats, err := m.docker.CollectStats(ctx, container, m.prevStats[container])
+		stats, err := docker.CollectStats(ctx, name, m.prevStats[container])
 		return statsMsg{
 			container: container,
 			stats:     stats,
@@ -561,10 +634,44 @@ func (m DashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.docker != nil {
 				m.docker.Close()
 			}
+			if m.streamConn != nil {
+				m.streamConn.Close()
+			}
 			return m, tea.Quit
 		case "p", " ":
 			m.paused = !m.paused
+		case "P":
+			// Pause or resume the daemon's collection
+			if m.stream != nil {
+				command := ControlPause
+				if m.daemonPaused {
+					command = ControlResume
+				}
+				if resp, err := SendControlCommand(m.config.Name, ControlRequest{Command: command}); err != nil {
+					m.err = err
+				} else if resp.Status != nil {
+					m.daemonPaused = resp.Status.Paused
+				}
+			}
+		}
+
+	case streamSampleMsg:
+		if !m.paused {
+			m.lastUpdate = time.Now()
+			m.addSample(msg.ContainerName, msg.Sample)
 		}
+		return m, m.waitForSample()
+
+	case streamClosedMsg:
+		m.err = fmt.Errorf("monitoring daemon stopped")
+		return m, nil
+
+	case controlStatusMsg:
+		if msg.err == nil && msg.status != nil {
+			m.config.Interval = msg.status.Interval
+			m.daemonPaused = msg.status.Paused
+		}
+		return m, m.pollDaemonStatus()
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -590,24 +697,7 @@ func (m DashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.err = msg.err
 		} else {
 			m.prevStats[msg.container] = msg.stats
-
-			// Update or create container data
-			if m.containerData[msg.container] == nil {
-				m.containerData[msg.container] = &ContainerData{
-					ContainerName: msg.container,
-					StartTime:     time.Now(),
-					Samples:       make([]Sample, 0),
-				}
-			}
-			m.containerData[msg.container].Samples = append(
-				m.containerData[msg.container].Samples,
-				msg.stats.Sample,
-			)
-
-			// Keep only last 100 samples in memory for dashboard
-			if len(m.containerData[msg.container].Samples) > 100 {
-				m.containerData[msg.container].Samples = m.containerData[msg.container].Samples[1:]
-			}
+			m.addSample(msg.container, msg.stats.Sample)
 		}
 	}
 
@@ -626,6 +716,9 @@ func (m DashboardModel) View() string {
 	if m.paused {
 		header += warningStyle.Render(" [PAUSED]")
 	}
+	if m.daemonPaused {
+		header += warningStyle.Render(" [COLLECTION PAUSED]")
+	}
 	s.WriteString(header)
 	s.WriteString("\n")
 	s.WriteString(dimStyle.Render(fmt.Sprintf("Last update: %s | Interval: %ds",
@@ -684,7 +777,11 @@ func (m DashboardModel) View() string {
 	}
 
 	// Help
-	s.WriteString(helpStyle.Render("p: pause | q: quit"))
+	if m.stream != nil {
+		s.WriteString(helpStyle.Render("p: pause view | P: pause/resume collection | q: quit"))
+	} else {
+		s.WriteString(helpStyle.Render("p: pause | q: quit"))
+	}
 
 	return s.String()
 }
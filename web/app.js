@@ -0,0 +1,415 @@
+// mdok web dashboard. Talks to the JSON API served by `mdok serve`.
+'use strict';
+
+const COLORS = ['#2196F3', '#4CAF50', '#FF9800', '#9C27B0', '#F44336', '#00BCD4', '#795548', '#607D8B'];
+const MAX_POINTS = 600;
+const HOURS_PER_MONTH = 730;
+
+const METRICS = {
+    cpu_percent: { title: 'CPU %', format: v => v.toFixed(1) + '%' },
+    memory_usage: { title: 'Memory', format: formatBytes },
+    net_rx_rate: { title: 'Network RX', format: v => formatBytes(v) + '/s' },
+    net_tx_rate: { title: 'Network TX', format: v => formatBytes(v) + '/s' },
+    block_read_rate: { title: 'Block Read', format: v => formatBytes(v) + '/s' },
+    block_write_rate: { title: 'Block Write', format: v => formatBytes(v) + '/s' },
+    pids_count: { title: 'PIDs', format: v => Math.round(v).toString() },
+};
+
+const state = {
+    config: null,
+    running: false,
+    session: '',
+    metric: 'cpu_percent',
+    samples: {},       // container name -> samples
+    stream: null,      // EventSource for live updates
+    reportTimer: null,
+    view: null,        // root element of the config view
+    tokenAsked: false, // the API token was asked for
+};
+
+// ---- API ----
+
+async function api(path) {
+    let resp = await fetch(path);
+    // `mdok serve --token`: ask once and keep the token in a cookie, which
+    // the live stream (EventSource) sends too
+    if (resp.status === 401) {
+        if (!state.tokenAsked) {
+            state.tokenAsked = true;
+            const token = window.prompt('API token (mdok serve --token)');
+            if (token) {
+                document.cookie = `mdok_token=${encodeURIComponent(token)}; path=/; SameSite=Strict`;
+            }
+        }
+        resp = await fetch(path);
+    }
+    if (!resp.ok) {
+        let msg = resp.statusText;
+        try { msg = (await resp.json()).error || msg; } catch (e) { /* not JSON */ }
+        throw new Error(msg);
+    }
+    return resp.json();
+}
+
+// ---- Formatting ----
+
+function formatBytes(b) {
+    const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+    let i = 0;
+    while (b >= 1024 && i < units.length - 1) {
+        b /= 1024;
+        i++;
+    }
+    return b.toFixed(i === 0 ? 0 : 1) + ' ' + units[i];
+}
+
+function formatTime(t) {
+    return new Date(t).toLocaleTimeString();
+}
+
+function formatMoney(v) {
+    return '$' + v.toFixed(2);
+}
+
+function el(tag, attrs, ...children) {
+    const node = document.createElement(tag);
+    for (const [k, v] of Object.entries(attrs || {})) {
+        if (k === 'class') node.className = v;
+        else if (k === 'style') node.style.cssText = v;
+        else node.setAttribute(k, v);
+    }
+    for (const child of children) {
+        if (child == null) continue;
+        node.append(child instanceof Node ? child : document.createTextNode(child));
+    }
+    return node;
+}
+
+// ---- Chart ----
+
+// drawChart draws a multi-series line chart on a canvas.
+// series: [{name, color, points: [[timeMs, value], ...]}]
+function drawChart(canvas, series, format) {
+    const dpr = window.devicePixelRatio || 1;
+    const width = canvas.clientWidth;
+    const height = canvas.clientHeight || 280;
+    canvas.width = width * dpr;
+    canvas.height = height * dpr;
+    const ctx = canvas.getContext('2d');
+    ctx.scale(dpr, dpr);
+    ctx.clearRect(0, 0, width, height);
+
+    const pad = { left: 70, right: 12, top: 10, bottom: 28 };
+    const plotW = width - pad.left - pad.right;
+    const plotH = height - pad.top - pad.bottom;
+
+    let minT = Infinity, maxT = -Infinity, maxV = 0;
+    for (const s of series) {
+        for (const [t, v] of s.points) {
+            minT = Math.min(minT, t);
+            maxT = Math.max(maxT, t);
+            maxV = Math.max(maxV, v);
+        }
+    }
+
+    ctx.font = '11px sans-serif';
+    ctx.fillStyle = '#888';
+    if (!isFinite(minT)) {
+        ctx.fillText('No samples', pad.left, pad.top + plotH / 2);
+        return;
+    }
+    if (maxT === minT) maxT = minT + 1000;
+    if (maxV === 0) maxV = 1;
+    maxV *= 1.1;
+
+    const x = t => pad.left + (t - minT) / (maxT - minT) * plotW;
+    const y = v => pad.top + plotH - v / maxV * plotH;
+
+    // Grid and axis labels
+    ctx.strokeStyle = '#eee';
+    ctx.lineWidth = 1;
+    ctx.textAlign = 'right';
+    ctx.textBaseline = 'middle';
+    for (let i = 0; i <= 4; i++) {
+        const v = maxV * i / 4;
+        const yy = Math.round(y(v)) + 0.5;
+        ctx.beginPath();
+        ctx.moveTo(pad.left, yy);
+        ctx.lineTo(pad.left + plotW, yy);
+        ctx.stroke();
+        ctx.fillText(format(v), pad.left - 6, yy);
+    }
+    ctx.textAlign = 'center';
+    ctx.textBaseline = 'top';
+    for (let i = 0; i <= 4; i++) {
+        const t = minT + (maxT - minT) * i / 4;
+        ctx.fillText(formatTime(t), x(t), pad.top + plotH + 8);
+    }
+
+    // Lines
+    ctx.lineWidth = 1.5;
+    for (const s of series) {
+        ctx.strokeStyle = s.color;
+        ctx.beginPath();
+        s.points.forEach(([t, v], i) => {
+            if (i === 0) ctx.moveTo(x(t), y(v));
+            else ctx.lineTo(x(t), y(v));
+        });
+        ctx.stroke();
+    }
+}
+
+function renderChart() {
+    if (!state.view) return;
+    const metric = METRICS[state.metric];
+    const names = Object.keys(state.samples).sort();
+    const series = names.map((name, i) => ({
+        name,
+        color: COLORS[i % COLORS.length],
+        points: state.samples[name].map(s => [Date.parse(s.timestamp), s[state.metric] || 0]),
+    }));
+
+    state.view.querySelector('.chart-title').textContent = metric.title;
+    drawChart(state.view.querySelector('.chart'), series, metric.format);
+
+    const legend = state.view.querySelector('.legend');
+    legend.replaceChildren(...series.map(s => el('span', { style: '--swatch: ' + s.color }, s.name)));
+}
+
+// ---- Reports ----
+
+function renderComparison(reports) {
+    const tbody = state.view.querySelector('.compare tbody');
+    tbody.replaceChildren(...reports.filter(r => r.summary).map(r => {
+        const s = r.summary;
+        return el('tr', {},
+            el('td', {}, r.container_name),
+            el('td', {}, String(s.sample_count)),
+            el('td', {}, s.cpu_percent.avg.toFixed(1) + '%'),
+            el('td', {}, s.cpu_percent.p95.toFixed(1) + '%'),
+            el('td', {}, s.cpu_percent.max.toFixed(1) + '%'),
+            el('td', {}, formatBytes(s.memory_usage.avg)),
+            el('td', {}, formatBytes(s.memory_usage.p95)),
+            el('td', {}, formatBytes(s.memory_usage.max)),
+            el('td', {}, formatBytes(s.net_rx_total)),
+            el('td', {}, formatBytes(s.net_tx_total)));
+    }));
+}
+
+function renderReport(r) {
+    const card = el('div', { class: 'report' },
+        el('h4', {}, r.container_name, ' ', el('span', { class: 'dim' }, r.image_name)));
+
+    const warnings = (r.summary && r.summary.warnings) || [];
+    for (const w of warnings) {
+        card.append(el('div', { class: 'warning' }, '⚠️ ' + w));
+    }
+    for (const e of r.events || []) {
+        card.append(el('div', { class: 'dim' }, `${formatTime(e.time)} ${e.type}: ${e.message}`));
+    }
+
+    if (r.instances && r.instances.length) {
+        const rows = r.instances.map(i => {
+            const po = i.purchase_options;
+            const monthly = h => po ? formatMoney(h * HOURS_PER_MONTH) : '-';
+            return el('tr', {},
+                el('td', {}, 'EC2 ' + (i.architecture || 'x86')),
+                el('td', {}, `${i.instance_type} (${i.vcpu} vCPU, ${i.memory_gb} GB)`),
+                el('td', {}, formatMoney(i.monthly_price_usd || i.hourly_price_usd * HOURS_PER_MONTH)),
+                el('td', {}, po ? monthly(po.reserved_1yr_hourly_usd) + ' / ' + monthly(po.reserved_3yr_hourly_usd) : '-'),
+                el('td', {}, po ? monthly(po.savings_plan_1yr_hourly_usd) + ' / ' + monthly(po.savings_plan_3yr_hourly_usd) : '-'),
+                el('td', {}, po ? monthly(po.spot_hourly_usd) : '-'));
+        });
+        for (const f of r.fargate || []) {
+            rows.push(el('tr', {},
+                el('td', {}, 'Fargate ' + f.architecture),
+                el('td', {}, `${f.vcpu} vCPU / ${f.memory_gb} GB`),
+                el('td', {}, formatMoney(f.monthly_price_usd)),
+                el('td', {}, '-'),
+                el('td', {}, '-'),
+                el('td', {}, f.spot_monthly_price_usd ? formatMoney(f.spot_monthly_price_usd) : 'n/a')));
+        }
+        card.append(el('table', {},
+            el('thead', {}, el('tr', {},
+                el('th', {}, 'Option'), el('th', {}, 'Size'), el('th', {}, 'On-demand/month'),
+                el('th', {}, 'Reserved 1yr / 3yr'), el('th', {}, 'Savings Plan 1yr / 3yr'), el('th', {}, 'Spot/month'))),
+            el('tbody', {}, ...rows)));
+
+        for (const i of r.instances) {
+            if (i.burstable) {
+                card.append(el('div', { class: i.burstable.sustainable ? 'dim' : 'warning' },
+                    `${i.instance_type} CPU credits: ${i.burstable.note}`));
+            }
+        }
+    }
+
+    const lr = r.limits_recommendation;
+    if (lr) {
+        const flags = `--cpus=${+lr.cpus.toFixed(2)} --memory=${formatBytes(lr.memory_limit)} ` +
+            `--memory-reservation=${formatBytes(lr.memory_reservation)} --pids-limit=${lr.pids_limit}`;
+        card.append(el('p', {}, `Recommended limits (${lr.policy}):`), el('pre', {}, flags));
+    }
+
+    return card;
+}
+
+async function loadReports() {
+    if (!state.view) return;
+    const params = state.session ? '?session=' + encodeURIComponent(state.session) : '';
+    try {
+        const reports = await api(`/api/configs/${encodeURIComponent(state.config)}/report${params}`);
+        renderComparison(reports);
+        state.view.querySelector('.reports').replaceChildren(...reports.map(renderReport));
+    } catch (e) {
+        setStatus('Failed to load report: ' + e.message);
+    }
+}
+
+// ---- Samples and live stream ----
+
+function stopLive() {
+    if (state.stream) {
+        state.stream.close();
+        state.stream = null;
+    }
+    clearInterval(state.reportTimer);
+    state.reportTimer = null;
+}
+
+async function loadSamples() {
+    const params = new URLSearchParams({ max_points: MAX_POINTS });
+    params.set('session', state.session || 'current');
+    const resp = await api(`/api/configs/${encodeURIComponent(state.config)}/samples?${params}`);
+    state.samples = {};
+    for (const c of resp.containers) {
+        state.samples[c.container_name] = c.samples;
+    }
+    renderChart();
+}
+
+function startLive() {
+    let latest = 0;
+    for (const samples of Object.values(state.samples)) {
+        if (samples.length) latest = Math.max(latest, Date.parse(samples[samples.length - 1].timestamp));
+    }
+    const since = latest ? '?since=' + encodeURIComponent(new Date(latest).toISOString()) : '';
+    const stream = new EventSource(`/api/configs/${encodeURIComponent(state.config)}/live${since}`);
+
+    stream.addEventListener('samples', e => {
+        const ev = JSON.parse(e.data);
+        const samples = (state.samples[ev.container_name] || []).concat(ev.samples);
+        // Keep the chart bounded; the full history is available per session
+        state.samples[ev.container_name] = samples.slice(-MAX_POINTS * 2);
+        renderChart();
+    });
+    stream.addEventListener('status', e => {
+        const status = JSON.parse(e.data);
+        setLive(status.running);
+        if (!status.running) {
+            stopLive();
+            loadReports();
+            loadConfigs();
+        }
+    });
+    stream.onerror = () => setStatus('Live stream disconnected, retrying...');
+    stream.onopen = () => setStatus('');
+
+    state.stream = stream;
+    state.reportTimer = setInterval(loadReports, 30000);
+}
+
+function setLive(live) {
+    state.running = live;
+    const badge = state.view && state.view.querySelector('.badge');
+    if (!badge) return;
+    badge.textContent = live ? 'LIVE' : 'stopped';
+    badge.classList.toggle('live', live);
+}
+
+async function showSession() {
+    stopLive();
+    try {
+        await loadSamples();
+    } catch (e) {
+        setStatus('Failed to load samples: ' + e.message);
+    }
+    loadReports();
+    // Live updates only apply to the current session of a running daemon
+    if (state.running && !state.session) startLive();
+}
+
+// ---- Configurations ----
+
+async function openConfig(name, running) {
+    stopLive();
+    state.config = name;
+    state.session = '';
+    state.samples = {};
+
+    const view = document.getElementById('config-view').content.cloneNode(true);
+    const main = document.getElementById('main');
+    main.replaceChildren(view);
+    state.view = main;
+
+    main.querySelector('.config-name').textContent = name;
+    setLive(running);
+
+    const sessionSelect = main.querySelector('.session-select');
+    sessionSelect.append(el('option', { value: '' }, running ? 'Current (live)' : 'Most recent'));
+    try {
+        const sessions = await api(`/api/configs/${encodeURIComponent(name)}/sessions`);
+        for (const s of sessions) {
+            const label = `${new Date(s.start_time).toLocaleString()} (${s.sample_count} samples)`;
+            sessionSelect.append(el('option', { value: s.session_id }, label));
+        }
+    } catch (e) {
+        setStatus('Failed to load sessions: ' + e.message);
+    }
+    sessionSelect.addEventListener('change', () => {
+        state.session = sessionSelect.value;
+        showSession();
+    });
+
+    const metricSelect = main.querySelector('.metric-select');
+    metricSelect.value = state.metric;
+    metricSelect.addEventListener('change', () => {
+        state.metric = metricSelect.value;
+        renderChart();
+    });
+
+    document.querySelectorAll('#configs li').forEach(li => li.classList.toggle('active', li.dataset.name === name));
+    showSession();
+}
+
+async function loadConfigs() {
+    try {
+        const [configs, daemons] = await Promise.all([api('/api/configs'), api('/api/daemons')]);
+        const running = new Set(daemons.map(d => d.config_name));
+        const list = document.getElementById('configs');
+        list.replaceChildren(...configs.map(c => {
+            const li = el('li', {},
+                el('span', {}, c.name),
+                running.has(c.name) ? el('span', { class: 'dot', title: 'running' }, '●') : el('span', { class: 'dim' }, '○'));
+            li.dataset.name = c.name;
+            li.classList.toggle('active', c.name === state.config);
+            li.addEventListener('click', () => openConfig(c.name, running.has(c.name)));
+            return li;
+        }));
+
+        // Pick up a daemon started after the page was opened
+        if (state.config && !state.session && running.has(state.config) && !state.running) {
+            setLive(true);
+            showSession();
+        }
+    } catch (e) {
+        setStatus('Failed to load configurations: ' + e.message);
+    }
+}
+
+function setStatus(msg) {
+    document.getElementById('status').textContent = msg;
+}
+
+window.addEventListener('resize', renderChart);
+loadConfigs();
+setInterval(loadConfigs, 10000);
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// webAssets holds the dashboard served by `mdok serve` (no external CDN)
+//
+//go:embed web
+var webAssets embed.FS
+
+// webUIHandler serves the embedded web dashboard
+func webUIHandler() http.Handler {
+	assets, err := fs.Sub(webAssets, "web")
+	if err != nil {
+		// The embedded directory is fixed at build time
+		panic(err)
+	}
+	return http.FileServer(http.FS(assets))
+}
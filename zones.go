@@ -0,0 +1,458 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+)
+
+// Built-in network zones. Traffic no configured zone matches falls into one
+// of them.
+const (
+	ZoneInterContainer = "inter-container"
+	ZoneInternal       = "internal"
+	ZoneInternet       = "internet"
+)
+
+// Default egress rates (USD per GB) of the built-in zones; internet traffic
+// uses the region's data transfer price
+var defaultZoneRates = map[string]float64{
+	ZoneInterContainer: 0,
+	ZoneInternal:       0.01, // Cross-AZ and VPC peering
+}
+
+// NetworkZones classifies traffic into a configuration's network zones
+type NetworkZones struct {
+	zones []zoneRules // In evaluation order
+	rates map[string]float64
+}
+
+// zoneRules are the ways traffic matches a configured zone; any one of them
+// is enough
+type zoneRules struct {
+	name     string
+	networks []*net.IPNet
+	labels   map[string]string // All must match; an empty value matches any value
+	suffixes []string          // Reverse DNS name suffixes, lower case
+}
+
+// NewNetworkZones builds the network zones of a configuration. A zone named
+// after a built-in zone extends it, and with no rules only sets its rate.
+func NewNetworkZones(config Config) (*NetworkZones, error) {
+	zones := &NetworkZones{rates: make(map[string]float64)}
+	seen := make(map[string]bool)
+	for i, zone := range config.NetworkZones {
+		if zone.Name == "" {
+			return nil, fmt.Errorf("network zone %d has no name", i+1)
+		}
+		if _, ok := seen[zone.Name]; ok {
+			return nil, fmt.Errorf("duplicate network zone %q", zone.Name)
+		}
+		seen[zone.Name] = true
+
+		// Built-in zones keep their default rate unless one is given
+		switch {
+		case zone.CostPerGB != nil && *zone.CostPerGB < 0:
+			return nil, fmt.Errorf("network zone %q has a negative cost_per_gb", zone.Name)
+		case zone.CostPerGB != nil:
+			zones.rates[zone.Name] = *zone.CostPerGB
+		case !isBuiltinZone(zone.Name):
+			zones.rates[zone.Name] = 0
+		}
+
+		rules := zoneRules{name: zone.Name, labels: zone.ContainerLabels}
+		for _, cidr := range zone.CIDRs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("network zone %q: invalid CIDR %q: %w", zone.Name, cidr, err)
+			}
+			rules.networks = append(rules.networks, network)
+		}
+		for _, suffix := range zone.DNSSuffixes {
+			suffix = strings.ToLower(strings.Trim(suffix, "."))
+			if suffix == "" {
+				return nil, fmt.Errorf("network zone %q has an empty DNS suffix", zone.Name)
+			}
+			rules.suffixes = append(rules.suffixes, suffix)
+		}
+		if len(rules.suffixes) > 0 && config.NoReverseDNS {
+			return nil, fmt.Errorf("network zone %q matches DNS suffixes, which needs reverse DNS (no_reverse_dns is set)", zone.Name)
+		}
+
+		if len(rules.networks) == 0 && len(rules.labels) == 0 && len(rules.suffixes) == 0 {
+			if !isBuiltinZone(zone.Name) {
+				return nil, fmt.Errorf("network zone %q has no cidrs, container_labels or dns_suffixes", zone.Name)
+			}
+			continue
+		}
+		zones.zones = append(zones.zones, rules)
+	}
+	return zones, nil
+}
+
+// isBuiltinZone reports whether a zone name is one of the built-in zones
+func isBuiltinZone(name string) bool {
+	return name == ZoneInterContainer || name == ZoneInternal || name == ZoneInternet
+}
+
+// Rates returns the egress rates set by the configuration, by zone
+func (z *NetworkZones) Rates() map[string]float64 {
+	if z == nil || len(z.rates) == 0 {
+		return nil
+	}
+	return maps.Clone(z.rates)
+}
+
+// classify returns the zone of traffic to an IP: the zone most of it is
+// counted in when it goes to a proxy
+func (z *NetworkZones) classify(ip net.IP, peers *networkPeers, outgoing bool) string {
+	return z.split(ip, peers, outgoing)[0].zone
+}
+
+// zonePart is the fraction of traffic to an address counted in a zone
+type zonePart struct {
+	zone     string
+	fraction float64
+}
+
+// split returns the zones traffic to an IP is counted in, largest part
+// first. Configured zones are tried in order, then other containers, private
+// ranges and the internet. Traffic to a proxy counts as internet by the
+// proxy's internet fraction, and the rest like traffic to any other
+// container. A nil NetworkZones only uses the built-in zones. Only outgoing
+// traffic starts reverse DNS lookups for DNS suffixes: incoming traffic from
+// clients uses names already looked up.
+func (z *NetworkZones) split(ip net.IP, peers *networkPeers, outgoing bool) []zonePart {
+	ipStr := ip.String()
+	if z != nil {
+		for _, zone := range z.zones {
+			if zone.matches(ip, peers.labels[ipStr], outgoing) {
+				return []zonePart{{zone.name, 1}}
+			}
+		}
+	}
+
+	direct := ZoneInternet
+	if peers.containers[ipStr] {
+		direct = ZoneInterContainer
+	} else if isPrivateIP(ip) {
+		direct = ZoneInternal
+	}
+
+	fraction, proxy := peers.proxies[ipStr]
+	switch {
+	case !proxy || fraction == 0 || direct == ZoneInternet:
+		return []zonePart{{direct, 1}}
+	case fraction == 1:
+		return []zonePart{{ZoneInternet, 1}} // Internet via proxy
+	case fraction >= 0.5:
+		return []zonePart{{ZoneInternet, fraction}, {direct, 1 - fraction}}
+	}
+	return []zonePart{{direct, 1 - fraction}, {ZoneInternet, fraction}}
+}
+
+// splitBytes divides a byte count between zone parts, giving the rounding
+// remainder to the largest
+func splitBytes(n uint64, parts []zonePart) []uint64 {
+	split := make([]uint64, len(parts))
+	rest := n
+	for i := len(parts) - 1; i > 0; i-- {
+		split[i] = uint64(float64(n) * parts[i].fraction)
+		rest -= split[i]
+	}
+	split[0] = rest
+	return split
+}
+
+// matches reports whether traffic to an IP, owned by a container with the
+// given labels (nil for other hosts), belongs to a zone. Reverse DNS names
+// come from the background lookups shared with top destinations, so an
+// address only matches a suffix from its second sample on. Without lookup,
+// only cached names are used.
+func (r zoneRules) matches(ip net.IP, containerLabels map[string]string, lookup bool) bool {
+	for _, network := range r.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	if len(r.labels) > 0 && containerLabels != nil {
+		matched := true
+		for key, want := range r.labels {
+			value, ok := containerLabels[key]
+			if !ok || (want != "" && value != want) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	if len(r.suffixes) > 0 && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() {
+		name := reverseDNS.cached(ip.String())
+		if lookup {
+			name = reverseDNS.lookup(ip.String())
+		}
+		name = strings.ToLower(name)
+		for _, suffix := range r.suffixes {
+			if name == suffix || strings.HasSuffix(name, "."+suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sampleZones returns a sample's traffic by zone. Samples recorded before
+// network zones carry the built-in zones in separate fields, with the bytes
+// sent by the flows open at the time rather than since the previous sample.
+func sampleZones(s Sample) map[string]ZoneTraffic {
+	if s.NetZones != nil {
+		return s.NetZones
+	}
+	zones := make(map[string]ZoneTraffic)
+	for zone, traffic := range map[string]ZoneTraffic{
+		ZoneInterContainer: {Conns: s.NetConnInterContainer, TxBytes: s.NetBytesInterContainer},
+		ZoneInternal:       {Conns: s.NetConnInternal, TxBytes: s.NetBytesInternal},
+		ZoneInternet:       {Conns: s.NetConnInternet, TxBytes: s.NetBytesInternet},
+	} {
+		if traffic != (ZoneTraffic{}) {
+			zones[zone] = traffic
+		}
+	}
+	return zones
+}
+
+// zoneShares turns a session's traffic by zone into shares, largest first.
+// With byte counts, zones get their share of the bytes sent and the volumes
+// transferred; without, their share of TCP connections and connected UDP
+// sockets. It returns no shares when there is no traffic.
+func zoneShares(totals map[string]ZoneTraffic) (basis string, shares []ZoneShare) {
+	var sent, volume, conns uint64
+	for _, t := range totals {
+		sent += t.TxBytes
+		volume += t.TxBytes + t.RxBytes
+		conns += uint64(t.Conns + t.UDP)
+	}
+
+	for zone, t := range totals {
+		share := ZoneShare{Zone: zone}
+		switch {
+		case volume > 0:
+			if t.TxBytes+t.RxBytes == 0 {
+				continue
+			}
+			if sent > 0 {
+				share.Pct = float64(t.TxBytes) / float64(sent) * 100
+			}
+			share.TxBytes, share.RxBytes = t.TxBytes, t.RxBytes
+		case conns > 0:
+			if t.Conns+t.UDP == 0 {
+				continue
+			}
+			share.Pct = float64(t.Conns+t.UDP) / float64(conns) * 100
+		default:
+			return "", nil
+		}
+		shares = append(shares, share)
+	}
+	if len(shares) == 0 {
+		return "", nil
+	}
+
+	slices.SortFunc(shares, func(a, b ZoneShare) int {
+		if c := cmp.Compare(b.Pct, a.Pct); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.TxBytes+b.RxBytes, a.TxBytes+a.RxBytes); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Zone, b.Zone)
+	})
+	if volume > 0 {
+		return "bytes", shares
+	}
+	return "connections", shares
+}
+
+// shares returns a breakdown's zones, converting summaries saved before
+// network zones
+func (b *NetworkBreakdown) shares() []ZoneShare {
+	if len(b.Zones) > 0 {
+		return b.Zones
+	}
+	var shares []ZoneShare
+	for _, share := range []ZoneShare{
+		{Zone: ZoneInterContainer, Pct: b.InterContainerPct},
+		{Zone: ZoneInternal, Pct: b.InternalPct},
+		{Zone: ZoneInternet, Pct: b.InternetPct},
+	} {
+		if share.Pct > 0 {
+			shares = append(shares, share)
+		}
+	}
+	return shares
+}
+
+// zoneRate returns the egress rate of a zone: the configured one, else the
+// built-in default, else the internet price
+func zoneRate(zone string, rates map[string]float64, internetPrice float64) float64 {
+	if rate, ok := rates[zone]; ok {
+		return rate
+	}
+	if rate, ok := defaultZoneRates[zone]; ok {
+		return rate
+	}
+	return internetPrice
+}
+
+// formatNetworkBreakdown shows a breakdown as "zone=pct%" pairs, with the
+// volumes sent and received when they were measured
+func formatNetworkBreakdown(b *NetworkBreakdown) string {
+	var parts []string
+	for _, share := range b.shares() {
+		part := fmt.Sprintf("%s=%.1f%%", share.Zone, share.Pct)
+		if share.TxBytes+share.RxBytes > 0 {
+			part += fmt.Sprintf(" (%s out / %s in)", formatBytes(share.TxBytes), formatBytes(share.RxBytes))
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// applyZoneBandwidth sets a sample's rates by zone against the previous
+// sample. Conntrack byte deltas are divided by the time between the two;
+// without conntrack the interface rates are split by each zone's share of
+// connections.
+func applyZoneBandwidth(result *StatsResult, prev *StatsResult) {
+	s := &result.Sample
+	if prev == nil || len(s.NetZones) == 0 {
+		return
+	}
+	elapsed := s.Timestamp.Sub(prev.Sample.Timestamp).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	bandwidth := make(map[string]ZoneBandwidth)
+	if s.NetBytesSource == "conntrack" {
+		if prev.PrevFlows == nil {
+			return // The first conntrack read only records the counters
+		}
+		for zone, t := range s.NetZones {
+			bandwidth[zone] = ZoneBandwidth{Tx: float64(t.TxBytes) / elapsed, Rx: float64(t.RxBytes) / elapsed}
+		}
+	} else {
+		var conns int
+		for _, t := range s.NetZones {
+			conns += t.Conns + t.UDP
+		}
+		if conns == 0 {
+			return
+		}
+		for zone, t := range s.NetZones {
+			share := float64(t.Conns+t.UDP) / float64(conns)
+			bandwidth[zone] = ZoneBandwidth{Tx: s.NetTxRate * share, Rx: s.NetRxRate * share}
+		}
+	}
+	s.NetZoneBandwidth = bandwidth
+}
+
+// summarizeZoneBandwidth summarizes each zone's rates over the samples that
+// measured them, counting samples without the zone as 0. Conntrack samples
+// after the first measure rates even when nothing was transferred, so they
+// count with no rates saved. Zones that never transferred anything are left
+// out.
+func summarizeZoneBandwidth(samples []Sample) map[string]ZoneBandwidthSummary {
+	var measured []Sample
+	zones := make(map[string]bool)
+	conntrackBefore := false
+	for _, s := range samples {
+		counted := s.NetZoneBandwidth != nil || (s.NetBytesSource == "conntrack" && conntrackBefore)
+		conntrackBefore = s.NetBytesSource == "conntrack"
+		if !counted {
+			continue
+		}
+		measured = append(measured, s)
+		for zone, b := range s.NetZoneBandwidth {
+			if b.Tx > 0 || b.Rx > 0 {
+				zones[zone] = true
+			}
+		}
+	}
+	if len(zones) == 0 {
+		return nil
+	}
+
+	summaries := make(map[string]ZoneBandwidthSummary)
+	for zone := range zones {
+		tx := make([]float64, len(measured))
+		rx := make([]float64, len(measured))
+		for i, s := range measured {
+			tx[i] = s.NetZoneBandwidth[zone].Tx
+			rx[i] = s.NetZoneBandwidth[zone].Rx
+		}
+		summaries[zone] = ZoneBandwidthSummary{Tx: calculateStats(tx), Rx: calculateStats(rx)}
+	}
+	return summaries
+}
+
+// bandwidthZones orders the zones of a bandwidth summary, busiest first
+func bandwidthZones(summaries map[string]ZoneBandwidthSummary) []string {
+	return slices.SortedFunc(maps.Keys(summaries), func(a, b string) int {
+		if c := cmp.Compare(summaries[b].Tx.Avg+summaries[b].Rx.Avg, summaries[a].Tx.Avg+summaries[a].Rx.Avg); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+}
+
+// formatRate shows a rate in bytes/sec
+func formatRate(bytesPerSec float64) string {
+	return formatBytes(uint64(bytesPerSec)) + "/s"
+}
+
+// formatZoneBandwidth lays out a bandwidth summary as text lines, one per
+// zone and direction
+func formatZoneBandwidth(summaries map[string]ZoneBandwidthSummary) []string {
+	zones := bandwidthZones(summaries)
+	width := 0
+	for _, zone := range zones {
+		width = max(width, len(zone))
+	}
+	var lines []string
+	for _, zone := range zones {
+		for _, dir := range []struct {
+			name  string
+			stats Summary
+		}{{"out", summaries[zone].Tx}, {"in", summaries[zone].Rx}} {
+			lines = append(lines, fmt.Sprintf("%-*s %-3s min=%s avg=%s p95=%s max=%s", width, zone, dir.name,
+				formatRate(dir.stats.Min), formatRate(dir.stats.Avg), formatRate(dir.stats.P95), formatRate(dir.stats.Max)))
+		}
+	}
+	return lines
+}
+
+// stackedZoneBandwidth returns every step-th sample's rates in KB/s, one
+// series per zone, each stacked on the ones before it
+func stackedZoneBandwidth(samples []Sample, zones []string, step int, tx bool) [][]float64 {
+	series := make([][]float64, len(zones))
+	for i := 0; i < len(samples); i += step {
+		total := 0.0
+		for k, zone := range zones {
+			b := samples[i].NetZoneBandwidth[zone]
+			if tx {
+				total += b.Tx / 1024
+			} else {
+				total += b.Rx / 1024
+			}
+			series[k] = append(series[k], total)
+		}
+	}
+	return series
+}